@@ -8,28 +8,66 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/HJyup/translatify-common/tracer"
 
+	"github.com/HJyup/translatify-chat/internal/detector"
 	"github.com/HJyup/translatify-chat/internal/handler"
+	"github.com/HJyup/translatify-chat/internal/hub"
+	"github.com/HJyup/translatify-chat/internal/models"
+	"github.com/HJyup/translatify-chat/internal/outbox"
 	"github.com/HJyup/translatify-chat/internal/service"
 	"github.com/HJyup/translatify-chat/internal/store"
+	"github.com/HJyup/translatify-chat/internal/translator"
 	"github.com/HJyup/translatify-common/broker"
 	"github.com/HJyup/translatify-common/discovery"
 	"github.com/HJyup/translatify-common/discovery/consul"
+	"github.com/HJyup/translatify-common/pagination"
 	common "github.com/HJyup/translatify-common/utils"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/grpc"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
+// The database settings are all the migrate subcommand needs, so they are
+// the only ones read up front.
 var (
+	dbUser = common.EnvString("POSTGRES_USER")
+	dbPass = common.EnvString("POSTGRES_PASSWORD")
+	dbHost = common.EnvString("POSTGRES_DB")
+	dbPort = common.EnvString("POSTGRES_PORT")
+	dbName = common.EnvString("POSTGRES_DB_NAME")
+)
+
+var (
+	serviceName string
+	grpcAddr    string
+	consulAddr  string
+	environment string
+
+	amqpUser string
+	amqpPass string
+	amqpHost string
+	amqpPort string
+
+	jaegerAddr string
+
+	pageTokenSecret string
+
+	streamBus string
+
+	translationContext string
+	translationEnabled string
+)
+
+func loadServiceEnv() {
 	serviceName = common.EnvString("SERVICE_NAME")
-	grpcAddr    = common.EnvString("GRPC_ADDR")
-	consulAddr  = common.EnvString("CONSUL_ADDR")
+	grpcAddr = common.EnvString("GRPC_ADDR")
+	consulAddr = common.EnvString("CONSUL_ADDR")
 	environment = common.EnvString("ENVIRONMENT")
 
 	amqpUser = common.EnvString("AMQP_USER")
@@ -37,14 +75,30 @@ var (
 	amqpHost = common.EnvString("AMQP_HOST")
 	amqpPort = common.EnvString("AMQP_PORT")
 
-	dbUser = common.EnvString("POSTGRES_USER")
-	dbPass = common.EnvString("POSTGRES_PASSWORD")
-	dbHost = common.EnvString("POSTGRES_DB")
-	dbPort = common.EnvString("POSTGRES_PORT")
-	dbName = common.EnvString("POSTGRES_DB_NAME")
-
 	jaegerAddr = common.EnvString("JAEGER_ADDR")
-)
+
+	pageTokenSecret = common.EnvString("PAGE_TOKEN_SECRET")
+
+	streamBus = common.EnvStringDefault("STREAM_BUS", "local")
+
+	translationContext = common.EnvStringDefault("TRANSLATION_CONTEXT_MESSAGES", "0")
+	translationEnabled = common.EnvStringDefault("TRANSLATION_ENABLED", "true")
+}
+
+func openDatabase(ctx context.Context) (*pgxpool.Config, *pgxpool.Pool) {
+	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", dbUser, dbPass, dbName, dbPort, dbHost)
+
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		log.Fatalf("Failed to parse database config: %v", err)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	return config, pool
+}
 
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -57,6 +111,33 @@ func main() {
 		cancel()
 	}()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		_, pool := openDatabase(ctx)
+		defer pool.Close()
+		if err := runMigrations(ctx, pool, os.Args[2:]); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		return
+	}
+
+	loadServiceEnv()
+
+	if len(os.Args) > 1 && os.Args[1] == "deadletters" {
+		ch, closeConn := broker.Connect(amqpUser, amqpPass, amqpHost, amqpPort)
+		defer closeConn()
+		if err := broker.RunDeadLetters(ctx, ch, broker.MessageTranslatedEvent, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Failed to handle dead letters: %v", err)
+		}
+		return
+	}
+
+	config, pool := openDatabase(ctx)
+	defer pool.Close()
+
+	if err := runMigrations(ctx, pool, nil); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
+
 	tracerCfg := tracer.Config{
 		ServiceName:    serviceName,
 		ServiceVersion: "1.0.0",
@@ -112,31 +193,58 @@ func main() {
 		}
 	}()
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", dbUser, dbPass, dbName, dbPort, dbHost)
-
-	config, err := pgx.ParseConfig(dsn)
+	grpcServer := grpc.NewServer(discovery.ServerOptions()...)
+	conn, err := net.Listen("tcp", grpcAddr)
 	if err != nil {
-		log.Fatalf("Failed to parse database config: %v", err)
+		log.Fatalf("Failed to listen on %s: %v", grpcAddr, err)
 	}
+	defer conn.Close()
 
-	dbConn, err := pgx.ConnectConfig(ctx, config)
-	if err != nil {
-		log.Fatalf("Failed to connect to the database: %v", err)
+	str := store.NewStore(pool)
+
+	var bus models.MessageBus
+	switch streamBus {
+	case "local":
+	case "postgres":
+		bus = hub.NewPostgresBus(config.ConnConfig, pool, str.GetMessage)
+	case "amqp":
+		amqpBus, err := hub.NewAMQPBus(broker.Address(amqpUser, amqpPass, amqpHost, amqpPort))
+		if err != nil {
+			log.Fatalf("Failed to set up the stream exchange: %v", err)
+		}
+		defer amqpBus.Close()
+		bus = amqpBus
+	default:
+		log.Fatalf("Unknown STREAM_BUS %q, expected local, postgres or amqp", streamBus)
 	}
-	defer dbConn.Close(ctx)
 
-	grpcServer := grpc.NewServer()
-	conn, err := net.Listen("tcp", grpcAddr)
+	msgHub := hub.NewHub(bus, hub.DefaultBufferSize)
+	go msgHub.Run(ctx)
+
+	contextSize, err := strconv.Atoi(translationContext)
+	if err != nil || contextSize < 0 {
+		log.Fatalf("Invalid TRANSLATION_CONTEXT_MESSAGES %q", translationContext)
+	}
+	translate, err := strconv.ParseBool(translationEnabled)
 	if err != nil {
-		log.Fatalf("Failed to listen on %s: %v", grpcAddr, err)
+		log.Fatalf("Invalid TRANSLATION_ENABLED %q", translationEnabled)
 	}
-	defer conn.Close()
 
-	str := store.NewStore(dbConn)
-	srv := service.NewService(str)
-	handler.NewGrpcHandler(grpcServer, srv, ch)
+	srv := service.NewService(str, msgHub, pagination.NewCodec(pageTokenSecret), detector.NewGrpcDetector(registry), translator.NewGrpcTranslator(registry), contextSize, translate)
+	handler.NewGrpcHandler(grpcServer, srv)
+
+	// The relay holds a transaction while publishing, which ties up one of
+	// the pool's connections, and gets its own confirm-mode channel.
+	relayCh, closeRelayConn := broker.Connect(amqpUser, amqpPass, amqpHost, amqpPort)
+	defer closeRelayConn()
+
+	relay, err := outbox.NewRelay(str, relayCh, outbox.DefaultInterval, outbox.DefaultBatchSize)
+	if err != nil {
+		log.Fatalf("Failed to start the outbox relay: %v", err)
+	}
+	go relay.Run(ctx)
 
-	cons := consumer.NewConsumer(srv)
+	cons := consumer.NewConsumer(srv, broker.DefaultRetryPolicy)
 	go cons.Listen(ch)
 
 	log.Printf("Starting chat server on %s", grpcAddr)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/HJyup/translatify-chat/internal/store"
+	"github.com/HJyup/translatify-common/migrate"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLock scopes the migration advisory lock. It is fixed rather than
+// taken from SERVICE_NAME, so the migrate subcommand only needs the database
+// settings.
+const migrationLock = "chat"
+
+// runMigrations handles both the startup migration and the migrate subcommand:
+//
+//	chat migrate [up | down [steps] | version]
+func runMigrations(ctx context.Context, pool *pgxpool.Pool, args []string) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	migrator, err := migrate.NewMigrator(conn.Conn(), store.Migrations(), migrationLock)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Schema version: %d", version)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
@@ -1,7 +1,7 @@
 package consumer
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/HJyup/translatify-chat/internal/models"
 	"github.com/HJyup/translatify-common/broker"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -10,39 +10,57 @@ import (
 
 type Consumer struct {
 	service models.ChatService
+	policy  broker.RetryPolicy
 }
 
-func NewConsumer(service models.ChatService) *Consumer {
-	return &Consumer{service: service}
+func NewConsumer(service models.ChatService, policy broker.RetryPolicy) *Consumer {
+	return &Consumer{service: service, policy: policy}
 }
 
 func (c *Consumer) Listen(ch *amqp.Channel) {
-	q, err := ch.QueueDeclare(broker.MessageTranslatedEvent, true, false, false, false, nil)
-	if err != nil {
-		panic(err)
+	if err := broker.DeclareRetryTopology(ch, broker.MessageTranslatedEvent, c.policy); err != nil {
+		log.Fatalf("Failed to declare %s topology: %v", broker.MessageTranslatedEvent, err)
 	}
 
-	msg, err := ch.Consume(q.Name, "", true, false, false, false, nil)
-
-	var forever chan struct{}
+	msgs, err := broker.Subscribe[broker.MessageTranslated](ch, broker.MessageTranslatedEvent, "")
+	if err != nil {
+		log.Fatalf("Failed to consume %s: %v", broker.MessageTranslatedEvent, err)
+	}
 
-	go func() {
-		for d := range msg {
+	for d := range msgs {
+		c.handle(ch, d)
+	}
+}
 
-			msg := &models.ConsumerResponse{}
-			err = json.Unmarshal(d.Body, msg)
-			if err != nil {
-				panic(err)
-			}
+// handle applies one translation outcome in the trace of the message it
+// belongs to.
+func (c *Consumer) handle(ch *amqp.Channel, d broker.Delivery[broker.MessageTranslated]) {
+	ctx, span := broker.StartProcess(context.Background(), broker.MessageTranslatedEvent, d.Delivery)
+	msg := d.Envelope.Data
 
-			err = c.service.UpdateMessageTranslation(msg.MessageId, msg.TranslatedContent)
-			if err != nil {
-				panic(err)
-			}
+	update := &models.TranslationUpdate{
+		MessageID:         msg.MessageID,
+		Status:            models.TranslationDone,
+		TranslatedContent: msg.TranslatedContent,
+		Provider:          msg.Provider,
+		Model:             msg.Model,
+	}
+	if !msg.Success {
+		update = &models.TranslationUpdate{MessageID: msg.MessageID, Status: models.TranslationFailed, Error: msg.Error}
+	}
 
-			log.Println("Message is updated")
+	err := c.service.UpdateMessageTranslation(ctx, update)
+	defer broker.EndSpan(span, err)
+	if err != nil {
+		log.Printf("Failed to update translation of message %s (attempt %d): %v", msg.MessageID, broker.RetryCount(d.Delivery)+1, err)
+		if err = broker.Retry(ctx, ch, broker.MessageTranslatedEvent, d.Delivery, c.policy, err); err != nil {
+			log.Printf("Failed to schedule retry of message %s: %v", msg.MessageID, err)
 		}
-	}()
+		return
+	}
 
-	<-forever
+	if err = d.Ack(false); err != nil {
+		log.Printf("Failed to acknowledge translation of message %s: %v", msg.MessageID, err)
+	}
+	log.Println("Message is updated")
 }
@@ -0,0 +1,43 @@
+package detector
+
+import (
+	"context"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+	pb "github.com/HJyup/translatify-common/api"
+	"github.com/HJyup/translatify-common/discovery"
+)
+
+// GrpcDetector asks the translation service to detect message languages.
+type GrpcDetector struct {
+	registry discovery.Registry
+}
+
+func NewGrpcDetector(registry discovery.Registry) *GrpcDetector {
+	return &GrpcDetector{registry: registry}
+}
+
+// DetectLanguage bills the detection to username in case the translation
+// service has to ask an LLM.
+func (d *GrpcDetector) DetectLanguage(ctx context.Context, content, chatID, username string) (*models.Detection, error) {
+	conn, err := discovery.ServiceConnection(ctx, "translation", d.registry)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, err := pb.NewTranslationServiceClient(conn).DetectLanguage(ctx, &pb.DetectLanguageRequest{
+		Content:  content,
+		ChatId:   chatID,
+		Username: username,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Detection{
+		Language:   resp.GetLanguage(),
+		Confidence: resp.GetConfidence(),
+		Mixed:      resp.GetMixed(),
+	}, nil
+}
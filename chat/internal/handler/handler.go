@@ -2,14 +2,13 @@ package handler
 
 import (
 	"context"
-	"github.com/HJyup/translatify-common/broker"
-	"github.com/go-jose/go-jose/v3/json"
+	"errors"
+	"github.com/HJyup/translatify-common/pagination"
 	"go.opentelemetry.io/otel"
 	"time"
 
 	"github.com/HJyup/translatify-chat/internal/models"
 	pb "github.com/HJyup/translatify-common/api"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -19,13 +18,11 @@ type GrpcHandler struct {
 	pb.UnimplementedChatServiceServer
 
 	service models.ChatService
-	channel *amqp.Channel
 }
 
-func NewGrpcHandler(grpcServer *grpc.Server, service models.ChatService, channel *amqp.Channel) {
+func NewGrpcHandler(grpcServer *grpc.Server, service models.ChatService) {
 	handler := &GrpcHandler{
 		service: service,
-		channel: channel,
 	}
 	pb.RegisterChatServiceServer(grpcServer, handler)
 }
@@ -48,6 +45,10 @@ func chatMessageFromModel(msg *models.ChatMessage) *pb.ChatMessage {
 	if msg == nil {
 		return nil
 	}
+	var translatedAt int64
+	if !msg.TranslatedAt.IsZero() {
+		translatedAt = msg.TranslatedAt.Unix()
+	}
 	return &pb.ChatMessage{
 		MessageId:         msg.MessageID,
 		ChatId:            msg.ChatID,
@@ -56,6 +57,59 @@ func chatMessageFromModel(msg *models.ChatMessage) *pb.ChatMessage {
 		Content:           msg.Content,
 		TranslatedContent: msg.TranslatedContent,
 		Timestamp:         msg.Timestamp.Unix(),
+		Sequence:          msg.Sequence,
+		DetectedLanguage:  msg.DetectedLanguage,
+		MixedLanguage:     msg.MixedLanguage,
+		SourceLanguage:    msg.SourceLanguage,
+
+		TranslationStatus:   translationStatusToProto(msg.TranslationStatus),
+		TranslationError:    msg.TranslationError,
+		TranslationProvider: msg.TranslationProvider,
+		TranslationModel:    msg.TranslationModel,
+		TranslatedAt:        translatedAt,
+
+		TranslationAlternativeId: msg.TranslationAlternativeID,
+	}
+}
+
+func translationAlternativeFromModel(alternative *models.TranslationAlternative) *pb.TranslationAlternative {
+	return &pb.TranslationAlternative{
+		AlternativeId:     alternative.AlternativeID,
+		MessageId:         alternative.MessageID,
+		TranslatedContent: alternative.TranslatedContent,
+		Style:             alternative.Style,
+		Provider:          alternative.Provider,
+		Model:             alternative.Model,
+		CreatedAt:         alternative.CreatedAt.Unix(),
+	}
+}
+
+func translationStatusToProto(status models.TranslationStatus) pb.TranslationStatus {
+	switch status {
+	case models.TranslationPending:
+		return pb.TranslationStatus_TRANSLATION_STATUS_PENDING
+	case models.TranslationDone:
+		return pb.TranslationStatus_TRANSLATION_STATUS_DONE
+	case models.TranslationFailed:
+		return pb.TranslationStatus_TRANSLATION_STATUS_FAILED
+	case models.TranslationSkipped:
+		return pb.TranslationStatus_TRANSLATION_STATUS_SKIPPED
+	case models.TranslationDisabled:
+		return pb.TranslationStatus_TRANSLATION_STATUS_DISABLED
+	default:
+		return pb.TranslationStatus_TRANSLATION_STATUS_UNSPECIFIED
+	}
+}
+
+func chatEventFromModel(event *models.ChatEvent) *pb.ChatEvent {
+	msg := chatMessageFromModel(event.Message)
+	switch event.Type {
+	case models.MessageTranslated:
+		return &pb.ChatEvent{Event: &pb.ChatEvent_MessageTranslated{MessageTranslated: msg}}
+	case models.MessageEdited:
+		return &pb.ChatEvent{Event: &pb.ChatEvent_MessageEdited{MessageEdited: msg}}
+	default:
+		return &pb.ChatEvent{Event: &pb.ChatEvent_MessageCreated{MessageCreated: msg}}
 	}
 }
 
@@ -94,52 +148,27 @@ func (h *GrpcHandler) SendMessage(ctx context.Context, req *pb.SendMessageReques
 		return nil, status.Error(codes.InvalidArgument, "chat_id, sender_username, receiver_username, and content must be provided")
 	}
 
-	chat, err := h.service.GetChat(chatID)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to get Chat: %v", err)
-	}
-
 	messageID, err := h.service.SendMessage(ctx, chatID, senderUsername, receiverUsername, content)
+	if errors.Is(err, models.ErrNotParticipant) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to send message: %v", err)
 	}
 
-	if chat.SourceLang != chat.TargetLang {
-		q, err := h.channel.QueueDeclare(broker.MessageSentEvent, true, false, false, false, nil)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to declare queue: %v", err)
-		}
-
-		msgData := map[string]interface{}{
-			"sourceLang": chat.TargetLang,
-			"targetLang": chat.SourceLang,
-			"messageID":  messageID,
-			"content":    content,
-		}
-
-		body, err := json.Marshal(msgData)
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to marshal message to JSON: %v", err)
-		}
-
-		err = h.channel.PublishWithContext(ctx, "", q.Name, false, false, amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent,
-		})
-
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to publish message: %v", err)
-		}
-	}
-
 	return &pb.SendMessageResponse{MessageId: messageID}, nil
 }
 
 func (h *GrpcHandler) StreamMessages(req *pb.StreamMessagesRequest, stream pb.ChatService_StreamMessagesServer) error {
 	chatID := req.GetChatId()
 
-	msgCh, err := h.service.StreamMessages(stream.Context(), chatID)
+	var since *time.Time
+	if req.GetSinceTimestamp() > 0 {
+		t := time.Unix(req.GetSinceTimestamp(), 0)
+		since = &t
+	}
+
+	eventCh, err := h.service.StreamMessages(stream.Context(), chatID, req.GetAfterSequence(), since)
 	if err != nil {
 		return status.Errorf(codes.Internal, "failed to start message stream: %v", err)
 	}
@@ -148,13 +177,12 @@ func (h *GrpcHandler) StreamMessages(req *pb.StreamMessagesRequest, stream pb.Ch
 		select {
 		case <-stream.Context().Done():
 			return nil
-		case msg, ok := <-msgCh:
+		case event, ok := <-eventCh:
 			if !ok {
-				return nil
+				return status.Error(codes.Aborted, "message stream interrupted, reconnect with after_sequence to resume")
 			}
-			protoMsg := chatMessageFromModel(msg)
-			if err := stream.Send(protoMsg); err != nil {
-				return status.Errorf(codes.Internal, "failed to send message: %v", err)
+			if err := stream.Send(chatEventFromModel(event)); err != nil {
+				return status.Errorf(codes.Internal, "failed to send event: %v", err)
 			}
 		}
 	}
@@ -180,7 +208,15 @@ func (h *GrpcHandler) ListMessages(_ context.Context, req *pb.ListMessagesReques
 		since = &t
 	}
 
-	msgs, pageToken, err := h.service.ListMessages(req.GetChatId(), since, int(req.GetLimit()), req.GetPageToken())
+	direction := pagination.Forward
+	if req.GetDirection() == pb.PageDirection_PAGE_DIRECTION_BACKWARD {
+		direction = pagination.Backward
+	}
+
+	msgs, pageToken, err := h.service.ListMessages(req.GetChatId(), since, int(req.GetLimit()), req.GetPageToken(), direction)
+	if errors.Is(err, pagination.ErrInvalidToken) {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to list messages: %v", err)
 	}
@@ -223,3 +259,97 @@ func (h *GrpcHandler) ListChats(_ context.Context, req *pb.ListChatsRequest) (*p
 
 	return &pb.ListChatsResponse{Chats: protoChats}, nil
 }
+
+func (h *GrpcHandler) RetranslateMessage(ctx context.Context, req *pb.RetranslateMessageRequest) (*pb.RetranslateMessageResponse, error) {
+	if req.GetMessageId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "message_id must be provided")
+	}
+
+	msg, alternative, err := h.service.RetranslateMessage(ctx, req.GetMessageId(), req.GetStyle(), req.GetProvider(), req.GetUsername())
+	switch {
+	case errors.Is(err, models.ErrInvalidTranslationOptions):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrTranslationUnavailable):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, models.ErrTranslationQuotaExceeded):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to retranslate message: %v", err)
+	}
+
+	return &pb.RetranslateMessageResponse{
+		Alternative: translationAlternativeFromModel(alternative),
+		Message:     chatMessageFromModel(msg),
+	}, nil
+}
+
+func (h *GrpcHandler) ListTranslationAlternatives(ctx context.Context, req *pb.ListTranslationAlternativesRequest) (*pb.ListTranslationAlternativesResponse, error) {
+	if req.GetMessageId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "message_id must be provided")
+	}
+
+	msg, err := h.service.GetMessage(req.GetMessageId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get message: %v", err)
+	}
+	alternatives, err := h.service.ListTranslationAlternatives(ctx, req.GetMessageId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list translation alternatives: %v", err)
+	}
+
+	resp := &pb.ListTranslationAlternativesResponse{
+		Alternatives:          make([]*pb.TranslationAlternative, len(alternatives)),
+		SelectedAlternativeId: msg.TranslationAlternativeID,
+	}
+	for i, alternative := range alternatives {
+		resp.Alternatives[i] = translationAlternativeFromModel(alternative)
+	}
+	return resp, nil
+}
+
+func (h *GrpcHandler) SelectTranslationAlternative(ctx context.Context, req *pb.SelectTranslationAlternativeRequest) (*pb.SelectTranslationAlternativeResponse, error) {
+	if req.GetMessageId() == "" || req.GetAlternativeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "message_id and alternative_id must be provided")
+	}
+
+	msg, err := h.service.SelectTranslationAlternative(ctx, req.GetMessageId(), req.GetAlternativeId())
+	if errors.Is(err, models.ErrAlternativeNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to select translation alternative: %v", err)
+	}
+
+	return &pb.SelectTranslationAlternativeResponse{Message: chatMessageFromModel(msg)}, nil
+}
+
+func (h *GrpcHandler) GetMessageTranslation(ctx context.Context, req *pb.GetMessageTranslationRequest) (*pb.GetMessageTranslationResponse, error) {
+	if req.GetMessageId() == "" || req.GetLanguage() == "" {
+		return nil, status.Error(codes.InvalidArgument, "message_id and language must be provided")
+	}
+
+	translation, err := h.service.GetMessageTranslation(ctx, req.GetMessageId(), req.GetLanguage(), req.GetUsername())
+	switch {
+	case errors.Is(err, models.ErrInvalidTranslationOptions):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrTranslationUnavailable), errors.Is(err, models.ErrTranslationNotReady):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, models.ErrTranslationQuotaExceeded):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to get message translation: %v", err)
+	}
+
+	var createdAt int64
+	if !translation.CreatedAt.IsZero() {
+		createdAt = translation.CreatedAt.Unix()
+	}
+	return &pb.GetMessageTranslationResponse{Translation: &pb.MessageTranslation{
+		MessageId:         translation.MessageID,
+		Language:          translation.Language,
+		TranslatedContent: translation.TranslatedContent,
+		Provider:          translation.Provider,
+		Model:             translation.Model,
+		CreatedAt:         createdAt,
+	}}, nil
+}
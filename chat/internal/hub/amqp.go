@@ -0,0 +1,134 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const streamExchange = "chat.stream"
+
+// AMQPBus shares chat events between replicas through a fanout exchange.
+// Every replica binds its own exclusive queue, so each one receives a copy.
+// The bus has a connection of its own, so a channel error elsewhere in the
+// service does not stop the fan-out, and dials again once it is lost.
+type AMQPBus struct {
+	address string
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	publishCh *amqp.Channel
+}
+
+func NewAMQPBus(address string) (*AMQPBus, error) {
+	b := &AMQPBus{address: address}
+
+	ch, err := b.channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	if err = ch.ExchangeDeclare(streamExchange, "fanout", true, false, false, false, nil); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// channel opens a channel on the bus connection, dialling again if the
+// connection was lost.
+func (b *AMQPBus) channel() (*amqp.Channel, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.openLocked()
+}
+
+func (b *AMQPBus) openLocked() (*amqp.Channel, error) {
+	if b.conn == nil || b.conn.IsClosed() {
+		conn, err := amqp.Dial(b.address)
+		if err != nil {
+			return nil, err
+		}
+		b.conn = conn
+	}
+	return b.conn.Channel()
+}
+
+func (b *AMQPBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	return b.conn.Close()
+}
+
+func (b *AMQPBus) Publish(ctx context.Context, event *models.ChatEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	if b.publishCh == nil || b.publishCh.IsClosed() {
+		if b.publishCh, err = b.openLocked(); err != nil {
+			b.mu.Unlock()
+			return err
+		}
+	}
+	ch := b.publishCh
+	b.mu.Unlock()
+
+	return ch.PublishWithContext(ctx, streamExchange, "", false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+	})
+}
+
+// Listen returns an error when the channel or its connection is closed, so
+// the hub can listen again on a new one.
+func (b *AMQPBus) Listen(ctx context.Context, deliver func(*models.ChatEvent)) error {
+	ch, err := b.channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = ch.QueueBind(q.Name, "", streamExchange, false, nil); err != nil {
+		return err
+	}
+
+	deliveries, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				return errors.New("stream channel closed")
+			}
+
+			event := &models.ChatEvent{}
+			if err = json.Unmarshal(d.Body, event); err != nil || event.Message == nil {
+				log.Printf("Failed to decode chat event: %v", err)
+				continue
+			}
+			deliver(event)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package hub
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+)
+
+const DefaultBufferSize = 64
+
+// busBackoff is the first wait before listening to a failed bus again; it
+// doubles up to maxBusBackoff while the bus keeps failing.
+var (
+	busBackoff    = time.Second
+	maxBusBackoff = 30 * time.Second
+)
+
+type subscriber struct {
+	ch   chan *models.ChatEvent
+	once sync.Once
+}
+
+func (s *subscriber) close() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// Hub fans chat events out to every StreamMessages subscriber of a chat. When
+// a bus is configured, events travel through it so that subscribers connected
+// to other replicas see them as well; otherwise delivery stays in-process.
+type Hub struct {
+	bus        models.MessageBus
+	bufferSize int
+
+	mu   sync.RWMutex
+	subs map[string]map[*subscriber]struct{}
+}
+
+func NewHub(bus models.MessageBus, bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Hub{
+		bus:        bus,
+		bufferSize: bufferSize,
+		subs:       make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// Run delivers events received from the bus to local subscribers until ctx
+// is cancelled. It returns immediately when the hub has no bus. When the bus
+// fails, every subscriber is closed, since it may have missed events, and
+// resumes with its cursor while the hub listens again after a backoff.
+func (h *Hub) Run(ctx context.Context) {
+	if h.bus == nil {
+		return
+	}
+
+	backoff := busBackoff
+	for {
+		started := time.Now()
+		err := h.bus.Listen(ctx, h.broadcast)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxBusBackoff {
+			backoff = busBackoff
+		}
+
+		log.Printf("Message stream bus failed, listening again in %s: %v", backoff, err)
+		h.closeAll()
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBusBackoff)
+	}
+}
+
+func (h *Hub) Publish(ctx context.Context, event *models.ChatEvent) error {
+	if h.bus == nil {
+		h.broadcast(event)
+		return nil
+	}
+	return h.bus.Publish(ctx, event)
+}
+
+// Subscribe registers a subscriber for chatID. The returned channel is closed
+// when the subscriber is cancelled or when it falls more than the buffer size
+// behind, so a slow client never stalls delivery to the others.
+func (h *Hub) Subscribe(chatID string) (<-chan *models.ChatEvent, func()) {
+	sub := &subscriber{ch: make(chan *models.ChatEvent, h.bufferSize)}
+
+	h.mu.Lock()
+	if h.subs[chatID] == nil {
+		h.subs[chatID] = make(map[*subscriber]struct{})
+	}
+	h.subs[chatID][sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub.ch, func() { h.remove(chatID, sub) }
+}
+
+func (h *Hub) broadcast(event *models.ChatEvent) {
+	chatID := event.Message.ChatID
+	var slow []*subscriber
+
+	h.mu.RLock()
+	for sub := range h.subs[chatID] {
+		select {
+		case sub.ch <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range slow {
+		log.Printf("Dropping slow subscriber of chat %s", chatID)
+		h.remove(chatID, sub)
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	subs := h.subs
+	h.subs = make(map[string]map[*subscriber]struct{})
+	h.mu.Unlock()
+
+	for _, chatSubs := range subs {
+		for sub := range chatSubs {
+			sub.close()
+		}
+	}
+}
+
+func (h *Hub) remove(chatID string, sub *subscriber) {
+	h.mu.Lock()
+	if subs, ok := h.subs[chatID]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subs, chatID)
+		}
+	}
+	h.mu.Unlock()
+
+	sub.close()
+}
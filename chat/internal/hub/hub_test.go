@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+)
+
+// flakyBus fails its first Listen and then delivers event until cancelled.
+type flakyBus struct {
+	listens chan int
+	event   *models.ChatEvent
+	calls   int
+}
+
+func (b *flakyBus) Publish(ctx context.Context, event *models.ChatEvent) error {
+	return nil
+}
+
+func (b *flakyBus) Listen(ctx context.Context, deliver func(*models.ChatEvent)) error {
+	b.calls++
+	b.listens <- b.calls
+	if b.calls == 1 {
+		return errors.New("connection lost")
+	}
+	deliver(b.event)
+	<-ctx.Done()
+	return nil
+}
+
+func TestRunListensAgainAfterTheBusFails(t *testing.T) {
+	busBackoff = time.Millisecond
+	defer func() { busBackoff = time.Second }()
+
+	event := &models.ChatEvent{Type: models.MessageCreated, Message: &models.ChatMessage{MessageID: "m", ChatID: "chat"}}
+	bus := &flakyBus{listens: make(chan int), event: event}
+	h := NewHub(bus, 1)
+
+	before, cancelBefore := h.Subscribe("chat")
+	defer cancelBefore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		h.Run(ctx)
+		close(done)
+	}()
+
+	<-bus.listens
+	if _, ok := <-before; ok {
+		t.Error("subscriber got an event instead of being closed when the bus failed")
+	}
+
+	after, cancelAfter := h.Subscribe("chat")
+	defer cancelAfter()
+	<-bus.listens
+	select {
+	case got := <-after:
+		if got != event {
+			t.Errorf("got %+v, want the event delivered after listening again", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("no event after listening again")
+	}
+
+	cancel()
+	<-done
+}
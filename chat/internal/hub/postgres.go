@@ -0,0 +1,87 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const notifyChannel = "chat_events"
+
+// PostgresBus shares chat events between replicas with LISTEN/NOTIFY.
+// NOTIFY payloads are capped at 8000 bytes, so only the event type and message
+// ID are sent and every replica reloads the message through fetch.
+type PostgresBus struct {
+	listenConfig *pgx.ConnConfig
+	pool         *pgxpool.Pool
+	fetch        func(ctx context.Context, messageID string) (*models.ChatMessage, error)
+}
+
+// NewPostgresBus listens on a dedicated connection made from listenConfig,
+// outside the pool: waiting for notifications occupies the connection for as
+// long as the bus runs, and a LISTEN on a pooled connection would end when it
+// is released.
+func NewPostgresBus(listenConfig *pgx.ConnConfig, pool *pgxpool.Pool, fetch func(ctx context.Context, messageID string) (*models.ChatMessage, error)) *PostgresBus {
+	return &PostgresBus{
+		listenConfig: listenConfig,
+		pool:         pool,
+		fetch:        fetch,
+	}
+}
+
+type notification struct {
+	Type      models.ChatEventType `json:"type"`
+	MessageID string               `json:"messageId"`
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, event *models.ChatEvent) error {
+	payload, err := json.Marshal(notification{Type: event.Type, MessageID: event.Message.MessageID})
+	if err != nil {
+		return err
+	}
+
+	_, err = b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", notifyChannel, string(payload))
+	return err
+}
+
+// Listen connects afresh on every call and returns an error when the
+// connection is lost, so the hub can listen again.
+func (b *PostgresBus) Listen(ctx context.Context, deliver func(*models.ChatEvent)) error {
+	listenConn, err := pgx.ConnectConfig(ctx, b.listenConfig)
+	if err != nil {
+		return err
+	}
+	defer listenConn.Close(context.Background())
+
+	if _, err = listenConn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := listenConn.WaitForNotification(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+
+		var payload notification
+		if err = json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+			log.Printf("Failed to decode chat event notification: %v", err)
+			continue
+		}
+
+		msg, err := b.fetch(ctx, payload.MessageID)
+		if err != nil {
+			log.Printf("Failed to load notified message %s: %v", payload.MessageID, err)
+			continue
+		}
+		deliver(&models.ChatEvent{Type: payload.Type, Message: msg})
+	}
+}
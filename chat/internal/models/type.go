@@ -2,28 +2,108 @@ package models
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/HJyup/translatify-common/pagination"
+)
+
+var (
+	ErrNotParticipant = errors.New("sender and receiver must be the two participants of the chat")
+
+	ErrTranslationUnavailable    = errors.New("message is not translated: it is in the receiver's language or translation is disabled")
+	ErrInvalidTranslationOptions = errors.New("invalid style or provider")
+	ErrAlternativeNotFound       = errors.New("translation alternative not found")
+	ErrMessageTranslationMissing = errors.New("message has no translation in this language")
+	ErrTranslationNotReady       = errors.New("receiver's translation is not available")
+	ErrTranslationQuotaExceeded  = errors.New("translation quota exceeded")
 )
 
 type ChatService interface {
 	CreateChat(userNameA, userNameB, sourceLang, targetLang string) (string, error)
 	SendMessage(ctx context.Context, chatID, senderUserName, receiverUserName, content string) (string, error)
 	GetMessage(messageID string) (*ChatMessage, error)
-	ListMessages(chatID string, since *time.Time, limit int, pageToken string) ([]*ChatMessage, string, error)
-	StreamMessages(ctx context.Context, chatID string) (<-chan *ChatMessage, error)
+	ListMessages(chatID string, since *time.Time, limit int, pageToken string, direction pagination.Direction) ([]*ChatMessage, string, error)
+	StreamMessages(ctx context.Context, chatID string, afterSequence int64, since *time.Time) (<-chan *ChatEvent, error)
 	GetChat(chatID string) (*Chat, error)
 	ListChats(userName string) ([]*Chat, error)
-	UpdateMessageTranslation(messageID string, translatedContent string) error
+	UpdateMessageTranslation(ctx context.Context, update *TranslationUpdate) error
+	RetranslateMessage(ctx context.Context, messageID, style, provider, username string) (*ChatMessage, *TranslationAlternative, error)
+	ListTranslationAlternatives(ctx context.Context, messageID string) ([]*TranslationAlternative, error)
+	SelectTranslationAlternative(ctx context.Context, messageID, alternativeID string) (*ChatMessage, error)
+	GetMessageTranslation(ctx context.Context, messageID, language, username string) (*MessageTranslation, error)
 }
 
 type ChatStore interface {
 	CreateConversion(ctx context.Context, conv *Chat) (string, error)
-	AddMessage(ctx context.Context, msg *ChatMessage) (string, error)
+	AddMessage(ctx context.Context, msg *ChatMessage, translation *TranslationRequest) (string, error)
 	GetMessage(ctx context.Context, id string) (*ChatMessage, error)
-	ListMessages(ctx context.Context, chatID string, since *time.Time, limit int, pageToken string) ([]*ChatMessage, string, error)
+	ListMessages(ctx context.Context, chatID string, since *time.Time, limit int, cursor *pagination.Cursor, direction pagination.Direction) ([]*ChatMessage, *pagination.Cursor, error)
+	ReplayMessages(ctx context.Context, chatID string, afterSequence int64, since *time.Time, limit int) ([]*ChatMessage, error)
 	GetChat(ctx context.Context, id string) (*Chat, error)
 	ListChats(ctx context.Context, userName string) ([]*Chat, error)
-	UpdateMessageTranslation(ctx context.Context, messageID string, translatedContent string) error
+	UpdateMessageTranslation(ctx context.Context, update *TranslationUpdate) error
+	AddTranslationAlternative(ctx context.Context, alternative *TranslationAlternative) (string, error)
+	ListTranslationAlternatives(ctx context.Context, messageID string) ([]*TranslationAlternative, error)
+	SelectTranslationAlternative(ctx context.Context, messageID, alternativeID string) error
+	GetMessageTranslation(ctx context.Context, messageID, language string) (*MessageTranslation, error)
+	SaveMessageTranslation(ctx context.Context, translation *MessageTranslation) (*MessageTranslation, error)
+}
+
+type OutboxStore interface {
+	RelayOutbox(ctx context.Context, limit int, publish func(ctx context.Context, event *OutboxEvent) error) (int, error)
+}
+
+// Translator translates a message on request, outside the message.sent queue.
+type Translator interface {
+	Translate(ctx context.Context, req *TranslationRequest, opts TranslateOptions) (*Translation, error)
+}
+
+// TranslateOptions asks for a particular translation. SkipCache makes the
+// translation service ask its provider again.
+type TranslateOptions struct {
+	Style     string
+	Provider  string
+	SkipCache bool
+}
+
+type Translation struct {
+	Content  string
+	Provider string
+	Model    string
+}
+
+type LanguageDetector interface {
+	DetectLanguage(ctx context.Context, content, chatID, username string) (*Detection, error)
+}
+
+type Detection struct {
+	Language   string
+	Confidence float64
+	Mixed      bool
+}
+
+type MessageHub interface {
+	Publish(ctx context.Context, event *ChatEvent) error
+	Subscribe(chatID string) (<-chan *ChatEvent, func())
+}
+
+type MessageBus interface {
+	Publish(ctx context.Context, event *ChatEvent) error
+	Listen(ctx context.Context, deliver func(*ChatEvent)) error
+}
+
+type ChatEventType string
+
+const (
+	MessageCreated    ChatEventType = "message.created"
+	MessageTranslated ChatEventType = "message.translated"
+	MessageEdited     ChatEventType = "message.edited"
+)
+
+type ChatEvent struct {
+	Type    ChatEventType
+	Message *ChatMessage
 }
 
 type ChatMessage struct {
@@ -34,7 +114,70 @@ type ChatMessage struct {
 	Content           string
 	TranslatedContent string
 	Timestamp         time.Time
+	Sequence          int64
+	DetectedLanguage  string
+	MixedLanguage     bool
+	// SourceLanguage is the language the message was translated from: the
+	// sender's, or a confident detection that overrode it.
+	SourceLanguage string
+
+	TranslationStatus   TranslationStatus
+	TranslationError    string
+	TranslationProvider string
+	TranslationModel    string
+	// TranslatedAt is zero until a translation is stored.
+	TranslatedAt time.Time
+	// TranslationAlternativeID is the alternative shown as TranslatedContent.
+	TranslationAlternativeID string
+}
+
+// TranslationAlternative is one of the translations a message received. The
+// first comes from the translation queue, later ones from retranslations.
+type TranslationAlternative struct {
+	AlternativeID     string
+	MessageID         string
+	TranslatedContent string
+	Style             string
+	Provider          string
+	Model             string
+	CreatedAt         time.Time
+}
+
+type TranslationStatus string
+
+const (
+	TranslationPending  TranslationStatus = "pending"
+	TranslationDone     TranslationStatus = "done"
+	TranslationFailed   TranslationStatus = "failed"
+	TranslationSkipped  TranslationStatus = "skipped"
+	TranslationDisabled TranslationStatus = "disabled"
+)
+
+// MessageTranslation is a message rendered in one language. In the message's
+// own language it is the original content, without provider.
+type MessageTranslation struct {
+	MessageID         string
+	Language          string
+	TranslatedContent string
+	Provider          string
+	Model             string
+	CreatedAt         time.Time
+}
+
+// TranslationUpdate is the outcome of a translation reported by the
+// translation service. Only the first one is displayed; later successful
+// updates are kept as alternatives.
+type TranslationUpdate struct {
+	MessageID         string
+	Status            TranslationStatus
+	TranslatedContent string
+	Provider          string
+	Model             string
+	Error             string
 }
+
+// Chat stores one language per participant: SourceLang is UsernameA's and
+// TargetLang is UsernameB's.
 type Chat struct {
 	ChatID     string
 	UsernameA  string
@@ -44,8 +187,50 @@ type Chat struct {
 	TargetLang string
 }
 
-type ConsumerResponse struct {
-	MessageId         string `json:"messageId"`
-	TranslatedContent string `json:"translatedContent"`
-	Success           bool   `json:"Success"`
+// LanguageOf returns the language of a participant, or false if username is
+// not part of the chat.
+func (c *Chat) LanguageOf(username string) (string, bool) {
+	switch username {
+	case c.UsernameA:
+		return c.SourceLang, true
+	case c.UsernameB:
+		return c.TargetLang, true
+	default:
+		return "", false
+	}
+}
+
+// TranslationRequest asks for the translation of a message, either right away
+// or as the message.sent event of the outbox. ChatID and the usernames select
+// the glossaries that apply. Username is who asked for it and is billed for
+// it; the translation service bills the sender when it is empty.
+type TranslationRequest struct {
+	MessageID        string
+	ChatID           string
+	SenderUsername   string
+	ReceiverUsername string
+	Username         string
+	Content          string
+	SourceLang       string
+	TargetLang       string
+	Context          []ContextMessage
+}
+
+// ContextMessage is an earlier message of the chat, sent along so short or
+// referring replies translate correctly.
+type ContextMessage struct {
+	Sender  string
+	Content string
+}
+
+// OutboxEvent is published to the exchange named by Topic with RoutingKey.
+// TraceContext holds the trace of the request that wrote it.
+type OutboxEvent struct {
+	ID           int64
+	Topic        string
+	RoutingKey   string
+	Payload      []byte
+	TraceContext map[string]string
+	CreatedAt    time.Time
+	Attempts     int
 }
@@ -0,0 +1,88 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+	"github.com/HJyup/translatify-common/broker"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	DefaultInterval  = time.Second
+	DefaultBatchSize = 100
+)
+
+// Relay publishes outbox events to the exchange named by their topic. An
+// event is only marked as published after the broker confirmed it, so
+// delivery is at-least-once and consumers must tolerate duplicates.
+type Relay struct {
+	store     models.OutboxStore
+	channel   *amqp.Channel
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRelay puts channel into confirm mode, so it should not be shared with
+// other publishers.
+func NewRelay(store models.OutboxStore, channel *amqp.Channel, interval time.Duration, batchSize int) (*Relay, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, err
+	}
+	return &Relay{
+		store:     store,
+		channel:   channel,
+		interval:  interval,
+		batchSize: batchSize,
+	}, nil
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back, then wait for the next tick.
+		published, err := r.store.RelayOutbox(ctx, r.batchSize, r.publish)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to relay outbox: %v", err)
+		}
+		if err == nil && published == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publish continues the trace of the request that wrote event; the producer
+// span lasts until the broker confirmed it.
+func (r *Relay) publish(ctx context.Context, event *models.OutboxEvent) (err error) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(event.TraceContext))
+	publishing := broker.Publishing(event.Payload)
+	ctx, span := broker.StartPublish(ctx, event.Topic, event.RoutingKey, &publishing)
+	defer func() { broker.EndSpan(span, err) }()
+
+	confirmation, err := r.channel.PublishWithDeferredConfirmWithContext(ctx, event.Topic, event.RoutingKey, false, false, publishing)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("broker rejected outbox event %d", event.ID)
+	}
+	return nil
+}
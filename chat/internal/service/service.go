@@ -3,18 +3,51 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/HJyup/translatify-chat/internal/models"
+	"github.com/HJyup/translatify-common/pagination"
+)
+
+const (
+	replayPageSize   = 100
+	maxPendingEvents = 1000
+	defaultPageSize  = 50
+
+	detectionTimeout       = 2 * time.Second
+	minDetectionConfidence = 0.6
 )
 
 type Service struct {
-	store models.ChatStore
+	store      models.ChatStore
+	hub        models.MessageHub
+	tokens     *pagination.Codec
+	detector   models.LanguageDetector
+	translator models.Translator
+
+	// contextSize is how many earlier messages travel with a translation
+	// request; zero disables conversation context.
+	contextSize int
+	// translationEnabled turns off sending messages for translation, so they
+	// are stored with the disabled status.
+	translationEnabled bool
 }
 
-func NewService(store models.ChatStore) *Service {
-	return &Service{store: store}
+func NewService(store models.ChatStore, hub models.MessageHub, tokens *pagination.Codec, detector models.LanguageDetector, translator models.Translator, contextSize int, translationEnabled bool) *Service {
+	return &Service{
+		store:              store,
+		hub:                hub,
+		tokens:             tokens,
+		detector:           detector,
+		translator:         translator,
+		contextSize:        contextSize,
+		translationEnabled: translationEnabled,
+	}
 }
 
 func (s *Service) CreateChat(userA, userB, sourceLang, targetLang string) (string, error) {
@@ -46,6 +79,17 @@ func (s *Service) SendMessage(ctx context.Context, chatID, senderUsername, recei
 		return "", errors.New("fromUsername, toUsername, and content are required")
 	}
 
+	chat, err := s.store.GetChat(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	senderLang, isSender := chat.LanguageOf(senderUsername)
+	receiverLang, isReceiver := chat.LanguageOf(receiverUsername)
+	if !isSender || !isReceiver || senderUsername == receiverUsername {
+		return "", models.ErrNotParticipant
+	}
+
 	now := time.Now()
 
 	msg := &models.ChatMessage{
@@ -57,14 +101,96 @@ func (s *Service) SendMessage(ctx context.Context, chatID, senderUsername, recei
 		Timestamp:         now,
 	}
 
-	messageID, err := s.store.AddMessage(ctx, msg)
+	// A confident, single-language detection overrides the sender's declared
+	// language, so messages already written in the recipient's language are
+	// not translated. Mixed messages keep the declared language.
+	sourceLang := senderLang
+	if detection := s.detectLanguage(ctx, chatID, senderUsername, content); detection != nil {
+		msg.DetectedLanguage = detection.Language
+		msg.MixedLanguage = detection.Mixed
+		if detection.Language != "" && !detection.Mixed && detection.Confidence >= minDetectionConfidence {
+			sourceLang = detection.Language
+		}
+	}
+	msg.SourceLanguage = sourceLang
+
+	var translation *models.TranslationRequest
+	switch {
+	case !s.translationEnabled:
+		msg.TranslationStatus = models.TranslationDisabled
+	case sameLanguage(sourceLang, receiverLang):
+		msg.TranslationStatus = models.TranslationSkipped
+	default:
+		msg.TranslationStatus = models.TranslationPending
+		translation = &models.TranslationRequest{
+			ChatID:           chatID,
+			SenderUsername:   senderUsername,
+			ReceiverUsername: receiverUsername,
+			Content:          content,
+			SourceLang:       sourceLang,
+			TargetLang:       receiverLang,
+			Context:          s.conversationContext(ctx, chatID),
+		}
+	}
+
+	messageID, err := s.store.AddMessage(ctx, msg, translation)
 	if err != nil {
 		return "", err
 	}
+	msg.MessageID = messageID
+
+	if err = s.hub.Publish(ctx, &models.ChatEvent{Type: models.MessageCreated, Message: msg}); err != nil {
+		log.Printf("Failed to publish message %s to subscribers: %v", messageID, err)
+	}
 
 	return messageID, nil
 }
 
+// detectLanguage is best effort: a slow or failing detector must not block
+// sending, so errors are logged and reported as no detection.
+func (s *Service) detectLanguage(ctx context.Context, chatID, username, content string) *models.Detection {
+	ctx, cancel := context.WithTimeout(ctx, detectionTimeout)
+	defer cancel()
+
+	detection, err := s.detector.DetectLanguage(ctx, content, chatID, username)
+	if err != nil {
+		log.Printf("Failed to detect message language: %v", err)
+		return nil
+	}
+	return detection
+}
+
+// conversationContext returns the latest messages of the chat, oldest first.
+// It is best effort: without context the message is still translated.
+func (s *Service) conversationContext(ctx context.Context, chatID string) []models.ContextMessage {
+	if s.contextSize <= 0 {
+		return nil
+	}
+
+	messages, _, err := s.store.ListMessages(ctx, chatID, nil, s.contextSize, nil, pagination.Backward)
+	if err != nil {
+		log.Printf("Failed to load conversation context of chat %s: %v", chatID, err)
+		return nil
+	}
+
+	history := make([]models.ContextMessage, len(messages))
+	for i, msg := range messages {
+		history[i] = models.ContextMessage{Sender: msg.SenderUsername, Content: msg.Content}
+	}
+	return history
+}
+
+// sameLanguage compares language codes by their primary subtag, so "en" and
+// "en-GB" match.
+func sameLanguage(a, b string) bool {
+	base := func(code string) string {
+		code, _, _ = strings.Cut(strings.ToLower(code), "-")
+		code, _, _ = strings.Cut(code, "_")
+		return code
+	}
+	return base(a) == base(b)
+}
+
 func (s *Service) GetMessage(messageID string) (*models.ChatMessage, error) {
 	if messageID == "" {
 		return nil, errors.New("message id is required")
@@ -72,45 +198,147 @@ func (s *Service) GetMessage(messageID string) (*models.ChatMessage, error) {
 	return s.store.GetMessage(context.Background(), messageID)
 }
 
-func (s *Service) ListMessages(chatID string, since *time.Time, limit int, pageToken string) ([]*models.ChatMessage, string, error) {
+func (s *Service) ListMessages(chatID string, since *time.Time, limit int, pageToken string, direction pagination.Direction) ([]*models.ChatMessage, string, error) {
 	if chatID == "" {
 		return nil, "", errors.New("chatID is required")
 	}
-	return s.store.ListMessages(context.Background(), chatID, since, limit, pageToken)
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	cursor, err := s.tokens.Decode(pageToken, direction)
+	if err != nil {
+		return nil, "", err
+	}
+
+	messages, next, err := s.store.ListMessages(context.Background(), chatID, since, limit, cursor, direction)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return messages, s.tokens.Encode(next, direction), nil
 }
 
-func (s *Service) StreamMessages(ctx context.Context, chatID string) (<-chan *models.ChatMessage, error) {
+// StreamMessages subscribes to live events before replaying anything the
+// client missed, so a message committed while the replay runs is seen either
+// in the replay or live. Messages are sent in seq order without gaps: a live
+// message that skips ahead, because the one before it was published later,
+// is preceded by a replay of the gap, and the late event is then dropped as a
+// duplicate. A client can therefore always resume after the highest seq it
+// saw. Live events are drained into a local queue while the client reads, so
+// a long replay does not overflow the hub's buffer; a client that falls more
+// than maxPendingEvents behind is dropped and resumes with its cursor.
+func (s *Service) StreamMessages(ctx context.Context, chatID string, afterSequence int64, since *time.Time) (<-chan *models.ChatEvent, error) {
 	if chatID == "" {
 		return nil, errors.New("chatID is required")
 	}
 
-	out := make(chan *models.ChatMessage)
-	startTime := time.Now()
+	eventCh, unsubscribe := s.hub.Subscribe(chatID)
+	out := make(chan *models.ChatEvent)
 
 	go func() {
 		defer close(out)
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
+		defer unsubscribe()
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				messages, _, err := s.store.ListMessages(context.Background(), chatID, &startTime, 100, "")
+		var pending []*models.ChatEvent
+		live := eventCh
+		send := func(event *models.ChatEvent) bool {
+			for {
+				select {
+				case <-ctx.Done():
+					return false
+				case out <- event:
+					return true
+				case e, ok := <-live:
+					if !ok {
+						live = nil
+						continue
+					}
+					if len(pending) >= maxPendingEvents {
+						log.Printf("Dropping slow stream of chat %s", chatID)
+						return false
+					}
+					pending = append(pending, e)
+				}
+			}
+		}
+
+		// cursor is the seq up to which every message has been sent. A
+		// stream without one starts at the first live message; anything
+		// below that floor arriving later was never sent and goes out as is.
+		cursor, floor := afterSequence, int64(0)
+		started := afterSequence > 0 || since != nil
+		replay := func(until int64) bool {
+			for {
+				messages, err := s.store.ReplayMessages(ctx, chatID, cursor, since, replayPageSize)
 				if err != nil {
-					continue
+					log.Printf("Failed to replay messages of chat %s: %v", chatID, err)
+					return false
 				}
 
 				for _, msg := range messages {
-					select {
-					case <-ctx.Done():
-						return
-					case out <- msg:
+					if !send(&models.ChatEvent{Type: models.MessageCreated, Message: msg}) {
+						return false
 					}
+					cursor = msg.Sequence
+				}
+
+				if len(messages) < replayPageSize || until > 0 && cursor >= until {
+					return true
+				}
+			}
+		}
+		deliver := func(event *models.ChatEvent) bool {
+			if event.Type != models.MessageCreated {
+				return send(event)
+			}
+
+			seq := event.Message.Sequence
+			switch {
+			case !started:
+				started, cursor, floor = true, seq, seq
+			case seq < floor:
+				return send(event)
+			case seq <= cursor:
+				return true
+			case seq > cursor+1:
+				if !replay(seq) {
+					return false
+				}
+				if seq <= cursor {
+					return true
+				}
+				cursor = seq
+			default:
+				cursor = seq
+			}
+			return send(event)
+		}
+
+		if started && !replay(0) {
+			return
+		}
+
+		for {
+			for len(pending) > 0 {
+				event := pending[0]
+				pending = pending[1:]
+				if !deliver(event) {
+					return
 				}
+			}
+			if live == nil {
+				return
+			}
 
-				startTime = time.Now()
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-live:
+				if !ok {
+					return
+				}
+				pending = append(pending, event)
 			}
 		}
 	}()
@@ -132,10 +360,192 @@ func (s *Service) ListChats(userName string) ([]*models.Chat, error) {
 	return s.store.ListChats(context.Background(), userName)
 }
 
-func (s *Service) UpdateMessageTranslation(messageID string, translatedContent string) error {
-	if messageID == "" {
+func (s *Service) UpdateMessageTranslation(ctx context.Context, update *models.TranslationUpdate) error {
+	ctx, span := otel.Tracer("chat-service").Start(ctx, "UpdateMessageTranslation")
+	span.SetAttributes(attribute.String("messageID", update.MessageID))
+	defer span.End()
+
+	if update.MessageID == "" {
 		return errors.New("messageID is empty for updating translation")
 	}
+	if update.Status != models.TranslationDone && update.Status != models.TranslationFailed {
+		return fmt.Errorf("unexpected translation status %q", update.Status)
+	}
+
+	if err := s.store.UpdateMessageTranslation(ctx, update); err != nil {
+		return err
+	}
+
+	_, err := s.publishTranslation(ctx, update.MessageID)
+	return err
+}
+
+// RetranslateMessage translates a message again, skipping the translation
+// cache, and displays the result. Earlier translations stay available as
+// alternatives. The conversation context is not sent: the latest messages may
+// postdate the one being retranslated.
+func (s *Service) RetranslateMessage(ctx context.Context, messageID, style, provider, username string) (*models.ChatMessage, *models.TranslationAlternative, error) {
+	if messageID == "" {
+		return nil, nil, errors.New("messageID is required")
+	}
+
+	msg, err := s.store.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.translationEnabled || msg.TranslationStatus == models.TranslationSkipped || msg.TranslationStatus == models.TranslationDisabled {
+		return nil, nil, models.ErrTranslationUnavailable
+	}
+
+	chat, err := s.store.GetChat(ctx, msg.ChatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	sourceLang, receiverLang := messageLanguages(chat, msg)
+
+	translation, err := s.translator.Translate(ctx, &models.TranslationRequest{
+		MessageID:        msg.MessageID,
+		ChatID:           msg.ChatID,
+		SenderUsername:   msg.SenderUsername,
+		ReceiverUsername: msg.ReceiverUsername,
+		Username:         username,
+		Content:          msg.Content,
+		SourceLang:       sourceLang,
+		TargetLang:       receiverLang,
+	}, models.TranslateOptions{Style: style, Provider: provider, SkipCache: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alternative := &models.TranslationAlternative{
+		MessageID:         msg.MessageID,
+		TranslatedContent: translation.Content,
+		Style:             style,
+		Provider:          translation.Provider,
+		Model:             translation.Model,
+	}
+	if alternative.AlternativeID, err = s.store.AddTranslationAlternative(ctx, alternative); err != nil {
+		return nil, nil, err
+	}
+
+	msg, err = s.publishTranslation(ctx, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, alternative, nil
+}
+
+// messageLanguages returns the source language the message was sent with
+// and the receiver's language, so later translations match the first one.
+func messageLanguages(chat *models.Chat, msg *models.ChatMessage) (string, string) {
+	sourceLang := msg.SourceLanguage
+	if sourceLang == "" {
+		sourceLang, _ = chat.LanguageOf(msg.SenderUsername)
+	}
+	receiverLang, _ := chat.LanguageOf(msg.ReceiverUsername)
+	return sourceLang, receiverLang
+}
+
+// GetMessageTranslation renders a message in any language. The original and
+// the receiver's translation come from the message itself, so the latter
+// follows its status and selected alternative; other languages are
+// translated on first request and stored for later ones.
+func (s *Service) GetMessageTranslation(ctx context.Context, messageID, language, username string) (*models.MessageTranslation, error) {
+	if messageID == "" || language == "" {
+		return nil, errors.New("messageID and language are required")
+	}
+	language = strings.ToLower(language)
+
+	msg, err := s.store.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	chat, err := s.store.GetChat(ctx, msg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceLang, receiverLang := messageLanguages(chat, msg)
+	switch {
+	case sameLanguage(language, sourceLang):
+		return &models.MessageTranslation{
+			MessageID:         msg.MessageID,
+			Language:          language,
+			TranslatedContent: msg.Content,
+			CreatedAt:         msg.Timestamp,
+		}, nil
+	case sameLanguage(language, receiverLang):
+		if msg.TranslationStatus != models.TranslationDone {
+			return nil, fmt.Errorf("%w: translation is %s", models.ErrTranslationNotReady, msg.TranslationStatus)
+		}
+		return &models.MessageTranslation{
+			MessageID:         msg.MessageID,
+			Language:          language,
+			TranslatedContent: msg.TranslatedContent,
+			Provider:          msg.TranslationProvider,
+			Model:             msg.TranslationModel,
+			CreatedAt:         msg.TranslatedAt,
+		}, nil
+	}
+
+	stored, err := s.store.GetMessageTranslation(ctx, messageID, language)
+	if !errors.Is(err, models.ErrMessageTranslationMissing) {
+		return stored, err
+	}
+	if !s.translationEnabled {
+		return nil, models.ErrTranslationUnavailable
+	}
 
-	return s.store.UpdateMessageTranslation(context.Background(), messageID, translatedContent)
+	translation, err := s.translator.Translate(ctx, &models.TranslationRequest{
+		MessageID:        msg.MessageID,
+		ChatID:           msg.ChatID,
+		SenderUsername:   msg.SenderUsername,
+		ReceiverUsername: msg.ReceiverUsername,
+		Username:         username,
+		Content:          msg.Content,
+		SourceLang:       sourceLang,
+		TargetLang:       language,
+	}, models.TranslateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.SaveMessageTranslation(ctx, &models.MessageTranslation{
+		MessageID:         msg.MessageID,
+		Language:          language,
+		TranslatedContent: translation.Content,
+		Provider:          translation.Provider,
+		Model:             translation.Model,
+	})
+}
+
+func (s *Service) ListTranslationAlternatives(ctx context.Context, messageID string) ([]*models.TranslationAlternative, error) {
+	if messageID == "" {
+		return nil, errors.New("messageID is required")
+	}
+	return s.store.ListTranslationAlternatives(ctx, messageID)
+}
+
+func (s *Service) SelectTranslationAlternative(ctx context.Context, messageID, alternativeID string) (*models.ChatMessage, error) {
+	if messageID == "" || alternativeID == "" {
+		return nil, errors.New("messageID and alternativeID are required")
+	}
+	if err := s.store.SelectTranslationAlternative(ctx, messageID, alternativeID); err != nil {
+		return nil, err
+	}
+	return s.publishTranslation(ctx, messageID)
+}
+
+// publishTranslation tells subscribers that the displayed translation of a
+// message changed and returns the updated message.
+func (s *Service) publishTranslation(ctx context.Context, messageID string) (*models.ChatMessage, error) {
+	msg, err := s.store.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.hub.Publish(ctx, &models.ChatEvent{Type: models.MessageTranslated, Message: msg}); err != nil {
+		log.Printf("Failed to publish translation of message %s to subscribers: %v", messageID, err)
+	}
+	return msg, nil
 }
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+)
+
+// replayStore holds the messages of one chat in seq order.
+type replayStore struct {
+	models.ChatStore
+	messages []*models.ChatMessage
+}
+
+func (s *replayStore) ReplayMessages(ctx context.Context, chatID string, afterSequence int64, since *time.Time, limit int) ([]*models.ChatMessage, error) {
+	var page []*models.ChatMessage
+	for _, msg := range s.messages {
+		if msg.Sequence > afterSequence && len(page) < limit {
+			page = append(page, msg)
+		}
+	}
+	return page, nil
+}
+
+// liveHub hands out an unbuffered channel, so every publish waits until the
+// stream has taken the event.
+type liveHub struct {
+	ch chan *models.ChatEvent
+}
+
+func (h *liveHub) Publish(ctx context.Context, event *models.ChatEvent) error {
+	h.ch <- event
+	return nil
+}
+
+func (h *liveHub) Subscribe(chatID string) (<-chan *models.ChatEvent, func()) {
+	return h.ch, func() {}
+}
+
+func TestStreamMessagesReplaysThenGoesLive(t *testing.T) {
+	message := func(seq int64) *models.ChatMessage {
+		return &models.ChatMessage{MessageID: fmt.Sprintf("m%d", seq), ChatID: "c1", Sequence: seq}
+	}
+	store := &replayStore{}
+	for seq := int64(1); seq <= 200; seq++ {
+		store.messages = append(store.messages, message(seq))
+	}
+	hub := &liveHub{ch: make(chan *models.ChatEvent)}
+	srv := NewService(store, hub, nil, nil, nil, 0, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out, err := srv.StreamMessages(ctx, "c1", 50, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing has been read yet, so these arrive while the replay is waiting
+	// on the client.
+	live := []*models.ChatEvent{
+		{Type: models.MessageCreated, Message: message(60)},
+		{Type: models.MessageTranslated, Message: message(60)},
+		{Type: models.MessageCreated, Message: message(201)},
+	}
+	for _, event := range live {
+		select {
+		case hub.ch <- event:
+		case <-time.After(time.Second):
+			t.Fatal("live event was not taken during the replay")
+		}
+	}
+	close(hub.ch)
+
+	var want, got []string
+	for seq := 51; seq <= 200; seq++ {
+		want = append(want, fmt.Sprintf("%s m%d", models.MessageCreated, seq))
+	}
+	want = append(want, fmt.Sprintf("%s m60", models.MessageTranslated), fmt.Sprintf("%s m201", models.MessageCreated))
+	for event := range out {
+		got = append(got, fmt.Sprintf("%s %s", event.Type, event.Message.MessageID))
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stream = %v, want %v", got, want)
+	}
+}
+
+func TestStreamMessagesFillsGapsInSeqOrder(t *testing.T) {
+	message := func(seq int64) *models.ChatMessage {
+		return &models.ChatMessage{MessageID: fmt.Sprintf("m%d", seq), ChatID: "c1", Sequence: seq}
+	}
+	store := &replayStore{}
+	for seq := int64(1); seq <= 7; seq++ {
+		store.messages = append(store.messages, message(seq))
+	}
+	hub := &liveHub{ch: make(chan *models.ChatEvent)}
+	srv := NewService(store, hub, nil, nil, nil, 0, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out, err := srv.StreamMessages(ctx, "c1", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// m7 overtakes m6, whose event arrives late; m4 was sent before the
+	// stream started, but its event only arrives now.
+	go func() {
+		for _, seq := range []int64{5, 7, 6, 4, 8} {
+			hub.ch <- &models.ChatEvent{Type: models.MessageCreated, Message: message(seq)}
+		}
+		close(hub.ch)
+	}()
+
+	var got []string
+	for event := range out {
+		got = append(got, event.Message.MessageID)
+	}
+	if want := []string{"m5", "m6", "m7", "m4", "m8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stream = %v, want %v", got, want)
+	}
+}
+
+func TestMessageLanguages(t *testing.T) {
+	chat := &models.Chat{UsernameA: "alice", UsernameB: "bob", SourceLang: "en", TargetLang: "ja"}
+
+	tests := []struct {
+		name         string
+		msg          *models.ChatMessage
+		wantSource   string
+		wantReceiver string
+	}{
+		{
+			name:         "stored source language",
+			msg:          &models.ChatMessage{SenderUsername: "alice", ReceiverUsername: "bob", SourceLanguage: "de", DetectedLanguage: "fr"},
+			wantSource:   "de",
+			wantReceiver: "ja",
+		},
+		{
+			name:         "weak detection was not used",
+			msg:          &models.ChatMessage{SenderUsername: "bob", ReceiverUsername: "alice", SourceLanguage: "ja", DetectedLanguage: "zh"},
+			wantSource:   "ja",
+			wantReceiver: "en",
+		},
+		{
+			name:         "nothing stored",
+			msg:          &models.ChatMessage{SenderUsername: "bob", ReceiverUsername: "alice", DetectedLanguage: "zh"},
+			wantSource:   "ja",
+			wantReceiver: "en",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, receiver := messageLanguages(chat, tt.msg)
+			if source != tt.wantSource || receiver != tt.wantReceiver {
+				t.Errorf("messageLanguages = %s, %s, want %s, %s", source, receiver, tt.wantSource, tt.wantReceiver)
+			}
+		})
+	}
+}
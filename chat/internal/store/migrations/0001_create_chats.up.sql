@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS chats (
+    chat_id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username_a      TEXT NOT NULL,
+    username_b      TEXT NOT NULL,
+    created_at      BIGINT NOT NULL,
+    source_language TEXT NOT NULL,
+    target_language TEXT NOT NULL
+);
+
+-- ListChats matches a user on either side of the chat.
+CREATE INDEX IF NOT EXISTS chats_username_a_idx ON chats (username_a);
+CREATE INDEX IF NOT EXISTS chats_username_b_idx ON chats (username_b);
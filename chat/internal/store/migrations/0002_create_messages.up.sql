@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS messages (
+    message_id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    chat_id            UUID NOT NULL REFERENCES chats (chat_id) ON DELETE CASCADE,
+    sender_username    TEXT NOT NULL,
+    receiver_username  TEXT NOT NULL,
+    content            TEXT NOT NULL,
+    translated_content TEXT NOT NULL DEFAULT '',
+    timestamp          BIGINT NOT NULL
+);
+
+-- ListMessages filters by chat and pages in timestamp order.
+CREATE INDEX IF NOT EXISTS messages_chat_id_timestamp_idx ON messages (chat_id, timestamp, message_id);
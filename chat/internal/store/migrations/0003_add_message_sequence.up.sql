@@ -0,0 +1,4 @@
+-- A monotonic position per message, used as the StreamMessages resume cursor.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS seq BIGINT GENERATED ALWAYS AS IDENTITY;
+
+CREATE UNIQUE INDEX IF NOT EXISTS messages_chat_id_seq_idx ON messages (chat_id, seq);
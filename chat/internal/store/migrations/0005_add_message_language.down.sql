@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS mixed_language;
+ALTER TABLE messages DROP COLUMN IF EXISTS detected_language;
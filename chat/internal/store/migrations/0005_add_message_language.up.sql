@@ -0,0 +1,3 @@
+-- Language detected for each message at send time; empty if detection failed.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS detected_language TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS mixed_language BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS translated_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS translation_model;
+ALTER TABLE messages DROP COLUMN IF EXISTS translation_provider;
+ALTER TABLE messages DROP COLUMN IF EXISTS translation_error;
+ALTER TABLE messages DROP COLUMN IF EXISTS translation_status;
@@ -0,0 +1,26 @@
+-- Translation lifecycle of each message: pending, done, failed, skipped or disabled.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS translation_status TEXT NOT NULL DEFAULT 'pending';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS translation_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS translation_provider TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS translation_model TEXT NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS translated_at BIGINT NOT NULL DEFAULT 0;
+
+-- Older messages only tell whether a translation arrived.
+UPDATE messages SET translation_status = 'done' WHERE translated_content <> '';
+
+-- The rest were either written in the recipient's language, and never sent
+-- for translation, or lost theirs. A translation still in flight replaces the
+-- failure when it arrives.
+WITH languages AS (
+    SELECT m.message_id,
+           split_part(split_part(lower(CASE WHEN m.sender_username = c.username_a THEN c.source_language ELSE c.target_language END), '-', 1), '_', 1) AS source,
+           split_part(split_part(lower(CASE WHEN m.receiver_username = c.username_a THEN c.source_language ELSE c.target_language END), '-', 1), '_', 1) AS target
+    FROM messages m
+    JOIN chats c ON c.chat_id = m.chat_id
+    WHERE m.translated_content = ''
+)
+UPDATE messages m
+SET translation_status = CASE WHEN l.source = l.target THEN 'skipped' ELSE 'failed' END,
+    translation_error  = CASE WHEN l.source = l.target THEN '' ELSE 'no translation was recorded' END
+FROM languages l
+WHERE m.message_id = l.message_id;
@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS translation_alternative_id;
+DROP TABLE IF EXISTS translation_alternatives;
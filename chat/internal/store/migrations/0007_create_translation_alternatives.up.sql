@@ -0,0 +1,26 @@
+-- Every translation a message received, including retranslations on request.
+CREATE TABLE IF NOT EXISTS translation_alternatives (
+    alternative_id     UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id         UUID NOT NULL REFERENCES messages (message_id) ON DELETE CASCADE,
+    translated_content TEXT NOT NULL,
+    style              TEXT NOT NULL DEFAULT '',
+    provider           TEXT NOT NULL DEFAULT '',
+    model              TEXT NOT NULL DEFAULT '',
+    created_at         BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS translation_alternatives_message_id_idx ON translation_alternatives (message_id, created_at);
+
+-- The alternative shown as translated_content.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS translation_alternative_id UUID;
+
+INSERT INTO translation_alternatives (message_id, translated_content, provider, model, created_at)
+SELECT message_id, translated_content, translation_provider, translation_model,
+       CASE WHEN translated_at > 0 THEN translated_at ELSE timestamp END
+FROM messages
+WHERE translation_status = 'done' AND translation_alternative_id IS NULL;
+
+UPDATE messages m
+SET translation_alternative_id = a.alternative_id
+FROM translation_alternatives a
+WHERE a.message_id = m.message_id AND m.translation_alternative_id IS NULL;
@@ -0,0 +1,11 @@
+-- Translations into languages other than the receiver's, made on first request.
+-- The receiver's translation stays on messages with its status and alternatives.
+CREATE TABLE IF NOT EXISTS message_translations (
+    message_id         UUID NOT NULL REFERENCES messages (message_id) ON DELETE CASCADE,
+    language           TEXT NOT NULL,
+    translated_content TEXT NOT NULL,
+    provider           TEXT NOT NULL DEFAULT '',
+    model              TEXT NOT NULL DEFAULT '',
+    created_at         BIGINT NOT NULL,
+    PRIMARY KEY (message_id, language)
+);
@@ -0,0 +1,3 @@
+-- The key an event is published with on its topic exchange. Rows written
+-- before keep the empty key, which bindings on "#" still match.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS routing_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- The trace context of the request that wrote the event, so the published
+-- event continues its trace.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS trace_context JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,9 @@
+-- The source language each message was translated from, so retranslations
+-- and other languages use the same one.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS source_language TEXT NOT NULL DEFAULT '';
+
+-- Older messages were only known to be sent in the sender's language.
+UPDATE messages m
+SET source_language = CASE WHEN m.sender_username = c.username_a THEN c.source_language ELSE c.target_language END
+FROM chats c
+WHERE c.chat_id = m.chat_id AND m.source_language = '';
@@ -2,22 +2,37 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"strconv"
+	"go.opentelemetry.io/otel/propagation"
+	"slices"
 	"time"
 
 	"github.com/HJyup/translatify-chat/internal/models"
+	"github.com/HJyup/translatify-common/broker"
+	"github.com/HJyup/translatify-common/pagination"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// execer is satisfied by both the pool and a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// producer names the chat service in the envelopes of its outbox events.
+const producer = "chat"
+
 type Store struct {
-	dbConn *pgx.Conn
+	db *pgxpool.Pool
 }
 
-func NewStore(dbConn *pgx.Conn) *Store {
-	return &Store{dbConn: dbConn}
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{db: db}
 }
 
 func (s *Store) CreateConversion(ctx context.Context, conv *models.Chat) (string, error) {
@@ -30,7 +45,7 @@ func (s *Store) CreateConversion(ctx context.Context, conv *models.Chat) (string
 	now := time.Now()
 	conv.CreatedAt = now
 	var chatID string
-	err := s.dbConn.QueryRow(ctx, query,
+	err := s.db.QueryRow(ctx, query,
 		conv.UsernameA,
 		conv.UsernameB,
 		now.Unix(),
@@ -43,65 +58,142 @@ func (s *Store) CreateConversion(ctx context.Context, conv *models.Chat) (string
 	return chatID, nil
 }
 
-func (s *Store) AddMessage(ctx context.Context, msg *models.ChatMessage) (string, error) {
+// AddMessage inserts msg and, when translation is set, the matching outbox
+// event in one transaction, so a stored message is never left untranslated.
+func (s *Store) AddMessage(ctx context.Context, msg *models.ChatMessage, translation *models.TranslationRequest) (string, error) {
 	ctx, span := otel.Tracer("chat-store").Start(ctx, "AddMessage")
 	span.SetAttributes(attribute.String("chatID", msg.ChatID))
 	defer span.End()
 
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	// seq is drawn at insert but becomes visible at commit. Serialising the
+	// inserts of a chat makes its messages commit in seq order, so a reader
+	// resuming after a seq never misses a later commit with a lower one.
+	if _, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, msg.ChatID); err != nil {
+		return "", err
+	}
+
 	query := `
 		INSERT INTO messages
-			(chat_id, sender_username, receiver_username, content, translated_content, timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING message_id
+			(chat_id, sender_username, receiver_username, content, translated_content, timestamp, detected_language, mixed_language, source_language, translation_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING message_id, seq
 	`
 	now := time.Now()
 	msg.Timestamp = now
 	var messageID string
-	err := s.dbConn.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		msg.ChatID,
 		msg.SenderUsername,
 		msg.ReceiverUsername,
 		msg.Content,
-		"",
+		msg.TranslatedContent,
 		now.Unix(),
-	).Scan(&messageID)
+		msg.DetectedLanguage,
+		msg.MixedLanguage,
+		msg.SourceLanguage,
+		msg.TranslationStatus,
+	).Scan(&messageID, &msg.Sequence)
 	if err != nil {
 		return "", err
 	}
+
+	if translation != nil {
+		translation.MessageID = messageID
+		payload, err := json.Marshal(broker.NewEnvelope(broker.Meta{Producer: producer, CorrelationID: messageID}, messageSent(translation)))
+		if err != nil {
+			return "", err
+		}
+		key := broker.SentKey(translation.SourceLang, translation.TargetLang)
+		traceContext := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, traceContext)
+		_, err = tx.Exec(ctx, "INSERT INTO outbox (topic, routing_key, payload, trace_context) VALUES ($1, $2, $3, $4)",
+			broker.MessageSentEvent, key, payload, map[string]string(traceContext))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
 	return messageID, nil
 }
 
+func messageSent(req *models.TranslationRequest) broker.MessageSent {
+	event := broker.MessageSent{
+		MessageID:        req.MessageID,
+		ChatID:           req.ChatID,
+		SenderUsername:   req.SenderUsername,
+		ReceiverUsername: req.ReceiverUsername,
+		Content:          req.Content,
+		SourceLang:       req.SourceLang,
+		TargetLang:       req.TargetLang,
+	}
+	for _, msg := range req.Context {
+		event.Context = append(event.Context, broker.ContextMessage{Sender: msg.Sender, Content: msg.Content})
+	}
+	return event
+}
+
 func (s *Store) GetMessage(ctx context.Context, id string) (*models.ChatMessage, error) {
 	query := `
-		SELECT message_id, chat_id, sender_username, receiver_username, content, translated_content, timestamp
+		SELECT message_id, chat_id, sender_username, receiver_username, content, translated_content, timestamp, seq, detected_language, mixed_language, source_language,
+			translation_status, translation_error, translation_provider, translation_model, translated_at,
+			COALESCE(translation_alternative_id::text, '')
 		FROM messages
 		WHERE message_id = $1
 	`
-	row := s.dbConn.QueryRow(ctx, query, id)
+	row := s.db.QueryRow(ctx, query, id)
 	return scanChatMessage(row)
 }
 
-func (s *Store) ListMessages(ctx context.Context, chatID string, since *time.Time, limit int, pageToken string) ([]*models.ChatMessage, string, error) {
-	effectiveSince := int64(0)
+// ListMessages pages through a chat in seq order, which is the order messages
+// were stored in; timestamps only have second precision. Cursors handed out
+// before carry the (timestamp, message ID) of a row instead, whose seq is
+// looked up.
+func (s *Store) ListMessages(ctx context.Context, chatID string, since *time.Time, limit int, cursor *pagination.Cursor, direction pagination.Direction) ([]*models.ChatMessage, *pagination.Cursor, error) {
+	if cursor != nil && cursor.Seq == 0 && cursor.ID == "" {
+		return nil, nil, pagination.ErrInvalidToken
+	}
+
+	sinceTs := int64(0)
 	if since != nil {
-		effectiveSince = since.Unix()
+		sinceTs = since.Unix()
 	}
-	if pageToken != "" {
-		if tokenTs, err := strconv.ParseInt(pageToken, 10, 64); err == nil && tokenTs > effectiveSince {
-			effectiveSince = tokenTs
-		}
+
+	order, cmp := "ASC", ">"
+	if direction == pagination.Backward {
+		order, cmp = "DESC", "<"
 	}
 
 	query := `
-		SELECT message_id, chat_id, sender_username, receiver_username, content, translated_content, timestamp
+		SELECT message_id, chat_id, sender_username, receiver_username, content, translated_content, timestamp, seq, detected_language, mixed_language, source_language,
+			translation_status, translation_error, translation_provider, translation_model, translated_at,
+			COALESCE(translation_alternative_id::text, '')
 		FROM messages
 		WHERE chat_id = $1 AND timestamp > $2
-		ORDER BY timestamp ASC
-		LIMIT $3
 	`
-	rows, err := s.dbConn.Query(ctx, query, chatID, effectiveSince, limit+1)
+	args := []any{chatID, sinceTs}
+	switch {
+	case cursor == nil:
+	case cursor.Seq != 0:
+		query += ` AND seq ` + cmp + ` $3`
+		args = append(args, cursor.Seq)
+	default:
+		query += ` AND seq ` + cmp + ` (SELECT seq FROM messages WHERE chat_id = $1 AND message_id = $3)`
+		args = append(args, cursor.ID)
+	}
+	query += fmt.Sprintf(` ORDER BY seq %s LIMIT %d`, order, limit+1)
+
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
@@ -109,21 +201,67 @@ func (s *Store) ListMessages(ctx context.Context, chatID string, since *time.Tim
 	for rows.Next() {
 		msg, err := scanChatMessage(rows)
 		if err != nil {
-			return nil, "", err
+			return nil, nil, err
 		}
 		messages = append(messages, msg)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	var nextPageToken string
+	messages, next := page(messages, limit, direction)
+	return messages, next, nil
+}
+
+// page trims rows fetched in direction, one more than limit if there is a
+// next page, to the page and its cursor. Pages are always oldest first.
+func page(messages []*models.ChatMessage, limit int, direction pagination.Direction) ([]*models.ChatMessage, *pagination.Cursor) {
+	var next *pagination.Cursor
 	if len(messages) > limit {
-		nextPageToken = strconv.FormatInt(messages[limit].Timestamp.Unix(), 10)
 		messages = messages[:limit]
+		next = &pagination.Cursor{Seq: messages[limit-1].Sequence}
 	}
 
-	return messages, nextPageToken, nil
+	if direction == pagination.Backward {
+		slices.Reverse(messages)
+	}
+	return messages, next
+}
+
+func (s *Store) ReplayMessages(ctx context.Context, chatID string, afterSequence int64, since *time.Time, limit int) ([]*models.ChatMessage, error) {
+	sinceTs := int64(0)
+	if since != nil {
+		sinceTs = since.Unix()
+	}
+
+	query := `
+		SELECT message_id, chat_id, sender_username, receiver_username, content, translated_content, timestamp, seq, detected_language, mixed_language, source_language,
+			translation_status, translation_error, translation_provider, translation_model, translated_at,
+			COALESCE(translation_alternative_id::text, '')
+		FROM messages
+		WHERE chat_id = $1 AND seq > $2 AND timestamp > $3
+		ORDER BY seq ASC
+		LIMIT $4
+	`
+	rows, err := s.db.Query(ctx, query, chatID, afterSequence, sinceTs, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*models.ChatMessage, 0)
+	for rows.Next() {
+		msg, err := scanChatMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
 }
 
 func (s *Store) GetChat(ctx context.Context, id string) (*models.Chat, error) {
@@ -132,7 +270,7 @@ func (s *Store) GetChat(ctx context.Context, id string) (*models.Chat, error) {
 		FROM chats
 		WHERE chat_id = $1
 	`
-	row := s.dbConn.QueryRow(ctx, query, id)
+	row := s.db.QueryRow(ctx, query, id)
 
 	var (
 		chatID     string
@@ -165,7 +303,7 @@ func (s *Store) ListChats(ctx context.Context, userName string) ([]*models.Chat,
 		FROM chats
 		WHERE username_a = $1 OR username_b = $1
 	`
-	rows, err := s.dbConn.Query(ctx, query, userName)
+	rows, err := s.db.Query(ctx, query, userName)
 	if err != nil {
 		return nil, err
 	}
@@ -200,13 +338,50 @@ func (s *Store) ListChats(ctx context.Context, userName string) ([]*models.Chat,
 	return chats, nil
 }
 
-func (s *Store) UpdateMessageTranslation(ctx context.Context, messageID string, translatedContent string) error {
+// UpdateMessageTranslation stores the outcome of a translation. The first
+// outcome is displayed; a later success, e.g. from a retry racing a streamed
+// translation, is only recorded as an alternative unless it repeats one.
+func (s *Store) UpdateMessageTranslation(ctx context.Context, update *models.TranslationUpdate) error {
+	if update.Status != models.TranslationDone {
+		query := `
+			UPDATE messages
+			SET translation_status = $1, translation_error = $2
+			WHERE message_id = $3 AND translation_status <> 'done'
+		`
+		_, err := s.db.Exec(ctx, query, string(update.Status), update.Error, update.MessageID)
+		return err
+	}
+
 	query := `
+		WITH existing AS (
+			SELECT alternative_id
+			FROM translation_alternatives
+			WHERE message_id = $1 AND translated_content = $2 AND style = '' AND provider = $3
+			LIMIT 1
+		), inserted AS (
+			INSERT INTO translation_alternatives (message_id, translated_content, provider, model, created_at)
+			SELECT message_id, $2, $3, $4, $5
+			FROM messages
+			WHERE message_id = $1 AND NOT EXISTS (SELECT 1 FROM existing)
+			RETURNING alternative_id
+		)
 		UPDATE messages
-		SET translated_content = $1
-		WHERE message_id = $2
+		SET translated_content = $2,
+			translation_status = 'done',
+			translation_error = '',
+			translation_provider = $3,
+			translation_model = $4,
+			translated_at = $5,
+			translation_alternative_id = COALESCE((SELECT alternative_id FROM inserted), (SELECT alternative_id FROM existing))
+		WHERE message_id = $1 AND translation_status <> 'done'
 	`
-	_, err := s.dbConn.Exec(ctx, query, translatedContent, messageID)
+	_, err := s.db.Exec(ctx, query,
+		update.MessageID,
+		update.TranslatedContent,
+		update.Provider,
+		update.Model,
+		time.Now().Unix(),
+	)
 	if err != nil {
 		return err
 	}
@@ -214,6 +389,157 @@ func (s *Store) UpdateMessageTranslation(ctx context.Context, messageID string,
 	return nil
 }
 
+// AddTranslationAlternative stores a new translation of a message and
+// displays it.
+func (s *Store) AddTranslationAlternative(ctx context.Context, alternative *models.TranslationAlternative) (string, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO translation_alternatives
+			(message_id, translated_content, style, provider, model, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING alternative_id
+	`
+	now := time.Now()
+	alternative.CreatedAt = now
+	var alternativeID string
+	err = tx.QueryRow(ctx, query,
+		alternative.MessageID,
+		alternative.TranslatedContent,
+		alternative.Style,
+		alternative.Provider,
+		alternative.Model,
+		now.Unix(),
+	).Scan(&alternativeID)
+	if err != nil {
+		return "", err
+	}
+
+	if err = selectAlternative(ctx, tx, alternative.MessageID, alternativeID); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return alternativeID, nil
+}
+
+func (s *Store) ListTranslationAlternatives(ctx context.Context, messageID string) ([]*models.TranslationAlternative, error) {
+	query := `
+		SELECT alternative_id, message_id, translated_content, style, provider, model, created_at
+		FROM translation_alternatives
+		WHERE message_id = $1
+		ORDER BY created_at, alternative_id
+	`
+	rows, err := s.db.Query(ctx, query, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alternatives := make([]*models.TranslationAlternative, 0)
+	for rows.Next() {
+		var (
+			alternative models.TranslationAlternative
+			createdAt   int64
+		)
+		err = rows.Scan(&alternative.AlternativeID, &alternative.MessageID, &alternative.TranslatedContent,
+			&alternative.Style, &alternative.Provider, &alternative.Model, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		alternative.CreatedAt = time.Unix(createdAt, 0)
+		alternatives = append(alternatives, &alternative)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alternatives, nil
+}
+
+func (s *Store) GetMessageTranslation(ctx context.Context, messageID, language string) (*models.MessageTranslation, error) {
+	query := `
+		SELECT message_id, language, translated_content, provider, model, created_at
+		FROM message_translations
+		WHERE message_id = $1 AND language = $2
+	`
+	translation, err := scanMessageTranslation(s.db.QueryRow(ctx, query, messageID, language))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.ErrMessageTranslationMissing
+	}
+	return translation, err
+}
+
+// SaveMessageTranslation stores a translation unless one exists already and
+// returns the stored one, so concurrent first requests agree on the result.
+func (s *Store) SaveMessageTranslation(ctx context.Context, translation *models.MessageTranslation) (*models.MessageTranslation, error) {
+	query := `
+		INSERT INTO message_translations
+			(message_id, language, translated_content, provider, model, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (message_id, language) DO UPDATE SET message_id = EXCLUDED.message_id
+		RETURNING message_id, language, translated_content, provider, model, created_at
+	`
+	row := s.db.QueryRow(ctx, query,
+		translation.MessageID,
+		translation.Language,
+		translation.TranslatedContent,
+		translation.Provider,
+		translation.Model,
+		time.Now().Unix(),
+	)
+	return scanMessageTranslation(row)
+}
+
+func scanMessageTranslation(row pgx.Row) (*models.MessageTranslation, error) {
+	var (
+		translation models.MessageTranslation
+		createdAt   int64
+	)
+	err := row.Scan(&translation.MessageID, &translation.Language, &translation.TranslatedContent,
+		&translation.Provider, &translation.Model, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	translation.CreatedAt = time.Unix(createdAt, 0)
+	return &translation, nil
+}
+
+func (s *Store) SelectTranslationAlternative(ctx context.Context, messageID, alternativeID string) error {
+	return selectAlternative(ctx, s.db, messageID, alternativeID)
+}
+
+// selectAlternative copies an alternative onto its message. A selected
+// alternative always counts as a done translation.
+func selectAlternative(ctx context.Context, db execer, messageID, alternativeID string) error {
+	query := `
+		UPDATE messages m
+		SET translated_content = a.translated_content,
+			translation_status = 'done',
+			translation_error = '',
+			translation_provider = a.provider,
+			translation_model = a.model,
+			translated_at = a.created_at,
+			translation_alternative_id = a.alternative_id
+		FROM translation_alternatives a
+		WHERE m.message_id = $1 AND a.message_id = m.message_id AND a.alternative_id::text = $2
+	`
+	tag, err := db.Exec(ctx, query, messageID, alternativeID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrAlternativeNotFound
+	}
+	return nil
+}
+
 func scanChatMessage(rs pgx.Row) (*models.ChatMessage, error) {
 	var (
 		messageID         string
@@ -223,22 +549,110 @@ func scanChatMessage(rs pgx.Row) (*models.ChatMessage, error) {
 		content           string
 		translatedContent string
 		ts                int64
+		seq               int64
+		detectedLanguage  string
+		mixedLanguage     bool
+		sourceLanguage    string
+		status            string
+		translationError  string
+		provider          string
+		model             string
+		translatedAt      int64
+		alternativeID     string
 	)
 
-	if err := rs.Scan(&messageID, &chatID, &senderUsername, &receiverUsername, &content, &translatedContent, &ts); err != nil {
+	if err := rs.Scan(&messageID, &chatID, &senderUsername, &receiverUsername, &content, &translatedContent, &ts, &seq, &detectedLanguage, &mixedLanguage,
+		&sourceLanguage, &status, &translationError, &provider, &model, &translatedAt, &alternativeID); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errors.New("message not found")
 		}
 		return nil, err
 	}
 
-	return &models.ChatMessage{
-		MessageID:         messageID,
-		ChatID:            chatID,
-		SenderUsername:    senderUsername,
-		ReceiverUsername:  receiverUsername,
-		Content:           content,
-		TranslatedContent: translatedContent,
-		Timestamp:         time.Unix(ts, 0),
-	}, nil
+	msg := &models.ChatMessage{
+		MessageID:           messageID,
+		ChatID:              chatID,
+		SenderUsername:      senderUsername,
+		ReceiverUsername:    receiverUsername,
+		Content:             content,
+		TranslatedContent:   translatedContent,
+		Timestamp:           time.Unix(ts, 0),
+		Sequence:            seq,
+		DetectedLanguage:    detectedLanguage,
+		MixedLanguage:       mixedLanguage,
+		SourceLanguage:      sourceLanguage,
+		TranslationStatus:   models.TranslationStatus(status),
+		TranslationError:    translationError,
+		TranslationProvider: provider,
+		TranslationModel:    model,
+
+		TranslationAlternativeID: alternativeID,
+	}
+	if translatedAt > 0 {
+		msg.TranslatedAt = time.Unix(translatedAt, 0)
+	}
+	return msg, nil
+}
+
+// RelayOutbox hands up to limit unpublished outbox events to publish in
+// insertion order and marks the delivered ones as published. Rows are locked
+// with SKIP LOCKED, so several relays can drain the outbox side by side. The
+// first failure stops the batch to keep the order for the next attempt.
+func (s *Store) RelayOutbox(ctx context.Context, limit int, publish func(ctx context.Context, event *models.OutboxEvent) error) (int, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT id, topic, routing_key, payload, trace_context, created_at, attempts
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		event := &models.OutboxEvent{}
+		if err = rows.Scan(&event.ID, &event.Topic, &event.RoutingKey, &event.Payload, &event.TraceContext, &event.CreatedAt, &event.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// The first failure stops the batch, so later events keep their order.
+	// It is recorded on the event and returned once the rest is committed.
+	published := 0
+	var publishErr error
+	for _, event := range events {
+		if err = publish(ctx, event); err != nil {
+			_, updateErr := tx.Exec(ctx, "UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1", event.ID, err.Error())
+			if updateErr != nil {
+				return 0, updateErr
+			}
+			publishErr = fmt.Errorf("outbox event %d (attempt %d): %w", event.ID, event.Attempts+1, err)
+			break
+		}
+		if _, err = tx.Exec(ctx, "UPDATE outbox SET published_at = now() WHERE id = $1", event.ID); err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return published, publishErr
 }
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+	"github.com/HJyup/translatify-common/pagination"
+)
+
+func TestPage(t *testing.T) {
+	// rows as the queries return them, in the direction of the page.
+	rows := func(seqs ...int64) []*models.ChatMessage {
+		messages := make([]*models.ChatMessage, len(seqs))
+		for i, seq := range seqs {
+			messages[i] = &models.ChatMessage{Sequence: seq}
+		}
+		return messages
+	}
+
+	tests := []struct {
+		name      string
+		rows      []*models.ChatMessage
+		limit     int
+		direction pagination.Direction
+		want      []int64
+		wantNext  *pagination.Cursor
+	}{
+		{name: "forward, last page", rows: rows(1, 2), limit: 3, direction: pagination.Forward, want: []int64{1, 2}},
+		{name: "forward, more", rows: rows(1, 2, 3), limit: 2, direction: pagination.Forward, want: []int64{1, 2}, wantNext: &pagination.Cursor{Seq: 2}},
+		{name: "backward, last page", rows: rows(9, 8), limit: 3, direction: pagination.Backward, want: []int64{8, 9}},
+		{name: "backward, more", rows: rows(9, 8, 7), limit: 2, direction: pagination.Backward, want: []int64{8, 9}, wantNext: &pagination.Cursor{Seq: 8}},
+		{name: "empty", rows: rows(), limit: 2, direction: pagination.Backward, want: []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, next := page(tt.rows, tt.limit, tt.direction)
+
+			got := make([]int64, len(messages))
+			for i, msg := range messages {
+				got[i] = msg.Sequence
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("page = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(next, tt.wantNext) {
+				t.Errorf("next = %+v, want %+v", next, tt.wantNext)
+			}
+		})
+	}
+}
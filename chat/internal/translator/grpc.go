@@ -0,0 +1,57 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HJyup/translatify-chat/internal/models"
+	pb "github.com/HJyup/translatify-common/api"
+	"github.com/HJyup/translatify-common/discovery"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcTranslator calls TranslateMessage on the translation service.
+type GrpcTranslator struct {
+	registry discovery.Registry
+}
+
+func NewGrpcTranslator(registry discovery.Registry) *GrpcTranslator {
+	return &GrpcTranslator{registry: registry}
+}
+
+func (t *GrpcTranslator) Translate(ctx context.Context, req *models.TranslationRequest, opts models.TranslateOptions) (*models.Translation, error) {
+	conn, err := discovery.ServiceConnection(ctx, "translation", t.registry)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, err := pb.NewTranslationServiceClient(conn).TranslateMessage(ctx, &pb.TranslationRequest{
+		MessageId:      req.MessageID,
+		Content:        req.Content,
+		SourceLanguage: req.SourceLang,
+		TargetLanguage: req.TargetLang,
+		ChatId:         req.ChatID,
+		Participants:   []string{req.SenderUsername, req.ReceiverUsername},
+		Username:       req.Username,
+		Style:          opts.Style,
+		Provider:       opts.Provider,
+		SkipCache:      opts.SkipCache,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.InvalidArgument:
+		return nil, fmt.Errorf("%w: %s", models.ErrInvalidTranslationOptions, status.Convert(err).Message())
+	case codes.ResourceExhausted:
+		return nil, fmt.Errorf("%w: %s", models.ErrTranslationQuotaExceeded, status.Convert(err).Message())
+	default:
+		return nil, err
+	}
+
+	return &models.Translation{
+		Content:  resp.GetTranslatedContent(),
+		Provider: resp.GetProvider(),
+		Model:    resp.GetModel(),
+	}, nil
+}
@@ -6,10 +6,13 @@ import (
 	"log"
 )
 
-func Connect(user, pass, host, port string) (*amqp.Channel, func() error) {
-	address := fmt.Sprintf("amqp://%s:%s@%s:%s/", user, pass, host, port)
+// Address is the AMQP URL of the broker.
+func Address(user, pass, host, port string) string {
+	return fmt.Sprintf("amqp://%s:%s@%s:%s/", user, pass, host, port)
+}
 
-	conn, err := amqp.Dial(address)
+func Connect(user, pass, host, port string) (*amqp.Channel, func() error) {
+	conn, err := amqp.Dial(Address(user, pass, host, port))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -19,14 +22,8 @@ func Connect(user, pass, host, port string) (*amqp.Channel, func() error) {
 		log.Fatal(err)
 	}
 
-	err = ch.ExchangeDeclare(MessageSentEvent, "direct", true, false, false, false, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = ch.ExchangeDeclare(MessageTranslatedEvent, "direct", true, false, false, false, nil)
-	if err != nil {
-		log.Fatal(err)
+	if err = DeclareTopology(ch); err != nil {
+		log.Fatalf("Failed to declare the broker topology: %v", err)
 	}
 
 	return ch, conn.Close
@@ -0,0 +1,59 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const defaultDeadLetterLimit = 100
+
+// RunDeadLetters implements the deadletters subcommand of the services. It
+// lists or re-drives the dead-lettered deliveries of queue and reports to out:
+//
+//	<service> deadletters [list [limit] | redrive [limit]]
+func RunDeadLetters(ctx context.Context, ch *amqp.Channel, queue string, args []string, out io.Writer) error {
+	command, limit, err := parseDeadLetterArgs(args)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		letters, err := ListDeadLetters(ch, queue, limit)
+		if err != nil {
+			return err
+		}
+		for _, letter := range letters {
+			fmt.Fprintf(out, "%s\tretries=%d\treason=%q\t%s\n", letter.FailedAt.Format("2006-01-02T15:04:05Z07:00"), letter.RetryCount, letter.Reason, letter.Body)
+		}
+		fmt.Fprintf(out, "%d dead-lettered message(s) in %s\n", len(letters), DeadLetterQueue(queue))
+		return nil
+	default:
+		moved, err := Redrive(ctx, ch, queue, limit)
+		fmt.Fprintf(out, "Re-drove %d message(s) onto %s\n", moved, queue)
+		return err
+	}
+}
+
+func parseDeadLetterArgs(args []string) (string, int, error) {
+	command := "list"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if command != "list" && command != "redrive" {
+		return "", 0, fmt.Errorf("unknown deadletters command %q", command)
+	}
+
+	limit := defaultDeadLetterLimit
+	if len(args) > 1 {
+		var err error
+		if limit, err = strconv.Atoi(args[1]); err != nil || limit <= 0 {
+			return "", 0, fmt.Errorf("invalid limit %q", args[1])
+		}
+	}
+	return command, limit, nil
+}
@@ -0,0 +1,31 @@
+package broker
+
+import "testing"
+
+func TestParseDeadLetterArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantCommand string
+		wantLimit   int
+		wantErr     bool
+	}{
+		{name: "defaults", wantCommand: "list", wantLimit: defaultDeadLetterLimit},
+		{name: "list with limit", args: []string{"list", "20"}, wantCommand: "list", wantLimit: 20},
+		{name: "redrive", args: []string{"redrive"}, wantCommand: "redrive", wantLimit: defaultDeadLetterLimit},
+		{name: "unknown command", args: []string{"purge"}, wantErr: true},
+		{name: "zero limit", args: []string{"list", "0"}, wantErr: true},
+		{name: "bad limit", args: []string{"redrive", "all"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, limit, err := parseDeadLetterArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if command != tt.wantCommand || limit != tt.wantLimit {
+				t.Errorf("parseDeadLetterArgs = %q, %d, want %q, %d", command, limit, tt.wantCommand, tt.wantLimit)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var ErrUnsupportedEvent = errors.New("unsupported event")
+
+// Envelope is what goes over the wire for every event. CorrelationID ties the
+// events of one flow together, e.g. a message and its translation.
+type Envelope[T Event] struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	Version       int       `json:"version"`
+	Producer      string    `json:"producer"`
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Data          T         `json:"data"`
+}
+
+// Meta is what the publisher adds to an envelope besides the event.
+type Meta struct {
+	Producer      string
+	CorrelationID string
+}
+
+func NewEnvelope[T Event](meta Meta, data T) Envelope[T] {
+	return Envelope[T]{
+		ID:            uuid.NewString(),
+		Type:          data.EventType(),
+		Version:       data.EventVersion(),
+		Producer:      meta.Producer,
+		Time:          time.Now().UTC(),
+		CorrelationID: meta.CorrelationID,
+		Data:          data,
+	}
+}
+
+// Correlation returns the correlation ID to carry on to the events that
+// follow from this one.
+func (e Envelope[T]) Correlation() string {
+	if e.CorrelationID != "" {
+		return e.CorrelationID
+	}
+	return e.ID
+}
+
+// header is the part of an envelope that does not depend on its event.
+type header struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	Version       int       `json:"version"`
+	Producer      string    `json:"producer"`
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlationId"`
+}
+
+// Publishing wraps an encoded envelope for AMQP and repeats its header in the
+// message properties, where tools like the management UI show them.
+func Publishing(body []byte) amqp.Publishing {
+	var h header
+	_ = json.Unmarshal(body, &h)
+	return amqp.Publishing{
+		ContentType:   "application/json",
+		Body:          body,
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     h.ID,
+		Type:          h.Type,
+		AppId:         h.Producer,
+		Timestamp:     h.Time,
+		CorrelationId: h.CorrelationID,
+	}
+}
+
+// Publish sends data in a new envelope to the exchange of its type.
+func Publish[T Event](ctx context.Context, ch *amqp.Channel, key string, meta Meta, data T) error {
+	body, err := json.Marshal(NewEnvelope(meta, data))
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", data.EventType(), err)
+	}
+	return publish(ctx, ch, data.EventType(), key, Publishing(body))
+}
+
+// Decode reads an envelope holding a T of at most the version this build
+// knows. Bodies published before envelopes existed hold the bare event and
+// decode as version 0; JSON keys match regardless of case, so their older
+// spellings such as "messageID" still fill the fields.
+func Decode[T Event](body []byte) (Envelope[T], error) {
+	var (
+		env  Envelope[T]
+		want T
+		h    header
+	)
+	if err := json.Unmarshal(body, &h); err != nil {
+		return env, err
+	}
+
+	if h.Type == "" {
+		if err := json.Unmarshal(body, &env.Data); err != nil {
+			return env, err
+		}
+		env.Type = want.EventType()
+		return env, nil
+	}
+
+	if h.Type != want.EventType() {
+		return env, fmt.Errorf("%w: got %s, want %s", ErrUnsupportedEvent, h.Type, want.EventType())
+	}
+	if h.Version > want.EventVersion() {
+		return env, fmt.Errorf("%w: %s version %d is newer than %d", ErrUnsupportedEvent, h.Type, h.Version, want.EventVersion())
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return env, err
+	}
+	return env, nil
+}
+
+// Delivery is a decoded delivery; settle it through the embedded
+// amqp.Delivery or Retry as usual.
+type Delivery[T Event] struct {
+	amqp.Delivery
+	Envelope Envelope[T]
+}
+
+// Subscribe consumes queue with manual acknowledgements and decodes every
+// delivery into a T. Deliveries that cannot be decoded never will be and are
+// dead-lettered right away. The channel is closed once the subscription is
+// cancelled and the deliveries already received are handed out.
+func Subscribe[T Event](ch *amqp.Channel, queue, consumer string) (<-chan Delivery[T], error) {
+	msgs, err := ch.Consume(queue, consumer, false, false, false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Delivery[T])
+	go func() {
+		defer close(out)
+		for d := range msgs {
+			env, err := Decode[T](d.Body)
+			if err != nil {
+				log.Printf("Dead-lettering undecodable delivery on %s: %v", queue, err)
+				if err = DeadLetterDelivery(context.Background(), ch, queue, d, err); err != nil {
+					log.Printf("Failed to dead-letter delivery on %s: %v", queue, err)
+				}
+				continue
+			}
+			out <- Delivery[T]{Delivery: d, Envelope: env}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	meta := Meta{Producer: "chat", CorrelationID: "m1"}
+
+	t.Run("MessageSent", func(t *testing.T) {
+		sent := MessageSent{
+			MessageID:        "m1",
+			ChatID:           "c1",
+			SenderUsername:   "alice",
+			ReceiverUsername: "bob",
+			Content:          "hello",
+			SourceLang:       "en",
+			TargetLang:       "de",
+			Context:          []ContextMessage{{Sender: "bob", Content: "hi"}},
+		}
+		roundTrip(t, NewEnvelope(meta, sent))
+	})
+
+	t.Run("MessageTranslated", func(t *testing.T) {
+		translated := MessageTranslated{
+			MessageID:         "m1",
+			TargetLang:        "de",
+			TranslatedContent: "hallo",
+			Provider:          "openai",
+			Model:             "gpt-4o-mini",
+			Success:           true,
+		}
+		roundTrip(t, NewEnvelope(meta, translated))
+	})
+}
+
+func roundTrip[T Event](t *testing.T, want Envelope[T]) {
+	t.Helper()
+
+	body, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got, err := Decode[T](body)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if !got.Time.Equal(want.Time) {
+		t.Errorf("Time = %v, want %v", got.Time, want.Time)
+	}
+	got.Time = want.Time
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+	if got.Version != want.Data.EventVersion() || got.Type != want.Data.EventType() {
+		t.Errorf("header = %s v%d, want %s v%d", got.Type, got.Version, want.Data.EventType(), want.Data.EventVersion())
+	}
+
+	p := Publishing(body)
+	if p.MessageId != want.ID || p.Type != want.Type || p.CorrelationId != want.CorrelationID || p.AppId != want.Producer {
+		t.Errorf("Publishing properties = %+v, want the envelope header", p)
+	}
+}
+
+func TestDecodeBarePayloads(t *testing.T) {
+	t.Run("MessageSent", func(t *testing.T) {
+		body := `{"messageID":"m1","chatID":"c1","senderUsername":"alice","receiverUsername":"bob",` +
+			`"content":"hello","sourceLang":"en","targetLang":"de","context":[{"sender":"bob","content":"hi"}]}`
+		want := MessageSent{
+			MessageID:        "m1",
+			ChatID:           "c1",
+			SenderUsername:   "alice",
+			ReceiverUsername: "bob",
+			Content:          "hello",
+			SourceLang:       "en",
+			TargetLang:       "de",
+			Context:          []ContextMessage{{Sender: "bob", Content: "hi"}},
+		}
+		decodeBare(t, body, want)
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want MessageTranslated
+	}{
+		{
+			name: "done",
+			body: `{"messageId":"m1","translatedContent":"hallo","provider":"openai","model":"gpt-4o-mini","Success":true}`,
+			want: MessageTranslated{MessageID: "m1", TranslatedContent: "hallo", Provider: "openai", Model: "gpt-4o-mini", Success: true},
+		},
+		{
+			name: "failed",
+			body: `{"messageId":"m1","Success":false,"error":"quota exceeded"}`,
+			want: MessageTranslated{MessageID: "m1", Error: "quota exceeded"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("MessageTranslated/"+tt.name, func(t *testing.T) {
+			decodeBare(t, tt.body, tt.want)
+		})
+	}
+}
+
+func decodeBare[T Event](t *testing.T, body string, want T) {
+	t.Helper()
+
+	got, err := Decode[T]([]byte(body))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Version != 0 {
+		t.Errorf("Version = %d, want 0", got.Version)
+	}
+	if got.Type != want.EventType() {
+		t.Errorf("Type = %q, want %q", got.Type, want.EventType())
+	}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("Data = %+v, want %+v", got.Data, want)
+	}
+}
+
+func TestDecodeUnsupported(t *testing.T) {
+	envelope := func(eventType string, version int) []byte {
+		body, err := json.Marshal(map[string]any{
+			"id":      "e1",
+			"type":    eventType,
+			"version": version,
+			"data":    map[string]any{"messageId": "m1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	tests := []struct {
+		name    string
+		body    []byte
+		decode  func([]byte) error
+		wantErr error
+	}{
+		{
+			name:   "current MessageSent",
+			body:   envelope(MessageSentEvent, MessageSent{}.EventVersion()),
+			decode: decodeAs[MessageSent],
+		},
+		{
+			name:   "older MessageTranslated",
+			body:   envelope(MessageTranslatedEvent, 0),
+			decode: decodeAs[MessageTranslated],
+		},
+		{
+			name:    "newer MessageSent",
+			body:    envelope(MessageSentEvent, MessageSent{}.EventVersion()+1),
+			decode:  decodeAs[MessageSent],
+			wantErr: ErrUnsupportedEvent,
+		},
+		{
+			name:    "newer MessageTranslated",
+			body:    envelope(MessageTranslatedEvent, MessageTranslated{}.EventVersion()+1),
+			decode:  decodeAs[MessageTranslated],
+			wantErr: ErrUnsupportedEvent,
+		},
+		{
+			name:    "MessageTranslated read as MessageSent",
+			body:    envelope(MessageTranslatedEvent, 1),
+			decode:  decodeAs[MessageSent],
+			wantErr: ErrUnsupportedEvent,
+		},
+		{
+			name:    "MessageSent read as MessageTranslated",
+			body:    envelope(MessageSentEvent, 1),
+			decode:  decodeAs[MessageTranslated],
+			wantErr: ErrUnsupportedEvent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.decode(tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decode error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func decodeAs[T Event](body []byte) error {
+	_, err := Decode[T](body)
+	return err
+}
@@ -4,3 +4,49 @@ const (
 	MessageSentEvent       = "message.sent"
 	MessageTranslatedEvent = "message.translated"
 )
+
+// Event is the payload of an envelope. Its type names the exchange it is
+// published to. The version is raised only for changes a consumer of the
+// previous version cannot read; adding an optional field keeps it.
+type Event interface {
+	EventType() string
+	EventVersion() int
+}
+
+// MessageSent asks the translation service to translate a stored chat
+// message. ChatID and the usernames select the glossaries that apply.
+type MessageSent struct {
+	MessageID        string           `json:"messageId"`
+	ChatID           string           `json:"chatId"`
+	SenderUsername   string           `json:"senderUsername"`
+	ReceiverUsername string           `json:"receiverUsername"`
+	Content          string           `json:"content"`
+	SourceLang       string           `json:"sourceLang"`
+	TargetLang       string           `json:"targetLang"`
+	Context          []ContextMessage `json:"context,omitempty"`
+}
+
+func (MessageSent) EventType() string { return MessageSentEvent }
+func (MessageSent) EventVersion() int { return 1 }
+
+// ContextMessage is an earlier message of the chat, sent along so short or
+// referring replies translate correctly.
+type ContextMessage struct {
+	Sender  string `json:"sender"`
+	Content string `json:"content"`
+}
+
+// MessageTranslated is the outcome of a message translation. A failed one
+// has Success unset and Error explaining why.
+type MessageTranslated struct {
+	MessageID         string `json:"messageId"`
+	TargetLang        string `json:"targetLang,omitempty"`
+	TranslatedContent string `json:"translatedContent,omitempty"`
+	Provider          string `json:"provider,omitempty"`
+	Model             string `json:"model,omitempty"`
+	Success           bool   `json:"success"`
+	Error             string `json:"error,omitempty"`
+}
+
+func (MessageTranslated) EventType() string { return MessageTranslatedEvent }
+func (MessageTranslated) EventVersion() int { return 1 }
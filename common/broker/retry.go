@@ -0,0 +1,217 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	retryCountHeader  = "x-retry-count"
+	deathReasonHeader = "x-death-reason"
+	deathTimeHeader   = "x-death-time"
+)
+
+// RetryPolicy bounds how often a failed delivery is re-attempted. The n-th
+// retry waits BaseDelay * 2^(n-1) in a dedicated delay queue before it is
+// dead-lettered back onto the work queue.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 5, BaseDelay: time.Second}
+
+// Delay is how long the given retry attempt waits in its delay queue.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	return p.BaseDelay << (attempt - 1)
+}
+
+type DeadLetter struct {
+	Body       []byte
+	RetryCount int
+	Reason     string
+	FailedAt   time.Time
+}
+
+func RetryQueue(queue string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, attempt)
+}
+
+func DeadLetterExchange(queue string) string {
+	return queue + ".dlx"
+}
+
+func DeadLetterQueue(queue string) string {
+	return queue + ".dead"
+}
+
+// DeclareRetryTopology declares the work queue together with its delay
+// queues and its dead-letter exchange and queue. The work queue itself keeps
+// no extra arguments, so it stays compatible with queues declared before.
+func DeclareRetryTopology(ch *amqp.Channel, queue string, policy RetryPolicy) error {
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+
+	for attempt := 1; attempt <= policy.MaxRetries; attempt++ {
+		_, err := ch.QueueDeclare(RetryQueue(queue, attempt), true, false, false, false, amqp.Table{
+			"x-message-ttl":             policy.Delay(attempt).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := ch.ExchangeDeclare(DeadLetterExchange(queue), "fanout", true, false, false, false, nil); err != nil {
+		return err
+	}
+	if _, err := ch.QueueDeclare(DeadLetterQueue(queue), true, false, false, false, nil); err != nil {
+		return err
+	}
+	return ch.QueueBind(DeadLetterQueue(queue), "", DeadLetterExchange(queue), false, nil)
+}
+
+func RetryCount(d amqp.Delivery) int {
+	switch v := d.Headers[retryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// Retry acknowledges a failed delivery after parking a copy in the next delay
+// queue, or dead-letters it once the policy is exhausted.
+func Retry(ctx context.Context, ch *amqp.Channel, queue string, d amqp.Delivery, policy RetryPolicy, cause error) error {
+	attempt := RetryCount(d) + 1
+	if attempt > policy.MaxRetries {
+		return DeadLetterDelivery(ctx, ch, queue, d, cause)
+	}
+
+	headers := copyHeaders(d.Headers)
+	headers[retryCountHeader] = int32(attempt)
+
+	err := publish(ctx, ch, "", RetryQueue(queue, attempt), amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		Body:         d.Body,
+		DeliveryMode: amqp.Persistent,
+	})
+	if err != nil {
+		_ = d.Nack(false, true)
+		return err
+	}
+
+	return d.Ack(false)
+}
+
+// DeadLetterDelivery moves a delivery that can never succeed, such as an
+// undecodable payload, straight to the dead-letter exchange.
+func DeadLetterDelivery(ctx context.Context, ch *amqp.Channel, queue string, d amqp.Delivery, cause error) error {
+	headers := copyHeaders(d.Headers)
+	headers[deathTimeHeader] = time.Now().UTC().Format(time.RFC3339)
+	if cause != nil {
+		headers[deathReasonHeader] = cause.Error()
+	}
+
+	err := publish(ctx, ch, DeadLetterExchange(queue), "", amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		Body:         d.Body,
+		DeliveryMode: amqp.Persistent,
+	})
+	if err != nil {
+		_ = d.Nack(false, true)
+		return err
+	}
+
+	return d.Ack(false)
+}
+
+// ListDeadLetters peeks at up to limit dead-lettered deliveries of queue and
+// returns them to the dead-letter queue untouched.
+func ListDeadLetters(ch *amqp.Channel, queue string, limit int) ([]DeadLetter, error) {
+	var (
+		letters []DeadLetter
+		lastTag uint64
+	)
+	for len(letters) < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		lastTag = d.DeliveryTag
+
+		letter := DeadLetter{Body: d.Body, RetryCount: RetryCount(d)}
+		letter.Reason, _ = d.Headers[deathReasonHeader].(string)
+		if failedAt, ok := d.Headers[deathTimeHeader].(string); ok {
+			letter.FailedAt, _ = time.Parse(time.RFC3339, failedAt)
+		}
+		letters = append(letters, letter)
+	}
+
+	if lastTag > 0 {
+		if err := ch.Nack(lastTag, true, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return letters, nil
+}
+
+// Redrive moves up to limit dead-lettered deliveries back onto queue with a
+// fresh retry budget and reports how many were moved.
+func Redrive(ctx context.Context, ch *amqp.Channel, queue string, limit int) (int, error) {
+	moved := 0
+	for moved < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return moved, err
+		}
+		if !ok {
+			break
+		}
+
+		headers := copyHeaders(d.Headers)
+		delete(headers, retryCountHeader)
+		delete(headers, deathReasonHeader)
+		delete(headers, deathTimeHeader)
+
+		err = ch.PublishWithContext(ctx, "", queue, false, false, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  d.ContentType,
+			Body:         d.Body,
+			DeliveryMode: amqp.Persistent,
+		})
+		if err != nil {
+			_ = d.Nack(false, true)
+			return moved, err
+		}
+		if err = d.Ack(false); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+
+	return moved, nil
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	out := make(amqp.Table, len(headers)+2)
+	for k, v := range headers {
+		out[k] = v
+	}
+	return out
+}
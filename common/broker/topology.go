@@ -0,0 +1,111 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Events are published to topic exchanges named after them, never straight
+// to a queue. The routing key describes the event, so anyone interested can
+// bind a queue of their own without the publisher knowing:
+//
+//	message.sent        <source>.<target>    e.g. "en.de"
+//	message.translated  <outcome>.<target>   e.g. "done.de", "failed.fr"
+//
+// An analytics consumer would bind "#", a notifier for failed translations
+// "failed.*" and a German search index "done.de".
+const (
+	TranslationDone   = "done"
+	TranslationFailed = "failed"
+)
+
+// unknownLanguage stands in for a language that is not known yet, so the
+// key keeps its shape.
+const unknownLanguage = "auto"
+
+type Binding struct {
+	Queue    string
+	Exchange string
+	Key      string
+}
+
+var (
+	Exchanges = []string{MessageSentEvent, MessageTranslatedEvent}
+
+	// Bindings are the work queues of the translation and chat services.
+	// They are declared by every service, so events published before their
+	// consumer first started are kept rather than dropped as unroutable.
+	Bindings = []Binding{
+		{Queue: MessageSentEvent, Exchange: MessageSentEvent, Key: "#"},
+		{Queue: MessageTranslatedEvent, Exchange: MessageTranslatedEvent, Key: "#"},
+	}
+)
+
+// DeclareTopology declares the event exchanges and the services' work queues.
+// Retry and dead-letter queues belong to each consumer and are declared by
+// DeclareRetryTopology.
+func DeclareTopology(ch *amqp.Channel) error {
+	for _, exchange := range Exchanges {
+		if err := ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil); err != nil {
+			return exchangeError(exchange, err)
+		}
+	}
+	for _, b := range Bindings {
+		if err := Bind(ch, b.Queue, b.Exchange, b.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exchangeError explains the refusal to redeclare an exchange that earlier
+// releases declared as direct.
+func exchangeError(exchange string, err error) error {
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.PreconditionFailed {
+		return err
+	}
+	return fmt.Errorf("exchange %s exists with another type, delete it once to upgrade "+
+		"(rabbitmqadmin delete exchange name=%s); its queues and messages are kept: %w", exchange, exchange, err)
+}
+
+// Bind declares a durable queue and routes the events of exchange matching
+// any of keys onto it.
+func Bind(ch *amqp.Channel, queue, exchange string, keys ...string) error {
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ch.QueueBind(queue, key, exchange, false, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SentKey routes a translation request by its language pair.
+func SentKey(sourceLang, targetLang string) string {
+	return keyWord(sourceLang) + "." + keyWord(targetLang)
+}
+
+// TranslatedKey routes a translation outcome by whether it succeeded and the
+// language it was meant for.
+func TranslatedKey(success bool, targetLang string) string {
+	outcome := TranslationDone
+	if !success {
+		outcome = TranslationFailed
+	}
+	return outcome + "." + keyWord(targetLang)
+}
+
+// keyWord makes a language code a single word of a routing key.
+func keyWord(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		return unknownLanguage
+	}
+	return strings.NewReplacer(".", "_", "*", "_", "#", "_").Replace(lang)
+}
@@ -0,0 +1,37 @@
+package broker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestExchangeError(t *testing.T) {
+	inequivalent := &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - inequivalent arg 'type'"}
+	refused := &amqp.Error{Code: amqp.AccessRefused, Reason: "ACCESS_REFUSED"}
+	other := errors.New("connection reset")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantMessage bool
+	}{
+		{name: "precondition failed", err: inequivalent, wantMessage: true},
+		{name: "access refused", err: refused},
+		{name: "other error", err: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exchangeError(MessageSentEvent, tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Errorf("exchangeError = %v, want it to wrap %v", got, tt.err)
+			}
+			hasMessage := strings.Contains(got.Error(), "rabbitmqadmin delete exchange name="+MessageSentEvent)
+			if hasMessage != tt.wantMessage {
+				t.Errorf("exchangeError = %q, want migration instruction %v", got, tt.wantMessage)
+			}
+		})
+	}
+}
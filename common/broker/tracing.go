@@ -0,0 +1,109 @@
+package broker
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/HJyup/translatify-common/broker"
+
+// HeaderCarrier lets the OpenTelemetry propagators read and write the trace
+// context in AMQP headers. Retry and DeadLetterDelivery copy the headers, so
+// a retried delivery stays in the trace of the original one.
+type HeaderCarrier amqp.Table
+
+func (c HeaderCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// StartPublish starts the producer span of publishing p to exchange and
+// writes its context into p's headers. The caller ends the span once the
+// broker has taken the message.
+func StartPublish(ctx context.Context, exchange, key string, p *amqp.Publishing) (context.Context, trace.Span) {
+	// The default exchange routes straight to the queue named by the key.
+	destination := exchange
+	if destination == "" {
+		destination = key
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "publish "+destination,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(destination, key, p.MessageId, p.CorrelationId, len(p.Body))...),
+		trace.WithAttributes(semconv.MessagingOperationTypePublish, semconv.MessagingOperationName("publish")),
+	)
+
+	if p.Headers == nil {
+		p.Headers = amqp.Table{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(p.Headers))
+	return ctx, span
+}
+
+// StartProcess starts the consumer span of handling d from queue, continuing
+// the trace its publisher wrote into the headers. The caller ends the span
+// once the delivery is settled.
+func StartProcess(ctx context.Context, queue string, d amqp.Delivery) (context.Context, trace.Span) {
+	if d.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(d.Headers))
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, "process "+queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messagingAttributes(queue, d.RoutingKey, d.MessageId, d.CorrelationId, len(d.Body))...),
+		trace.WithAttributes(semconv.MessagingOperationTypeDeliver, semconv.MessagingOperationName("process")),
+	)
+}
+
+func messagingAttributes(destination, key, messageID, correlationID string, size int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingDestinationName(destination),
+		semconv.MessagingMessageBodySize(size),
+	}
+	if key != "" {
+		attrs = append(attrs, semconv.MessagingRabbitmqDestinationRoutingKey(key))
+	}
+	if messageID != "" {
+		attrs = append(attrs, semconv.MessagingMessageID(messageID))
+	}
+	if correlationID != "" {
+		attrs = append(attrs, semconv.MessagingMessageConversationID(correlationID))
+	}
+	return attrs
+}
+
+// EndSpan ends a messaging span, marking it failed when err is set.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// publish sends p to exchange in its own producer span.
+func publish(ctx context.Context, ch *amqp.Channel, exchange, key string, p amqp.Publishing) error {
+	ctx, span := StartPublish(ctx, exchange, key, &p)
+	err := ch.PublishWithContext(ctx, exchange, key, false, false, p)
+	EndSpan(span, err)
+	return err
+}
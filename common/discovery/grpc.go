@@ -21,3 +21,9 @@ func ServiceConnection(ctx context.Context, serviceName string, registry Registr
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
 	)
 }
+
+// ServerOptions instrument a service's gRPC server, so its spans continue the
+// trace of the calling service.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}
+}
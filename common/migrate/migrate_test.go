@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		fileName      string
+		wantVersion   int64
+		wantName      string
+		wantDirection string
+		wantErr       bool
+	}{
+		{fileName: "0001_create_chats.up.sql", wantVersion: 1, wantName: "create_chats", wantDirection: "up"},
+		{fileName: "0012_add_index.down.sql", wantVersion: 12, wantName: "add_index", wantDirection: "down"},
+		{fileName: "3_v1.2_fix.up.sql", wantVersion: 3, wantName: "v1.2_fix", wantDirection: "up"},
+		{fileName: "0001_create_chats.sql", wantErr: true},
+		{fileName: "0001_create_chats.sideways.sql", wantErr: true},
+		{fileName: "0001.up.sql", wantErr: true},
+		{fileName: "abcd_name.up.sql", wantErr: true},
+		{fileName: "0000_name.up.sql", wantErr: true},
+		{fileName: "-1_name.up.sql", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			version, name, direction, err := parseFileName(tt.fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if version != tt.wantVersion || name != tt.wantName || direction != tt.wantDirection {
+				t.Errorf("parseFileName = %d, %q, %q, want %d, %q, %q", version, name, direction, tt.wantVersion, tt.wantName, tt.wantDirection)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "sorted by version",
+			fsys: fstest.MapFS{
+				"0010_second.up.sql":   file("up 10"),
+				"0002_first.up.sql":    file("up 2"),
+				"0002_first.down.sql":  file("down 2"),
+				"README.md":            file("ignored"),
+				"nested/0003_x.up.sql": file("ignored"),
+			},
+			want: []Migration{
+				{Version: 2, Name: "first", Up: "up 2", Down: "down 2"},
+				{Version: 10, Name: "second", Up: "up 10"},
+			},
+		},
+		{
+			name:    "down without up",
+			fsys:    fstest.MapFS{"0001_first.down.sql": file("down")},
+			wantErr: "has no up script",
+		},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"0001_first.up.sql":   file("up"),
+				"0001_other.down.sql": file("down"),
+			},
+			wantErr: "conflicting names",
+		},
+		{
+			name:    "bad file name",
+			fsys:    fstest.MapFS{"first.up.sql": file("up")},
+			wantErr: "must be named",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.fsys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,3 +12,10 @@ func EnvString(key string) string {
 	log.Fatalf("Required environment variable %s is not set or is empty", key)
 	return ""
 }
+
+func EnvStringDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
@@ -30,7 +30,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Create a new chat between two users.",
+                "description": "Create a new chat between two users. sourceLanguage is usernameA's language and targetLanguage is userNameB's; a missing one is taken from the user's profile. Messages are translated from the sender's language into the recipient's.",
                 "consumes": [
                     "application/json"
                 ],
@@ -190,6 +190,12 @@ const docTemplate = `{
                         "description": "Token for pagination",
                         "name": "pageToken",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Paging direction from pageToken: forward (default) or backward to load older messages",
+                        "name": "direction",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -307,7 +313,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Open a websocket connection to stream messages for a specific chat.",
+                "description": "Open a websocket connection to stream message events for a specific chat. Each frame is a models.StreamEvent whose type is message.created, message.translated or message.edited.",
                 "produces": [
                     "application/json"
                 ],
@@ -322,13 +328,442 @@ const docTemplate = `{
                         "name": "chatId",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resume after this message sequence; missed messages are replayed first",
+                        "name": "afterSequence",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Replay messages created since this timestamp (Unix epoch in seconds)",
+                        "name": "sinceTimestamp",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "101": {
                         "description": "Switching Protocols",
                         "schema": {
-                            "type": "string"
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/chats/{chatId}/messages/{messageId}/languages/{language}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Render a message in any language, e.g. to switch the display language. The original language returns the content itself; other languages than the receiver's are translated on first request and stored.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Get Message In Language",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO 639-1 language code, e.g. de",
+                        "name": "language",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Message in the language",
+                        "schema": {
+                            "$ref": "#/definitions/api.GetMessageTranslationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Translation not available yet",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/chats/{chatId}/messages/{messageId}/translation/stream": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Translate a message progressively as server-sent events. \"delta\" events carry text to append, the final \"done\" event carries the complete translation, which replaces the deltas and is stored on the message, and \"error\" events end a failed stream. Messages that are already translated get a single \"done\" event.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Stream Message Translation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of translation chunks",
+                        "schema": {
+                            "$ref": "#/definitions/api.TranslationChunk"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/chats/{chatId}/messages/{messageId}/translations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every translation a message received, oldest first, and which one is displayed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "List Translation Alternatives",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Translation alternatives",
+                        "schema": {
+                            "$ref": "#/definitions/api.ListTranslationAlternativesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Translate a message again, bypassing the translation cache, optionally in another style (\"formal\", \"casual\" or \"literal\") or with another provider. The new translation is displayed on the message; earlier ones stay available as alternatives.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Retranslate Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Style and provider",
+                        "name": "options",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.RetranslateMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "New translation",
+                        "schema": {
+                            "$ref": "#/definitions/api.RetranslateMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Message is not translated",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/chats/{chatId}/messages/{messageId}/translations/{alternativeId}/select": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Display an earlier translation of a message. Chat subscribers receive the change as a message.translated event.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "chats"
+                ],
+                "summary": "Select Translation Alternative",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chat ID",
+                        "name": "chatId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Alternative ID",
+                        "name": "alternativeId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated message",
+                        "schema": {
+                            "$ref": "#/definitions/api.SelectTranslationAlternativeResponse"
                         }
                     },
                     "400": {
@@ -349,6 +784,15 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "404": {
+                        "description": "Message or alternative not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -388,6 +832,12 @@ const docTemplate = `{
                         "description": "Pagination token",
                         "name": "pageToken",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Paging direction from pageToken: forward (default) or backward",
+                        "name": "direction",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -583,6 +1033,85 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/api/v1/users/{username}/translation-usage": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Report the tokens spent on translating a user's messages per chat, language pair and model, and where the user stands against the daily and monthly quota. Users can only see their own usage.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get Translation Usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix timestamp of the period start, defaults to the start of the month",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Unix timestamp of the period end, defaults to now",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only report this chat",
+                        "name": "chatId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Usage report",
+                        "schema": {
+                            "$ref": "#/definitions/api.GetUsageReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
@@ -598,11 +1127,11 @@ const docTemplate = `{
                     "type": "integer"
                 },
                 "source_language": {
-                    "description": "The language code of the original content (e.g., \"en\" for English).",
+                    "description": "The language code username_a writes and reads in (e.g., \"en\" for English).",
                     "type": "string"
                 },
                 "target_language": {
-                    "description": "The target language code for translation (e.g., \"es\" for Spanish).",
+                    "description": "The language code username_b writes and reads in (e.g., \"es\" for Spanish).",
                     "type": "string"
                 },
                 "username_a": {
@@ -626,10 +1155,18 @@ const docTemplate = `{
                     "description": "The original message content.",
                     "type": "string"
                 },
+                "detected_language": {
+                    "description": "Language detected when the message was sent; empty if detection failed.",
+                    "type": "string"
+                },
                 "message_id": {
                     "description": "Unique identifier for the message.",
                     "type": "string"
                 },
+                "mixed_language": {
+                    "description": "Whether the message mixes several languages.",
+                    "type": "boolean"
+                },
                 "receiver_username": {
                     "description": "The receiver's user ID.",
                     "type": "string"
@@ -638,13 +1175,49 @@ const docTemplate = `{
                     "description": "The sender's user ID.",
                     "type": "string"
                 },
+                "sequence": {
+                    "description": "Monotonic position of the message, usable as a StreamMessages resume cursor.",
+                    "type": "integer"
+                },
+                "source_language": {
+                    "description": "The language the message is translated from: the sender's, or a\nconfident detection that overrode it.",
+                    "type": "string"
+                },
                 "timestamp": {
                     "description": "Unix timestamp when the message was created.",
                     "type": "integer"
                 },
+                "translated_at": {
+                    "description": "Unix timestamp when the translation was stored; 0 until then.",
+                    "type": "integer"
+                },
                 "translated_content": {
                     "description": "The translated content (if applicable).",
                     "type": "string"
+                },
+                "translation_alternative_id": {
+                    "description": "The alternative shown as translated_content, if any.",
+                    "type": "string"
+                },
+                "translation_error": {
+                    "description": "Why the translation failed; only set when the status is failed.",
+                    "type": "string"
+                },
+                "translation_model": {
+                    "description": "The provider's model, for providers that serve several.",
+                    "type": "string"
+                },
+                "translation_provider": {
+                    "description": "The translation provider that produced translated_content, e.g. \"deepl\".",
+                    "type": "string"
+                },
+                "translation_status": {
+                    "description": "Where the message is in its translation lifecycle.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.TranslationStatus"
+                        }
+                    ]
                 }
             }
         },
@@ -687,6 +1260,33 @@ const docTemplate = `{
                 }
             }
         },
+        "api.GetMessageTranslationResponse": {
+            "type": "object",
+            "properties": {
+                "translation": {
+                    "$ref": "#/definitions/api.MessageTranslation"
+                }
+            }
+        },
+        "api.GetUsageReportResponse": {
+            "type": "object",
+            "properties": {
+                "quota": {
+                    "description": "Only set when the request names a user.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.QuotaStatus"
+                        }
+                    ]
+                },
+                "summaries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.UsageSummary"
+                    }
+                }
+            }
+        },
         "api.GetUserResponse": {
             "type": "object",
             "properties": {
@@ -712,7 +1312,22 @@ const docTemplate = `{
                     }
                 },
                 "next_page_token": {
-                    "description": "A token that can be used to retrieve the next page of results.",
+                    "description": "A token that can be used to retrieve the next page of results in the same direction.",
+                    "type": "string"
+                }
+            }
+        },
+        "api.ListTranslationAlternativesResponse": {
+            "type": "object",
+            "properties": {
+                "alternatives": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.TranslationAlternative"
+                    }
+                },
+                "selected_alternative_id": {
+                    "description": "The alternative currently displayed.",
                     "type": "string"
                 }
             }
@@ -724,7 +1339,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "next_page_token": {
-                    "description": "Token to retrieve the next page.",
+                    "description": "Token to retrieve the next page in the same direction.",
                     "type": "string"
                 },
                 "users": {
@@ -735,6 +1350,69 @@ const docTemplate = `{
                 }
             }
         },
+        "api.MessageTranslation": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "Unix timestamp when the translation was made.",
+                    "type": "integer"
+                },
+                "language": {
+                    "type": "string"
+                },
+                "message_id": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "translated_content": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.QuotaStatus": {
+            "type": "object",
+            "properties": {
+                "daily_limit": {
+                    "type": "integer"
+                },
+                "daily_used": {
+                    "type": "integer"
+                },
+                "exceeded": {
+                    "type": "boolean"
+                },
+                "monthly_limit": {
+                    "type": "integer"
+                },
+                "monthly_used": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.RetranslateMessageResponse": {
+            "type": "object",
+            "properties": {
+                "alternative": {
+                    "$ref": "#/definitions/api.TranslationAlternative"
+                },
+                "message": {
+                    "$ref": "#/definitions/api.ChatMessage"
+                }
+            }
+        },
+        "api.SelectTranslationAlternativeResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "$ref": "#/definitions/api.ChatMessage"
+                }
+            }
+        },
         "api.SendMessageResponse": {
             "type": "object",
             "properties": {
@@ -750,6 +1428,128 @@ const docTemplate = `{
                 }
             }
         },
+        "api.TranslationAlternative": {
+            "type": "object",
+            "properties": {
+                "alternative_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "description": "Unix timestamp when the translation was made.",
+                    "type": "integer"
+                },
+                "message_id": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "style": {
+                    "description": "The requested style; empty for the default.",
+                    "type": "string"
+                },
+                "translated_content": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.TranslationChunk": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "description": "Text to append to what was received so far.",
+                    "type": "string"
+                },
+                "done": {
+                    "description": "Set on the last chunk, which carries the result instead of a delta.",
+                    "type": "boolean"
+                },
+                "glossary_applied": {
+                    "description": "Glossary terms that were rendered as the glossary demands; last chunk only.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "glossary_violations": {
+                    "description": "Glossary terms the translation failed to keep; last chunk only.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message_id": {
+                    "description": "Unique identifier of the message being translated, if any.",
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "provider": {
+                    "description": "The provider and model that produced the translation; last chunk only.",
+                    "type": "string"
+                },
+                "translated_content": {
+                    "description": "The complete translation; only on the last chunk. It may differ from the\njoined deltas, e.g. when a protected span did not survive, and replaces\nthem.",
+                    "type": "string"
+                }
+            }
+        },
+        "api.TranslationStatus": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4,
+                5
+            ],
+            "x-enum-varnames": [
+                "TranslationStatus_TRANSLATION_STATUS_UNSPECIFIED",
+                "TranslationStatus_TRANSLATION_STATUS_PENDING",
+                "TranslationStatus_TRANSLATION_STATUS_DONE",
+                "TranslationStatus_TRANSLATION_STATUS_FAILED",
+                "TranslationStatus_TRANSLATION_STATUS_SKIPPED",
+                "TranslationStatus_TRANSLATION_STATUS_DISABLED"
+            ]
+        },
+        "api.UsageSummary": {
+            "type": "object",
+            "properties": {
+                "chat_id": {
+                    "type": "string"
+                },
+                "completion_tokens": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "prompt_tokens": {
+                    "type": "integer"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "requests": {
+                    "description": "Number of billed translations.",
+                    "type": "integer"
+                },
+                "source_language": {
+                    "type": "string"
+                },
+                "target_language": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
         "api.User": {
             "type": "object",
             "properties": {
@@ -813,6 +1613,17 @@ const docTemplate = `{
                 }
             }
         },
+        "models.RetranslateMessageRequest": {
+            "type": "object",
+            "properties": {
+                "provider": {
+                    "type": "string"
+                },
+                "style": {
+                    "type": "string"
+                }
+            }
+        },
         "models.SendMessageRequest": {
             "type": "object",
             "properties": {
@@ -14,6 +14,7 @@ import (
 	"github.com/HJyup/translatify-common/discovery/consul"
 	"github.com/HJyup/translatify-common/tracer"
 	"github.com/HJyup/translatify-gateway/internal/gateway/chat"
+	"github.com/HJyup/translatify-gateway/internal/gateway/translation"
 	"github.com/HJyup/translatify-gateway/internal/gateway/user"
 	"github.com/HJyup/translatify-gateway/internal/handlers"
 	mux2 "github.com/gorilla/mux"
@@ -107,11 +108,16 @@ func main() {
 
 	router := mux2.NewRouter()
 
+	userGateway := user.NewGateway(registry)
+
 	chatGateway := chat.NewGateway(registry)
-	chatHandler := handlers.NewChatHandler(chatGateway)
+	chatHandler := handlers.NewChatHandler(chatGateway, userGateway)
 	chatHandler.RegisterRoutes(router)
 
-	userGateway := user.NewGateway(registry)
+	translationGateway := translation.NewGateway(registry)
+	translationHandler := handlers.NewTranslationHandler(chatGateway, translationGateway)
+	translationHandler.RegisterRoutes(router)
+
 	userHandler := handlers.NewUserHandler(userGateway)
 	userHandler.RegisterRoutes(router)
 
@@ -13,4 +13,8 @@ type Gateway interface {
 	StreamMessages(context.Context, *pb.StreamMessagesRequest) (pb.ChatService_StreamMessagesClient, error)
 	GetChat(context.Context, *pb.GetChatRequest) (*pb.GetChatResponse, error)
 	ListChats(context.Context, *pb.ListChatsRequest) (*pb.ListChatsResponse, error)
+	RetranslateMessage(context.Context, *pb.RetranslateMessageRequest) (*pb.RetranslateMessageResponse, error)
+	ListTranslationAlternatives(context.Context, *pb.ListTranslationAlternativesRequest) (*pb.ListTranslationAlternativesResponse, error)
+	SelectTranslationAlternative(context.Context, *pb.SelectTranslationAlternativeRequest) (*pb.SelectTranslationAlternativeResponse, error)
+	GetMessageTranslation(context.Context, *pb.GetMessageTranslationRequest) (*pb.GetMessageTranslationResponse, error)
 }
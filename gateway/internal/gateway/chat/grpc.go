@@ -2,6 +2,7 @@ package chat
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	pb "github.com/HJyup/translatify-common/api"
@@ -79,3 +80,43 @@ func (g *GrpcGateway) ListChats(ctx context.Context, payload *pb.ListChatsReques
 	chatClient := pb.NewChatServiceClient(conn)
 	return chatClient.ListChats(ctx, payload)
 }
+
+func (g *GrpcGateway) RetranslateMessage(ctx context.Context, payload *pb.RetranslateMessageRequest) (*pb.RetranslateMessageResponse, error) {
+	conn, err := discovery.ServiceConnection(ctx, "chat", g.registry)
+	if err != nil {
+		return nil, fmt.Errorf("connect to chat service: %w", err)
+	}
+	defer conn.Close()
+	chatClient := pb.NewChatServiceClient(conn)
+	return chatClient.RetranslateMessage(ctx, payload)
+}
+
+func (g *GrpcGateway) ListTranslationAlternatives(ctx context.Context, payload *pb.ListTranslationAlternativesRequest) (*pb.ListTranslationAlternativesResponse, error) {
+	conn, err := discovery.ServiceConnection(ctx, "chat", g.registry)
+	if err != nil {
+		return nil, fmt.Errorf("connect to chat service: %w", err)
+	}
+	defer conn.Close()
+	chatClient := pb.NewChatServiceClient(conn)
+	return chatClient.ListTranslationAlternatives(ctx, payload)
+}
+
+func (g *GrpcGateway) SelectTranslationAlternative(ctx context.Context, payload *pb.SelectTranslationAlternativeRequest) (*pb.SelectTranslationAlternativeResponse, error) {
+	conn, err := discovery.ServiceConnection(ctx, "chat", g.registry)
+	if err != nil {
+		return nil, fmt.Errorf("connect to chat service: %w", err)
+	}
+	defer conn.Close()
+	chatClient := pb.NewChatServiceClient(conn)
+	return chatClient.SelectTranslationAlternative(ctx, payload)
+}
+
+func (g *GrpcGateway) GetMessageTranslation(ctx context.Context, payload *pb.GetMessageTranslationRequest) (*pb.GetMessageTranslationResponse, error) {
+	conn, err := discovery.ServiceConnection(ctx, "chat", g.registry)
+	if err != nil {
+		return nil, fmt.Errorf("connect to chat service: %w", err)
+	}
+	defer conn.Close()
+	chatClient := pb.NewChatServiceClient(conn)
+	return chatClient.GetMessageTranslation(ctx, payload)
+}
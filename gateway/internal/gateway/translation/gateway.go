@@ -0,0 +1,11 @@
+package translation
+
+import (
+	"context"
+	pb "github.com/HJyup/translatify-common/api"
+)
+
+type Gateway interface {
+	StreamTranslate(ctx context.Context, payload *pb.TranslationRequest) (pb.TranslationService_StreamTranslateClient, error)
+	GetUsageReport(ctx context.Context, payload *pb.GetUsageReportRequest) (*pb.GetUsageReportResponse, error)
+}
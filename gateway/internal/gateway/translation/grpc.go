@@ -0,0 +1,47 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/HJyup/translatify-common/api"
+	"github.com/HJyup/translatify-common/discovery"
+)
+
+type GrpcGateway struct {
+	registry discovery.Registry
+}
+
+func NewGateway(registry discovery.Registry) *GrpcGateway {
+	return &GrpcGateway{registry: registry}
+}
+
+// StreamTranslate keeps its connection open until ctx ends, which for an HTTP
+// handler is when the request finishes.
+func (g *GrpcGateway) StreamTranslate(ctx context.Context, payload *pb.TranslationRequest) (pb.TranslationService_StreamTranslateClient, error) {
+	conn, err := discovery.ServiceConnection(ctx, "translation", g.registry)
+	if err != nil {
+		return nil, fmt.Errorf("connect to translation service: %w", err)
+	}
+	translationClient := pb.NewTranslationServiceClient(conn)
+	stream, err := translationClient.StreamTranslate(ctx, payload)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return stream, nil
+}
+
+func (g *GrpcGateway) GetUsageReport(ctx context.Context, payload *pb.GetUsageReportRequest) (*pb.GetUsageReportResponse, error) {
+	conn, err := discovery.ServiceConnection(ctx, "translation", g.registry)
+	if err != nil {
+		return nil, fmt.Errorf("connect to translation service: %w", err)
+	}
+	defer conn.Close()
+	translationClient := pb.NewTranslationServiceClient(conn)
+	return translationClient.GetUsageReport(ctx, payload)
+}
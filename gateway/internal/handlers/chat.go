@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"github.com/HJyup/translatify-common/api"
 	"github.com/HJyup/translatify-common/utils"
 	"github.com/HJyup/translatify-gateway/internal/gateway/chat"
+	"github.com/HJyup/translatify-gateway/internal/gateway/user"
 	"github.com/HJyup/translatify-gateway/internal/models"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
@@ -25,10 +27,11 @@ var upgrader = websocket.Upgrader{
 
 type ChatHandler struct {
 	gateway chat.Gateway
+	users   user.Gateway
 }
 
-func NewChatHandler(gateway chat.Gateway) *ChatHandler {
-	return &ChatHandler{gateway: gateway}
+func NewChatHandler(gateway chat.Gateway, users user.Gateway) *ChatHandler {
+	return &ChatHandler{gateway: gateway, users: users}
 }
 
 func (h *ChatHandler) RegisterRoutes(router *mux.Router) {
@@ -40,6 +43,28 @@ func (h *ChatHandler) RegisterRoutes(router *mux.Router) {
 	chatRouter.Handle("/{chatId}/messages/stream", utils.TokenAuthMiddleware(http.HandlerFunc(h.HandleStreamMessages))).Methods("GET")
 }
 
+func streamEventFromProto(event *api.ChatEvent) models.StreamEvent {
+	switch e := event.GetEvent().(type) {
+	case *api.ChatEvent_MessageTranslated:
+		return models.StreamEvent{Type: "message.translated", Message: e.MessageTranslated}
+	case *api.ChatEvent_MessageEdited:
+		return models.StreamEvent{Type: "message.edited", Message: e.MessageEdited}
+	default:
+		return models.StreamEvent{Type: "message.created", Message: event.GetMessageCreated()}
+	}
+}
+
+func parseDirection(value string) (api.PageDirection, error) {
+	switch value {
+	case "", "forward":
+		return api.PageDirection_PAGE_DIRECTION_FORWARD, nil
+	case "backward":
+		return api.PageDirection_PAGE_DIRECTION_BACKWARD, nil
+	default:
+		return 0, fmt.Errorf("invalid direction %q", value)
+	}
+}
+
 func extractUsername(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -56,9 +81,23 @@ func extractUsername(r *http.Request) (string, error) {
 	return claims.UserName, nil
 }
 
+// userLanguage returns the requested language, or the one stored on the
+// user's profile when none was requested.
+func (h *ChatHandler) userLanguage(ctx context.Context, username, requested string) (string, error) {
+	if requested != "" {
+		return requested, nil
+	}
+
+	resp, err := h.users.GetUser(ctx, &api.GetUserRequest{Username: username})
+	if err != nil || resp.GetUser().GetLanguage() == "" {
+		return "", fmt.Errorf("no language given or stored for user %s", username)
+	}
+	return resp.GetUser().GetLanguage(), nil
+}
+
 // HandleCreateChat godoc
 // @Summary Create Chat
-// @Description Create a new chat between two users.
+// @Description Create a new chat between two users. sourceLanguage is usernameA's language and targetLanguage is userNameB's; a missing one is taken from the user's profile. Messages are translated from the sender's language into the recipient's.
 // @Tags chats
 // @Security BearerAuth
 // @Accept json
@@ -86,11 +125,21 @@ func (h *ChatHandler) HandleCreateChat(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	sourceLanguage, err := h.userLanguage(r.Context(), reqBody.UserNameA, reqBody.SourceLanguage)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	targetLanguage, err := h.userLanguage(r.Context(), reqBody.UserNameB, reqBody.TargetLanguage)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	req := &api.CreateChatRequest{
 		UsernameA:      reqBody.UserNameA,
 		UsernameB:      reqBody.UserNameB,
-		SourceLanguage: reqBody.SourceLanguage,
-		TargetLanguage: reqBody.TargetLanguage,
+		SourceLanguage: sourceLanguage,
+		TargetLanguage: targetLanguage,
 	}
 	resp, err := h.gateway.CreateChat(r.Context(), req)
 	if err != nil {
@@ -143,6 +192,7 @@ func (h *ChatHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 // @Param sinceTimestamp query int false "Since timestamp (Unix epoch in seconds)"
 // @Param limit query int false "Maximum number of messages to return"
 // @Param pageToken query string false "Token for pagination"
+// @Param direction query string false "Paging direction from pageToken: forward (default) or backward to load older messages"
 // @Success 200 {object} api.ListMessagesResponse "List of messages"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 401 {object} map[string]string "Unauthorized"
@@ -170,6 +220,11 @@ func (h *ChatHandler) HandleListMessages(w http.ResponseWriter, r *http.Request)
 	sinceStr := q.Get("sinceTimestamp")
 	limitStr := q.Get("limit")
 	pageToken := q.Get("pageToken")
+	direction, err := parseDirection(q.Get("direction"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	var sinceTimestamp int64
 	if sinceStr != "" {
 		var err error
@@ -193,6 +248,7 @@ func (h *ChatHandler) HandleListMessages(w http.ResponseWriter, r *http.Request)
 		SinceTimestamp: sinceTimestamp,
 		Limit:          limit,
 		PageToken:      pageToken,
+		Direction:      direction,
 	}
 	resp, err := h.gateway.ListMessages(r.Context(), req)
 	if err != nil {
@@ -204,11 +260,13 @@ func (h *ChatHandler) HandleListMessages(w http.ResponseWriter, r *http.Request)
 
 // HandleStreamMessages godoc
 // @Summary Stream Messages
-// @Description Open a websocket connection to stream messages for a specific chat.
+// @Description Open a websocket connection to stream message events for a specific chat. Each frame is a models.StreamEvent whose type is message.created, message.translated or message.edited.
 // @Tags chats
 // @Security BearerAuth
 // @Produce json
 // @Param chatId path string true "Chat ID"
+// @Param afterSequence query int false "Resume after this message sequence; missed messages are replayed first"
+// @Param sinceTimestamp query int false "Replay messages created since this timestamp (Unix epoch in seconds)"
 // @Success 101 {string} string "Switching Protocols"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 401 {object} map[string]string "Unauthorized"
@@ -232,6 +290,20 @@ func (h *ChatHandler) HandleStreamMessages(w http.ResponseWriter, r *http.Reques
 		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	streamReq := models.StreamMessagesRequest{ChatId: chatId}
+	q := r.URL.Query()
+	if afterStr := q.Get("afterSequence"); afterStr != "" {
+		if streamReq.AfterSequence, err = strconv.ParseInt(afterStr, 10, 64); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "invalid afterSequence")
+			return
+		}
+	}
+	if sinceStr := q.Get("sinceTimestamp"); sinceStr != "" {
+		if streamReq.SinceTimestamp, err = strconv.ParseInt(sinceStr, 10, 64); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "invalid sinceTimestamp")
+			return
+		}
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "failed to upgrade connection: "+err.Error())
@@ -239,7 +311,9 @@ func (h *ChatHandler) HandleStreamMessages(w http.ResponseWriter, r *http.Reques
 	}
 	defer conn.Close()
 	req := &api.StreamMessagesRequest{
-		ChatId: chatId,
+		ChatId:         streamReq.ChatId,
+		AfterSequence:  streamReq.AfterSequence,
+		SinceTimestamp: streamReq.SinceTimestamp,
 	}
 	grpcStream, err := h.gateway.StreamMessages(r.Context(), req)
 	if err != nil {
@@ -247,12 +321,12 @@ func (h *ChatHandler) HandleStreamMessages(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	for {
-		msg, err := grpcStream.Recv()
+		event, err := grpcStream.Recv()
 		if err != nil {
 			conn.WriteJSON(map[string]string{"error": err.Error()})
 			return
 		}
-		if err := conn.WriteJSON(msg); err != nil {
+		if err := conn.WriteJSON(streamEventFromProto(event)); err != nil {
 			return
 		}
 	}
@@ -0,0 +1,26 @@
+package handlers
+
+import "testing"
+
+func TestValidLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		want     bool
+	}{
+		{language: "de", want: true},
+		{language: "JA", want: true},
+		{language: "zu", want: true},
+		{language: "", want: false},
+		{language: "xx", want: false},
+		{language: "deu", want: false},
+		{language: "pt-BR", want: false},
+		{language: "en%20", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			if got := validLanguage(tt.language); got != tt.want {
+				t.Errorf("validLanguage(%q) = %v, want %v", tt.language, got, tt.want)
+			}
+		})
+	}
+}
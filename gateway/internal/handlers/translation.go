@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/HJyup/translatify-common/api"
+	"github.com/HJyup/translatify-common/utils"
+	"github.com/HJyup/translatify-gateway/internal/gateway/chat"
+	"github.com/HJyup/translatify-gateway/internal/gateway/translation"
+	"github.com/HJyup/translatify-gateway/internal/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type TranslationHandler struct {
+	chats        chat.Gateway
+	translations translation.Gateway
+}
+
+func NewTranslationHandler(chats chat.Gateway, translations translation.Gateway) *TranslationHandler {
+	return &TranslationHandler{chats: chats, translations: translations}
+}
+
+func (h *TranslationHandler) RegisterRoutes(router *mux.Router) {
+	chatRouter := router.PathPrefix("/api/v1/chats").Subrouter()
+	chatRouter.Handle("/{chatId}/messages/{messageId}/translation/stream", utils.TokenAuthMiddleware(http.HandlerFunc(h.HandleStreamTranslation))).Methods("GET")
+	chatRouter.Handle("/{chatId}/messages/{messageId}/translations", utils.TokenAuthMiddleware(http.HandlerFunc(h.HandleRetranslateMessage))).Methods("POST")
+	chatRouter.Handle("/{chatId}/messages/{messageId}/translations", utils.TokenAuthMiddleware(http.HandlerFunc(h.HandleListTranslationAlternatives))).Methods("GET")
+	chatRouter.Handle("/{chatId}/messages/{messageId}/translations/{alternativeId}/select", utils.TokenAuthMiddleware(http.HandlerFunc(h.HandleSelectTranslationAlternative))).Methods("POST")
+	chatRouter.Handle("/{chatId}/messages/{messageId}/languages/{language}", utils.TokenAuthMiddleware(http.HandlerFunc(h.HandleGetMessageTranslation))).Methods("GET")
+
+	userRouter := router.PathPrefix("/api/v1/users").Subrouter()
+	userRouter.Handle("/{username}/translation-usage", utils.TokenAuthMiddleware(http.HandlerFunc(h.HandleGetTranslationUsage))).Methods("GET")
+}
+
+// authorizeMessage loads the chat and message of the request and checks that
+// the caller, whom it returns as well, takes part in the chat. It writes the
+// error response itself.
+func (h *TranslationHandler) authorizeMessage(w http.ResponseWriter, r *http.Request) (*api.Chat, *api.ChatMessage, string, bool) {
+	vars := mux.Vars(r)
+	chatId, messageId := vars["chatId"], vars["messageId"]
+	if chatId == "" || messageId == "" {
+		utils.WriteError(w, http.StatusBadRequest, "chatId and messageId are required")
+		return nil, nil, "", false
+	}
+	chatResp, err := h.chats.GetChat(r.Context(), &api.GetChatRequest{ChatId: chatId})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, "", false
+	}
+	tokenUsername, err := extractUsername(r)
+	if err != nil || (tokenUsername != chatResp.Chat.UsernameA && tokenUsername != chatResp.Chat.UsernameB) {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil, nil, "", false
+	}
+	msgResp, err := h.chats.GetMessage(r.Context(), &api.GetMessageRequest{MessageId: messageId})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, "", false
+	}
+	msg := msgResp.GetMessage()
+	if msg == nil || msg.GetChatId() != chatId {
+		utils.WriteError(w, http.StatusNotFound, "message not found")
+		return nil, nil, "", false
+	}
+	return chatResp.Chat, msg, tokenUsername, true
+}
+
+// writeGrpcError reports rejected requests as client errors rather than 500s.
+func writeGrpcError(w http.ResponseWriter, err error) {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		utils.WriteError(w, http.StatusBadRequest, status.Convert(err).Message())
+	case codes.NotFound:
+		utils.WriteError(w, http.StatusNotFound, status.Convert(err).Message())
+	case codes.FailedPrecondition:
+		utils.WriteError(w, http.StatusConflict, status.Convert(err).Message())
+	case codes.ResourceExhausted:
+		utils.WriteError(w, http.StatusTooManyRequests, status.Convert(err).Message())
+	case codes.Unavailable:
+		utils.WriteError(w, http.StatusServiceUnavailable, status.Convert(err).Message())
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeEvent sends one server-sent event and flushes it to the client.
+func writeEvent(w io.Writer, flusher http.Flusher, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// HandleStreamTranslation godoc
+// @Summary Stream Message Translation
+// @Description Translate a message progressively as server-sent events. "delta" events carry text to append, the final "done" event carries the complete translation, which replaces the deltas and is stored on the message, and "error" events end a failed stream. Messages that are already translated get a single "done" event.
+// @Tags chats
+// @Security BearerAuth
+// @Produce text/event-stream
+// @Param chatId path string true "Chat ID"
+// @Param messageId path string true "Message ID"
+// @Success 200 {object} api.TranslationChunk "Stream of translation chunks"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Message not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/chats/{chatId}/messages/{messageId}/translation/stream [get]
+func (h *TranslationHandler) HandleStreamTranslation(w http.ResponseWriter, r *http.Request) {
+	chatInfo, msg, caller, ok := h.authorizeMessage(w, r)
+	if !ok {
+		return
+	}
+	chatId, messageId := chatInfo.GetChatId(), msg.GetMessageId()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.WriteError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// The source language the chat service sent the message with, so the
+	// stream matches the queued translation, into the receiver's language.
+	languageOf := func(username string) string {
+		if username == chatInfo.GetUsernameA() {
+			return chatInfo.GetSourceLanguage()
+		}
+		return chatInfo.GetTargetLanguage()
+	}
+	sourceLanguage := msg.GetSourceLanguage()
+	if sourceLanguage == "" {
+		sourceLanguage = languageOf(msg.GetSenderUsername())
+	}
+	targetLanguage := languageOf(msg.GetReceiverUsername())
+
+	switch {
+	case msg.GetTranslatedContent() != "":
+		_ = writeEvent(w, flusher, "done", &api.TranslationChunk{MessageId: messageId, Done: true, TranslatedContent: msg.GetTranslatedContent()})
+		return
+	case msg.GetTranslationStatus() == api.TranslationStatus_TRANSLATION_STATUS_SKIPPED, strings.EqualFold(sourceLanguage, targetLanguage):
+		_ = writeEvent(w, flusher, "done", &api.TranslationChunk{MessageId: messageId, Done: true, TranslatedContent: msg.GetContent()})
+		return
+	}
+
+	grpcStream, err := h.translations.StreamTranslate(r.Context(), &api.TranslationRequest{
+		MessageId:      messageId,
+		Content:        msg.GetContent(),
+		SourceLanguage: sourceLanguage,
+		TargetLanguage: targetLanguage,
+		ChatId:         chatId,
+		Participants:   []string{msg.GetSenderUsername(), msg.GetReceiverUsername()},
+		Username:       caller,
+	})
+	if err != nil {
+		_ = writeEvent(w, flusher, "error", map[string]string{"error": err.Error()})
+		return
+	}
+	for {
+		chunk, err := grpcStream.Recv()
+		if err != nil {
+			_ = writeEvent(w, flusher, "error", map[string]string{"error": err.Error()})
+			return
+		}
+		event := "delta"
+		if chunk.GetDone() {
+			event = "done"
+		}
+		if err = writeEvent(w, flusher, event, chunk); err != nil || chunk.GetDone() {
+			return
+		}
+	}
+}
+
+// HandleRetranslateMessage godoc
+// @Summary Retranslate Message
+// @Description Translate a message again, bypassing the translation cache, optionally in another style ("formal", "casual" or "literal") or with another provider. The new translation is displayed on the message; earlier ones stay available as alternatives.
+// @Tags chats
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param chatId path string true "Chat ID"
+// @Param messageId path string true "Message ID"
+// @Param options body models.RetranslateMessageRequest false "Style and provider"
+// @Success 200 {object} api.RetranslateMessageResponse "New translation"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Message not found"
+// @Failure 409 {object} map[string]string "Message is not translated"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/chats/{chatId}/messages/{messageId}/translations [post]
+func (h *TranslationHandler) HandleRetranslateMessage(w http.ResponseWriter, r *http.Request) {
+	_, msg, caller, ok := h.authorizeMessage(w, r)
+	if !ok {
+		return
+	}
+
+	var reqBody models.RetranslateMessageRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	defer r.Body.Close()
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &reqBody); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+
+	resp, err := h.chats.RetranslateMessage(r.Context(), &api.RetranslateMessageRequest{
+		MessageId: msg.GetMessageId(),
+		Style:     reqBody.Style,
+		Provider:  reqBody.Provider,
+		Username:  caller,
+	})
+	if err != nil {
+		writeGrpcError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleListTranslationAlternatives godoc
+// @Summary List Translation Alternatives
+// @Description List every translation a message received, oldest first, and which one is displayed.
+// @Tags chats
+// @Security BearerAuth
+// @Produce json
+// @Param chatId path string true "Chat ID"
+// @Param messageId path string true "Message ID"
+// @Success 200 {object} api.ListTranslationAlternativesResponse "Translation alternatives"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Message not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/chats/{chatId}/messages/{messageId}/translations [get]
+func (h *TranslationHandler) HandleListTranslationAlternatives(w http.ResponseWriter, r *http.Request) {
+	_, msg, _, ok := h.authorizeMessage(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.chats.ListTranslationAlternatives(r.Context(), &api.ListTranslationAlternativesRequest{MessageId: msg.GetMessageId()})
+	if err != nil {
+		writeGrpcError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleSelectTranslationAlternative godoc
+// @Summary Select Translation Alternative
+// @Description Display an earlier translation of a message. Chat subscribers receive the change as a message.translated event.
+// @Tags chats
+// @Security BearerAuth
+// @Produce json
+// @Param chatId path string true "Chat ID"
+// @Param messageId path string true "Message ID"
+// @Param alternativeId path string true "Alternative ID"
+// @Success 200 {object} api.SelectTranslationAlternativeResponse "Updated message"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Message or alternative not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/chats/{chatId}/messages/{messageId}/translations/{alternativeId}/select [post]
+func (h *TranslationHandler) HandleSelectTranslationAlternative(w http.ResponseWriter, r *http.Request) {
+	_, msg, _, ok := h.authorizeMessage(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.chats.SelectTranslationAlternative(r.Context(), &api.SelectTranslationAlternativeRequest{
+		MessageId:     msg.GetMessageId(),
+		AlternativeId: mux.Vars(r)["alternativeId"],
+	})
+	if err != nil {
+		writeGrpcError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleGetMessageTranslation godoc
+// @Summary Get Message In Language
+// @Description Render a message in any language, e.g. to switch the display language. The original language returns the content itself; other languages than the receiver's are translated on first request and stored.
+// @Tags chats
+// @Security BearerAuth
+// @Produce json
+// @Param chatId path string true "Chat ID"
+// @Param messageId path string true "Message ID"
+// @Param language path string true "ISO 639-1 language code, e.g. de"
+// @Success 200 {object} api.GetMessageTranslationResponse "Message in the language"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Message not found"
+// @Failure 409 {object} map[string]string "Translation not available yet"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/chats/{chatId}/messages/{messageId}/languages/{language} [get]
+func (h *TranslationHandler) HandleGetMessageTranslation(w http.ResponseWriter, r *http.Request) {
+	language := mux.Vars(r)["language"]
+	if !validLanguage(language) {
+		utils.WriteError(w, http.StatusBadRequest, "language must be an ISO 639-1 code")
+		return
+	}
+
+	_, msg, caller, ok := h.authorizeMessage(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.chats.GetMessageTranslation(r.Context(), &api.GetMessageTranslationRequest{
+		MessageId: msg.GetMessageId(),
+		Language:  language,
+		Username:  caller,
+	})
+	if err != nil {
+		writeGrpcError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleGetTranslationUsage godoc
+// @Summary Get Translation Usage
+// @Description Report the tokens spent on translating a user's messages per chat, language pair and model, and where the user stands against the daily and monthly quota. Users can only see their own usage.
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param username path string true "Username"
+// @Param since query int false "Unix timestamp of the period start, defaults to the start of the month"
+// @Param until query int false "Unix timestamp of the period end, defaults to now"
+// @Param chatId query string false "Only report this chat"
+// @Success 200 {object} api.GetUsageReportResponse "Usage report"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/users/{username}/translation-usage [get]
+func (h *TranslationHandler) HandleGetTranslationUsage(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	tokenUsername, err := extractUsername(r)
+	if err != nil || tokenUsername != username {
+		utils.WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	now := time.Now().UTC()
+	req := &api.GetUsageReportRequest{
+		Username: username,
+		ChatId:   r.URL.Query().Get("chatId"),
+		Since:    time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Unix(),
+	}
+	for name, field := range map[string]*int64{"since": &req.Since, "until": &req.Until} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		if *field, err = strconv.ParseInt(value, 10, 64); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s", name))
+			return
+		}
+	}
+
+	resp, err := h.translations.GetUsageReport(r.Context(), req)
+	if err != nil {
+		writeGrpcError(w, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, resp)
+}
@@ -82,6 +82,7 @@ func (h *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param limit query int false "Maximum number of users to return"
 // @Param pageToken query string false "Pagination token"
+// @Param direction query string false "Paging direction from pageToken: forward (default) or backward"
 // @Success 200 {object} api.ListUsersResponse "List of users"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
@@ -104,10 +105,16 @@ func (h *UserHandler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	pageToken := r.URL.Query().Get("pageToken")
+	direction, err := parseDirection(r.URL.Query().Get("direction"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	resp, err := h.gateway.ListUsers(ctx, &api.ListUsersRequest{
 		Limit:     int32(limit),
 		PageToken: pageToken,
+		Direction: direction,
 	})
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
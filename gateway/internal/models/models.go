@@ -1,5 +1,7 @@
 package models
 
+import "github.com/HJyup/translatify-common/api"
+
 type CreateChatRequest struct {
 	UserNameA      string `json:"usernameA"`
 	UserNameB      string `json:"userNameB"`
@@ -13,17 +15,33 @@ type SendMessageRequest struct {
 	Content      string `json:"content"`
 }
 
+// RetranslateMessageRequest picks how a message is translated again. Both
+// fields are optional; Style is "formal", "casual" or "literal".
+type RetranslateMessageRequest struct {
+	Style    string `json:"style"`
+	Provider string `json:"provider"`
+}
+
 type ListMessagesRequest struct {
 	SinceTimestamp int64  `json:"sinceTimestamp"`
 	Limit          int32  `json:"limit"`
 	PageToken      string `json:"pageToken"`
+	Direction      string `json:"direction"`
 }
 
 type StreamMessagesRequest struct {
 	ChatId         string `json:"chatId"`
+	AfterSequence  int64  `json:"afterSequence"`
 	SinceTimestamp int64  `json:"sinceTimestamp"`
 }
 
+// StreamEvent is the websocket frame sent for every api.ChatEvent. Type is
+// one of "message.created", "message.translated" or "message.edited".
+type StreamEvent struct {
+	Type    string           `json:"type"`
+	Message *api.ChatMessage `json:"message"`
+}
+
 type CreateUserRequest struct {
 	UserName string `json:"username"`
 	Email    string `json:"email"`
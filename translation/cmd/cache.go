@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/HJyup/translatify-translation/internal/cache"
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// newCache opens the TRANSLATION_CACHE backend. The postgres backend migrates
+// its database on startup and may share it with the glossaries.
+func newCache(ctx context.Context) (*cache.Instrumented, func(), error) {
+	var (
+		backend models.TranslationCache
+		closer  = func() {}
+	)
+
+	switch cacheBackend {
+	case "memory":
+		backend = cache.NewMemoryCache()
+	case "postgres":
+		pool, err := openDatabase(ctx, cacheURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open the cache database: %w", err)
+		}
+		backend, closer = cache.NewPostgresCache(pool), pool.Close
+	case "redis":
+		redisCache, err := cache.NewRedisCache(ctx, cacheURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to the cache server: %w", err)
+		}
+		backend = redisCache
+		closer = func() {
+			if err := redisCache.Close(); err != nil {
+				log.Printf("Error closing cache connection: %v", err)
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown TRANSLATION_CACHE %q, expected memory, postgres or redis", cacheBackend)
+	}
+
+	instrumented, err := cache.NewInstrumented(cacheBackend, backend)
+	if err != nil {
+		closer()
+		return nil, nil, err
+	}
+	return instrumented, closer, nil
+}
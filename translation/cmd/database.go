@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/HJyup/translatify-common/migrate"
+	"github.com/HJyup/translatify-translation/internal/store"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// openDatabase connects to a Postgres database and applies the service's
+// migrations. The cache and the glossaries may point at the same database or
+// at different ones; every database gets the full schema.
+func openDatabase(ctx context.Context, url string) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	defer conn.Release()
+
+	migrator, err := migrate.NewMigrator(conn.Conn(), store.Migrations(), serviceName)
+	if err == nil {
+		err = migrator.Up(ctx)
+	}
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// openTranslationDatabase opens TRANSLATION_DATABASE_URL, which holds the
+// glossaries and the usage records. Without it both are kept in memory and
+// the returned pool is nil.
+func openTranslationDatabase(ctx context.Context) (*pgxpool.Pool, func(), error) {
+	if databaseURL == "" {
+		log.Printf("TRANSLATION_DATABASE_URL is not set, glossaries and usage are kept in memory")
+		return nil, func() {}, nil
+	}
+
+	pool, err := openDatabase(ctx, databaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open the translation database: %w", err)
+	}
+	return pool, pool.Close, nil
+}
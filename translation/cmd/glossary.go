@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/HJyup/translatify-translation/internal/store"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newGlossaryStore keeps glossaries in the translation database, or in memory
+// when no database is configured.
+func newGlossaryStore(pool *pgxpool.Pool) models.GlossaryStore {
+	if pool == nil {
+		return store.NewMemoryGlossaryStore()
+	}
+	return store.NewGlossaryStore(pool)
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -17,8 +18,8 @@ import (
 	common "github.com/HJyup/translatify-common/utils"
 	"github.com/HJyup/translatify-translation/internal/consumer"
 	"github.com/HJyup/translatify-translation/internal/handler"
+	"github.com/HJyup/translatify-translation/internal/placeholder"
 	"github.com/HJyup/translatify-translation/internal/service"
-	"github.com/HJyup/translatify-translation/internal/translator"
 	"google.golang.org/grpc"
 
 	_ "github.com/joho/godotenv/autoload"
@@ -30,14 +31,25 @@ var (
 	consulAddr  = common.EnvString("CONSUL_ADDR")
 	environment = common.EnvString("ENVIRONMENT")
 
-	openaiAPIKey = common.EnvString("OPENAI_API_KEY")
-
 	amqpUser = common.EnvString("AMQP_USER")
 	amqpPass = common.EnvString("AMQP_PASS")
 	amqpHost = common.EnvString("AMQP_HOST")
 	amqpPort = common.EnvString("AMQP_PORT")
 
 	jaegerAddr = common.EnvString("JAEGER_ADDR")
+
+	cacheBackend = common.EnvStringDefault("TRANSLATION_CACHE", "memory")
+	cacheURL     = common.EnvStringDefault("TRANSLATION_CACHE_URL", "")
+	cacheTTL     = common.EnvStringDefault("TRANSLATION_CACHE_TTL", "24h")
+
+	databaseURL = common.EnvStringDefault("TRANSLATION_DATABASE_URL", "")
+
+	batchSize    = common.EnvStringDefault("TRANSLATION_BATCH_SIZE", strconv.Itoa(consumer.DefaultBatchConfig.Size))
+	batchWait    = common.EnvStringDefault("TRANSLATION_BATCH_WAIT", consumer.DefaultBatchConfig.Wait.String())
+	batchTimeout = common.EnvStringDefault("TRANSLATION_BATCH_TIMEOUT", consumer.DefaultBatchConfig.Timeout.String())
+
+	workers  = common.EnvStringDefault("TRANSLATION_WORKERS", strconv.Itoa(consumer.DefaultPoolConfig.Workers))
+	prefetch = common.EnvStringDefault("TRANSLATION_PREFETCH", "0")
 )
 
 func main() {
@@ -51,6 +63,15 @@ func main() {
 		cancel()
 	}()
 
+	if len(os.Args) > 1 && os.Args[1] == "deadletters" {
+		ch, closeConn := broker.Connect(amqpUser, amqpPass, amqpHost, amqpPort)
+		defer closeConn()
+		if err := broker.RunDeadLetters(ctx, ch, broker.MessageSentEvent, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Failed to handle dead letters: %v", err)
+		}
+		return
+	}
+
 	tracerCfg := tracer.Config{
 		ServiceName:    serviceName,
 		ServiceVersion: "1.0.0",
@@ -106,18 +127,90 @@ func main() {
 	}()
 	defer registry.DeRegister(instanceID)
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(discovery.ServerOptions()...)
 	conn, err := net.Listen("tcp", grpcAddr)
 	if err != nil {
 		log.Fatalf("Failed to listen on %s: %v", grpcAddr, err)
 	}
 	defer conn.Close()
 
-	trans := translator.NewModel(openaiAPIKey)
-	srv := service.NewTranslationService(trans)
+	ttl, err := time.ParseDuration(cacheTTL)
+	if err != nil {
+		log.Fatalf("Invalid TRANSLATION_CACHE_TTL %q: %v", cacheTTL, err)
+	}
+
+	translationCache, closeCache, err := newCache(ctx)
+	if err != nil {
+		log.Fatalf("Failed to open the translation cache: %v", err)
+	}
+	defer closeCache()
+
+	trans, err := newTranslator(translationCache, ttl)
+	if err != nil {
+		log.Fatalf("Failed to configure translation providers: %v", err)
+	}
+	langDetector, err := newDetector(trans)
+	if err != nil {
+		log.Fatalf("Failed to configure language detection: %v", err)
+	}
+	quota, err := newQuotaPolicy(trans)
+	if err != nil {
+		log.Fatalf("Failed to configure translation quotas: %v", err)
+	}
+
+	database, closeDatabase, err := openTranslationDatabase(ctx)
+	if err != nil {
+		log.Fatalf("Failed to open the translation database: %v", err)
+	}
+	defer closeDatabase()
+
+	usage, err := newUsageStore(database, quota)
+	if err != nil {
+		log.Fatalf("Failed to configure translation quotas: %v", err)
+	}
 
-	cons := consumer.NewConsumer(srv)
-	go cons.Listen(ch)
+	srv := service.NewTranslationService(
+		placeholder.NewTranslator(trans),
+		langDetector,
+		translationCache,
+		newGlossaryStore(database),
+		usage,
+		quota,
+	)
+
+	batch := consumer.BatchConfig{}
+	if batch.Size, err = strconv.Atoi(batchSize); err != nil || batch.Size < 1 {
+		log.Fatalf("Invalid TRANSLATION_BATCH_SIZE %q", batchSize)
+	}
+	if batch.Wait, err = time.ParseDuration(batchWait); err != nil {
+		log.Fatalf("Invalid TRANSLATION_BATCH_WAIT %q: %v", batchWait, err)
+	}
+	if batch.Timeout, err = time.ParseDuration(batchTimeout); err != nil || batch.Timeout <= 0 {
+		log.Fatalf("Invalid TRANSLATION_BATCH_TIMEOUT %q", batchTimeout)
+	}
+
+	pool := consumer.PoolConfig{}
+	if pool.Workers, err = strconv.Atoi(workers); err != nil || pool.Workers < 1 {
+		log.Fatalf("Invalid TRANSLATION_WORKERS %q", workers)
+	}
+	if pool.Prefetch, err = strconv.Atoi(prefetch); err != nil || pool.Prefetch < 0 {
+		log.Fatalf("Invalid TRANSLATION_PREFETCH %q", prefetch)
+	}
+
+	cons := consumer.NewConsumer(srv, broker.DefaultRetryPolicy, batch, pool)
+	consumerDone := make(chan struct{})
+	go func() {
+		cons.Listen(ctx, ch)
+		close(consumerDone)
+	}()
+
+	// On shutdown the consumer drains its workers first, so their
+	// translations are published before the connection goes away.
+	go func() {
+		<-ctx.Done()
+		<-consumerDone
+		grpcServer.GracefulStop()
+	}()
 
 	handler.NewGrpcHandler(grpcServer, srv, ch)
 
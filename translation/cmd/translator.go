@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	common "github.com/HJyup/translatify-common/utils"
+	"github.com/HJyup/translatify-translation/internal/detector"
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/HJyup/translatify-translation/internal/placeholder"
+	"github.com/HJyup/translatify-translation/internal/translator"
+)
+
+// newTranslator builds the provider router from the environment.
+// TRANSLATION_PROVIDERS lists "name" or "name=kind" entries; each provider
+// reads <NAME>_API_KEY, <NAME>_BASE_URL and <NAME>_MODEL, and the limits of
+// its account from <NAME>_RPM, <NAME>_TPM, <NAME>_TIMEOUT,
+// <NAME>_BREAKER_FAILURES and <NAME>_BREAKER_COOLDOWN.
+func newTranslator(cache models.TranslationCache, cacheTTL time.Duration) (*translator.Router, error) {
+	providers := make(map[string]models.TranslatorModel)
+
+	var first string
+	for _, entry := range strings.Split(common.EnvStringDefault("TRANSLATION_PROVIDERS", "openai"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, kind, ok := strings.Cut(entry, "=")
+		if !ok {
+			kind = name
+		}
+
+		cfg, err := providerConfig(name)
+		if err != nil {
+			return nil, err
+		}
+		provider, err := translator.NewProvider(kind, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		providers[name] = provider
+		if first == "" {
+			first = name
+		}
+	}
+
+	if first == "" {
+		return nil, errors.New("TRANSLATION_PROVIDERS does not list any provider")
+	}
+
+	routes, err := translator.ParseRoutes(common.EnvStringDefault("TRANSLATION_ROUTES", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return translator.NewRouter(
+		providers,
+		common.EnvStringDefault("TRANSLATION_PRIMARY", first),
+		common.EnvStringDefault("TRANSLATION_FALLBACK", ""),
+		routes,
+		cache,
+		cacheTTL,
+		placeholder.Check,
+	)
+}
+
+func providerConfig(name string) (translator.ProviderConfig, error) {
+	prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	cfg := translator.ProviderConfig{
+		Name:    name,
+		APIKey:  common.EnvStringDefault(prefix+"_API_KEY", ""),
+		BaseURL: common.EnvStringDefault(prefix+"_BASE_URL", ""),
+		Model:   common.EnvStringDefault(prefix+"_MODEL", ""),
+	}
+
+	var err error
+	if cfg.RequestsPerMinute, err = envInt(prefix+"_RPM", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.TokensPerMinute, err = envInt(prefix+"_TPM", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.Timeout, err = envDuration(prefix+"_TIMEOUT", time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.BreakerFailures, err = envInt(prefix+"_BREAKER_FAILURES", 5); err != nil {
+		return cfg, err
+	}
+	if cfg.BreakerCooldown, err = envDuration(prefix+"_BREAKER_COOLDOWN", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func envInt(key string, fallback int) (int, error) {
+	value := common.EnvStringDefault(key, strconv.Itoa(fallback))
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return n, nil
+}
+
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := common.EnvStringDefault(key, fallback.String())
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return d, nil
+}
+
+// newDetector builds the local language detector. DETECTOR_LLM_PROVIDER names
+// a configured provider able to identify languages, which is then asked for
+// texts the local detector is less than DETECTOR_LLM_THRESHOLD sure about.
+func newDetector(router *translator.Router) (*detector.Detector, error) {
+	threshold, err := strconv.ParseFloat(common.EnvStringDefault("DETECTOR_LLM_THRESHOLD", "0.5"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DETECTOR_LLM_THRESHOLD: %w", err)
+	}
+
+	name := common.EnvStringDefault("DETECTOR_LLM_PROVIDER", "")
+	if name == "" {
+		return detector.New(nil, "", threshold), nil
+	}
+
+	provider, ok := router.Provider(name)
+	if !ok {
+		return nil, fmt.Errorf("DETECTOR_LLM_PROVIDER %q is not configured", name)
+	}
+	identifier, ok := provider.(detector.LanguageIdentifier)
+	if !ok {
+		return nil, fmt.Errorf("provider %q cannot identify languages", name)
+	}
+	return detector.New(identifier, name, threshold), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	common "github.com/HJyup/translatify-common/utils"
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/HJyup/translatify-translation/internal/store"
+	"github.com/HJyup/translatify-translation/internal/translator"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newUsageStore refuses to enforce quotas in memory: every replica would
+// count on its own and start from zero after a restart, so scaling out or
+// restarting would hand out fresh quota.
+func newUsageStore(pool *pgxpool.Pool, quota models.QuotaPolicy) (models.UsageStore, error) {
+	if pool != nil {
+		return store.NewUsageStore(pool), nil
+	}
+	if quota.Enabled() {
+		return nil, errors.New("quotas need TRANSLATION_DATABASE_URL to count usage across replicas and restarts")
+	}
+	return store.NewMemoryUsageStore(), nil
+}
+
+// newQuotaPolicy reads the per-user token limits. TRANSLATION_QUOTA_POLICY is
+// "reject" or "degrade"; degrading moves users over quota to
+// TRANSLATION_QUOTA_DEGRADE_PROVIDER, which must be configured.
+func newQuotaPolicy(router *translator.Router) (models.QuotaPolicy, error) {
+	var (
+		policy models.QuotaPolicy
+		err    error
+	)
+
+	daily := common.EnvStringDefault("TRANSLATION_QUOTA_DAILY_TOKENS", "0")
+	if policy.DailyTokens, err = strconv.ParseInt(daily, 10, 64); err != nil || policy.DailyTokens < 0 {
+		return policy, fmt.Errorf("invalid TRANSLATION_QUOTA_DAILY_TOKENS %q", daily)
+	}
+	monthly := common.EnvStringDefault("TRANSLATION_QUOTA_MONTHLY_TOKENS", "0")
+	if policy.MonthlyTokens, err = strconv.ParseInt(monthly, 10, 64); err != nil || policy.MonthlyTokens < 0 {
+		return policy, fmt.Errorf("invalid TRANSLATION_QUOTA_MONTHLY_TOKENS %q", monthly)
+	}
+
+	policy.Action = models.QuotaAction(common.EnvStringDefault("TRANSLATION_QUOTA_POLICY", string(models.QuotaReject)))
+	switch policy.Action {
+	case models.QuotaReject:
+	case models.QuotaDegrade:
+		policy.DegradeProvider = common.EnvStringDefault("TRANSLATION_QUOTA_DEGRADE_PROVIDER", "")
+		if _, ok := router.Provider(policy.DegradeProvider); !ok {
+			return policy, fmt.Errorf("TRANSLATION_QUOTA_DEGRADE_PROVIDER %q is not configured", policy.DegradeProvider)
+		}
+	default:
+		return policy, fmt.Errorf("unknown TRANSLATION_QUOTA_POLICY %q, expected reject or degrade", policy.Action)
+	}
+	return policy, nil
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Instrumented counts hits, misses and backend errors of a cache, both for
+// GetCacheStats and as the OpenTelemetry counter translation.cache.lookups.
+type Instrumented struct {
+	backend string
+	inner   models.TranslationCache
+
+	hits, misses, errors atomic.Int64
+
+	lookups metric.Int64Counter
+}
+
+func NewInstrumented(backend string, inner models.TranslationCache) (*Instrumented, error) {
+	lookups, err := otel.Meter("translation-cache").Int64Counter(
+		"translation.cache.lookups",
+		metric.WithDescription("Translation cache lookups by result"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Instrumented{backend: backend, inner: inner, lookups: lookups}, nil
+}
+
+func (c *Instrumented) record(ctx context.Context, result string) {
+	c.lookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("backend", c.backend),
+		attribute.String("result", result),
+	))
+}
+
+func (c *Instrumented) Get(ctx context.Context, key models.CacheKey) (string, bool, error) {
+	value, found, err := c.inner.Get(ctx, key)
+	switch {
+	case err != nil:
+		c.errors.Add(1)
+		c.record(ctx, "error")
+	case found:
+		c.hits.Add(1)
+		c.record(ctx, "hit")
+	default:
+		c.misses.Add(1)
+		c.record(ctx, "miss")
+	}
+	return value, found, err
+}
+
+// GetFirst looks the keys up like First and counts them as one lookup: a hit
+// if an accepted value was found, otherwise an error or a miss.
+func (c *Instrumented) GetFirst(ctx context.Context, keys []models.CacheKey, accept func(value string) bool) (int, string, error) {
+	i, value, err := First(ctx, c.inner, keys, accept)
+	switch {
+	case i >= 0:
+		c.hits.Add(1)
+		c.record(ctx, "hit")
+	case err != nil:
+		c.errors.Add(1)
+		c.record(ctx, "error")
+	default:
+		c.misses.Add(1)
+		c.record(ctx, "miss")
+	}
+	return i, value, err
+}
+
+// First returns the index and value of the first key whose entry accept
+// takes, or -1. Keys that fail to load are skipped; their errors are only
+// returned when nothing was found.
+func First(ctx context.Context, c models.TranslationCache, keys []models.CacheKey, accept func(value string) bool) (int, string, error) {
+	var errs []error
+	for i, key := range keys {
+		value, found, err := c.Get(ctx, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if found && accept(value) {
+			return i, value, nil
+		}
+	}
+	return -1, "", errors.Join(errs...)
+}
+
+func (c *Instrumented) Set(ctx context.Context, key models.CacheKey, translatedText string, ttl time.Duration) error {
+	err := c.inner.Set(ctx, key, translatedText, ttl)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	return err
+}
+
+func (c *Instrumented) InvalidatePair(ctx context.Context, sourceLang, targetLang string) (int64, error) {
+	return c.inner.InvalidatePair(ctx, sourceLang, targetLang)
+}
+
+func (c *Instrumented) Stats() models.CacheStats {
+	return models.CacheStats{
+		Backend: c.backend,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Errors:  c.errors.Load(),
+	}
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// brokenCache fails every read.
+type brokenCache struct {
+	MemoryCache
+}
+
+func (c *brokenCache) Get(ctx context.Context, key models.CacheKey) (string, bool, error) {
+	return "", false, errors.New("connection refused")
+}
+
+func TestInstrumentedGetFirst(t *testing.T) {
+	ctx := context.Background()
+	primary := models.CacheKey{Provider: "primary", SourceLang: "en", TargetLang: "de", Text: "hello"}
+	fallback := models.CacheKey{Provider: "fallback", SourceLang: "en", TargetLang: "de", Text: "hello"}
+	accept := func(value string) bool { return value != "broken" }
+
+	tests := []struct {
+		name      string
+		inner     models.TranslationCache
+		entries   map[models.CacheKey]string
+		wantIndex int
+		wantStats models.CacheStats
+	}{
+		{
+			name:      "both missing",
+			inner:     NewMemoryCache(),
+			wantIndex: -1,
+			wantStats: models.CacheStats{Misses: 1},
+		},
+		{
+			name:      "fallback found",
+			inner:     NewMemoryCache(),
+			entries:   map[models.CacheKey]string{fallback: "hallo"},
+			wantIndex: 1,
+			wantStats: models.CacheStats{Hits: 1},
+		},
+		{
+			name:      "primary rejected",
+			inner:     NewMemoryCache(),
+			entries:   map[models.CacheKey]string{primary: "broken", fallback: "hallo"},
+			wantIndex: 1,
+			wantStats: models.CacheStats{Hits: 1},
+		},
+		{
+			name:      "only rejected",
+			inner:     NewMemoryCache(),
+			entries:   map[models.CacheKey]string{primary: "broken"},
+			wantIndex: -1,
+			wantStats: models.CacheStats{Misses: 1},
+		},
+		{
+			name:      "backend down",
+			inner:     &brokenCache{},
+			wantIndex: -1,
+			wantStats: models.CacheStats{Errors: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.entries {
+				if err := tt.inner.Set(ctx, key, value, time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c, err := NewInstrumented("test", tt.inner)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i, _, err := c.GetFirst(ctx, []models.CacheKey{primary, fallback}, accept)
+			if i != tt.wantIndex {
+				t.Errorf("GetFirst index = %d (%v), want %d", i, err, tt.wantIndex)
+			}
+			tt.wantStats.Backend = "test"
+			if got := c.Stats(); got != tt.wantStats {
+				t.Errorf("Stats = %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
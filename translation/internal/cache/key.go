@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// pairPrefix groups every entry of a language pair, which is what
+// InvalidatePair matches on.
+func pairPrefix(sourceLang, targetLang string) string {
+	return strings.ToLower(sourceLang) + ":" + strings.ToLower(targetLang) + ":"
+}
+
+// storageKey is "<source>:<target>:<hash>", the hash covering everything else
+// that identifies the entry.
+func storageKey(key models.CacheKey) string {
+	hash := sha256.Sum256([]byte(key.Provider + "\x00" + key.Model + "\x00" + key.PromptVersion + "\x00" + key.Options + "\x00" + key.Text))
+	return pairPrefix(key.SourceLang, key.TargetLang) + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/patrickmn/go-cache"
+)
+
+// MemoryCache keeps entries in process; it is lost on restart and not shared
+// between replicas.
+type MemoryCache struct {
+	items *cache.Cache
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{items: cache.New(cache.NoExpiration, 10*time.Minute)}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key models.CacheKey) (string, bool, error) {
+	value, found := c.items.Get(storageKey(key))
+	if !found {
+		return "", false, nil
+	}
+	return value.(string), true, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key models.CacheKey, translatedText string, ttl time.Duration) error {
+	c.items.Set(storageKey(key), translatedText, ttl)
+	return nil
+}
+
+func (c *MemoryCache) InvalidatePair(_ context.Context, sourceLang, targetLang string) (int64, error) {
+	prefix := pairPrefix(sourceLang, targetLang)
+
+	var removed int64
+	for k := range c.items.Items() {
+		if strings.HasPrefix(k, prefix) {
+			c.items.Delete(k)
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresCache stores entries in the translation_cache table. Expired rows
+// are skipped on read and overwritten on the next write of the same key.
+type PostgresCache struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresCache(pool *pgxpool.Pool) *PostgresCache {
+	return &PostgresCache{pool: pool}
+}
+
+func (c *PostgresCache) Get(ctx context.Context, key models.CacheKey) (string, bool, error) {
+	query := `
+		SELECT translated_text
+		FROM translation_cache
+		WHERE cache_key = $1 AND (expires_at IS NULL OR expires_at > now())
+	`
+	var value string
+	err := c.pool.QueryRow(ctx, query, storageKey(key)).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (c *PostgresCache) Set(ctx context.Context, key models.CacheKey, translatedText string, ttl time.Duration) error {
+	query := `
+		INSERT INTO translation_cache
+			(cache_key, provider, model, prompt_version, source_lang, target_lang, translated_text, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (cache_key) DO UPDATE
+		SET translated_text = EXCLUDED.translated_text, expires_at = EXCLUDED.expires_at
+	`
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	_, err := c.pool.Exec(ctx, query,
+		storageKey(key),
+		key.Provider,
+		key.Model,
+		key.PromptVersion,
+		strings.ToLower(key.SourceLang),
+		strings.ToLower(key.TargetLang),
+		translatedText,
+		expiresAt,
+	)
+	return err
+}
+
+func (c *PostgresCache) InvalidatePair(ctx context.Context, sourceLang, targetLang string) (int64, error) {
+	tag, err := c.pool.Exec(ctx,
+		"DELETE FROM translation_cache WHERE source_lang = $1 AND target_lang = $2",
+		strings.ToLower(sourceLang), strings.ToLower(targetLang),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
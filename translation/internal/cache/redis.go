@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "translation:"
+
+// RedisCache works with any server speaking the Redis protocol, such as
+// Redis, Valkey or KeyDB.
+type RedisCache struct {
+	client *redis.Client
+}
+
+func NewRedisCache(ctx context.Context, url string) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	if err = client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &RedisCache{client: client}, nil
+}
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+func (c *RedisCache) Get(ctx context.Context, key models.CacheKey) (string, bool, error) {
+	value, err := c.client.Get(ctx, redisKeyPrefix+storageKey(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key models.CacheKey, translatedText string, ttl time.Duration) error {
+	return c.client.Set(ctx, redisKeyPrefix+storageKey(key), translatedText, ttl).Err()
+}
+
+func (c *RedisCache) InvalidatePair(ctx context.Context, sourceLang, targetLang string) (int64, error) {
+	pattern := redisKeyPrefix + pairPrefix(sourceLang, targetLang) + "*"
+
+	var removed int64
+	iter := c.client.Scan(ctx, 0, pattern, 500).Iterator()
+	batch := make([]string, 0, 500)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			n, err := c.client.Unlink(ctx, batch...).Result()
+			if err != nil {
+				return removed, err
+			}
+			removed += n
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, err
+	}
+
+	if len(batch) > 0 {
+		n, err := c.client.Unlink(ctx, batch...).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
@@ -2,70 +2,420 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"github.com/HJyup/translatify-common/broker"
+	"github.com/HJyup/translatify-translation/internal/events"
 	"github.com/HJyup/translatify-translation/internal/models"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// BatchConfig controls micro-batching: deliveries are collected until Size
+// messages are pending or Wait has passed since the first one arrived.
+// Timeout bounds the translation of a batch, fallbacks included.
+type BatchConfig struct {
+	Size    int
+	Wait    time.Duration
+	Timeout time.Duration
+}
+
+var DefaultBatchConfig = BatchConfig{Size: 20, Wait: 200 * time.Millisecond, Timeout: 2 * time.Minute}
+
+// PoolConfig sizes the worker pool. Messages are partitioned on their chat,
+// so one chat's messages are translated and published in the order they
+// arrive while different chats proceed in parallel. While a message is away
+// for a requeue or retry, its worker holds back the later messages of its
+// chat until it returns. Prefetch bounds the unacknowledged deliveries over
+// all workers; zero gives every worker room for a full batch.
+type PoolConfig struct {
+	Workers  int
+	Prefetch int
+}
+
+var DefaultPoolConfig = PoolConfig{Workers: 4}
+
+// consumerTag names the subscription so it can be cancelled on shutdown.
+const consumerTag = "translation"
+
+const (
+	// drainGrace is how long the batches in flight get to finish on
+	// shutdown before they are cancelled.
+	drainGrace = 20 * time.Second
+	// settleTimeout bounds publishing the outcome of one message.
+	settleTimeout = 10 * time.Second
 )
 
 type Consumer struct {
 	service models.TranslationService
+	policy  broker.RetryPolicy
+	batch   BatchConfig
+	pool    PoolConfig
 }
 
-func NewConsumer(service models.TranslationService) *Consumer {
-	return &Consumer{service: service}
+func NewConsumer(service models.TranslationService, policy broker.RetryPolicy, batch BatchConfig, pool PoolConfig) *Consumer {
+	if batch.Size < 1 {
+		batch.Size = 1
+	}
+	if batch.Timeout <= 0 {
+		batch.Timeout = DefaultBatchConfig.Timeout
+	}
+	if pool.Workers < 1 {
+		pool.Workers = 1
+	}
+	if pool.Prefetch < 1 {
+		pool.Prefetch = pool.Workers * batch.Size
+	}
+	return &Consumer{service: service, policy: policy, batch: batch, pool: pool}
 }
 
-func (c *Consumer) Listen(ch *amqp.Channel) {
-	q, err := ch.QueueDeclare(broker.MessageSentEvent, true, false, false, false, nil)
-	if err != nil {
-		panic(err)
+// Listen hands deliveries to the workers until ctx is done or the channel
+// closes. On shutdown it stops the subscription, lets every worker finish
+// and publish what it holds, and only then returns.
+func (c *Consumer) Listen(ctx context.Context, ch *amqp.Channel) {
+	if err := broker.DeclareRetryTopology(ch, broker.MessageSentEvent, c.policy); err != nil {
+		log.Fatalf("Failed to declare %s topology: %v", broker.MessageSentEvent, err)
+	}
+
+	// Unacknowledged deliveries are what fills a batch, so the prefetch has
+	// to allow at least a full one.
+	if err := ch.Qos(max(c.pool.Prefetch, c.batch.Size), 0, false); err != nil {
+		log.Fatalf("Failed to set prefetch: %v", err)
 	}
 
-	msg, err := ch.Consume(q.Name, "", true, false, false, false, nil)
+	msgs, err := broker.Subscribe[broker.MessageSent](ch, broker.MessageSentEvent, consumerTag)
+	if err != nil {
+		log.Fatalf("Failed to consume %s: %v", broker.MessageSentEvent, err)
+	}
 
-	var forever chan struct{}
+	// Batches in flight outlive the shutdown by the drain grace at most.
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(drainGrace, cancel)
+	})
+	defer stop()
 
-	go func() {
-		for d := range msg {
+	// Inboxes hold up to the prefetch, so a busy worker never blocks the
+	// dispatch to the others.
+	var wg sync.WaitGroup
+	inboxes := make([]chan broker.Delivery[broker.MessageSent], c.pool.Workers)
+	for i := range inboxes {
+		inboxes[i] = make(chan broker.Delivery[broker.MessageSent], c.pool.Prefetch)
+		wg.Add(1)
+		go func(inbox <-chan broker.Delivery[broker.MessageSent]) {
+			defer wg.Done()
+			c.work(ctx, runCtx, ch, inbox)
+		}(inboxes[i])
+	}
+	defer func() {
+		for _, inbox := range inboxes {
+			close(inbox)
+		}
+		wg.Wait()
+	}()
 
-			msg := &models.ConsumerResponse{}
-			err = json.Unmarshal(d.Body, msg)
-			if err != nil {
-				panic(err)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping the consumer, draining %d workers", c.pool.Workers)
+			if err = ch.Cancel(consumerTag, false); err != nil {
+				log.Printf("Failed to cancel the subscription: %v", err)
 			}
+			// Whatever was delivered in the meantime goes back to the queue.
+			for d := range msgs {
+				_ = d.Nack(false, true)
+			}
+			return
+		case d, ok := <-msgs:
+			if !ok {
+				return
+			}
+
+			inboxes[partition(d.Envelope.Data.ChatID, len(inboxes))] <- d
+		}
+	}
+}
 
-			trnResponse, err := c.service.TranslateMessage(msg.Content, msg.SourceLang, msg.TargetLang)
-			if err != nil {
-				panic(err)
+func partition(chatID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(chatID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// work micro-batches the deliveries of its partition and translates them
+// under runCtx. It flushes what is pending once its inbox is closed and
+// requeues what it still holds back.
+func (c *Consumer) work(ctx, runCtx context.Context, ch *amqp.Channel, inbox <-chan broker.Delivery[broker.MessageSent]) {
+	var (
+		pending []broker.Delivery[broker.MessageSent]
+		flushC  <-chan time.Time
+		holds   = make(chatHolds)
+	)
+	expiry := time.NewTicker(time.Second)
+	defer expiry.Stop()
+
+	flush := func() {
+		pause := c.flush(runCtx, ch, pending, holds)
+		pending, flushC = nil, nil
+		if pause > 0 {
+			log.Printf("Translation provider unavailable, pausing a worker for %s", pause.Round(time.Second))
+			timer := time.NewTimer(pause)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
 			}
+		}
+	}
 
-			q, err := ch.QueueDeclare(broker.MessageTranslatedEvent, true, false, false, false, nil)
-			if err != nil {
-				panic(err)
+	// Released deliveries are admitted one at a time, because a flush in
+	// between can hold their chat back again.
+	receive := func(backlog ...broker.Delivery[broker.MessageSent]) {
+		for len(backlog) > 0 {
+			p := backlog[0]
+			backlog = backlog[1:]
+			released, ready := holds.admit(p)
+			if !ready {
+				continue
 			}
+			backlog = append(released, backlog...)
 
-			msgData := map[string]interface{}{
-				"messageId":         msg.MessageID,
-				"translatedContent": trnResponse.TranslatedContent,
-				"Success":           true,
+			pending = append(pending, p)
+			if len(pending) == 1 {
+				flushC = time.After(c.batch.Wait)
 			}
+			if len(pending) >= c.batch.Size {
+				flush()
+			}
+		}
+	}
 
-			body, err := json.Marshal(msgData)
-			if err != nil {
-				panic(err)
+	for {
+		select {
+		case p, ok := <-inbox:
+			if !ok {
+				if len(pending) > 0 {
+					flush()
+				}
+				for _, p := range holds.drain() {
+					if err := p.Nack(false, true); err != nil {
+						log.Printf("Failed to requeue message %s: %v", p.Envelope.Data.MessageID, err)
+					}
+				}
+				return
 			}
+			receive(p)
+		case <-flushC:
+			flush()
+		case now := <-expiry.C:
+			receive(holds.expire(now)...)
+		}
+	}
+}
+
+// holdGrace is how long past its expected return a chat waits for a message
+// that was sent away. It may never come back to this worker, for instance
+// when another replica picks it up.
+const holdGrace = 10 * time.Second
+
+// hold keeps back the deliveries of a chat while one of its messages is away
+// for a requeue or retry.
+type hold struct {
+	messageID string
+	until     time.Time
+	held      []broker.Delivery[broker.MessageSent]
+}
+
+// chatHolds are the holds of one worker by chat.
+type chatHolds map[string]*hold
 
-			err = ch.PublishWithContext(context.Background(), "", q.Name, false, false, amqp.Publishing{
-				ContentType:  "application/json",
-				Body:         body,
-				DeliveryMode: amqp.Persistent,
-			})
-			if err != nil {
-				panic(err)
+// block holds back the chat of p until p is delivered again or back and the
+// grace period have passed.
+func (h chatHolds) block(p broker.Delivery[broker.MessageSent], back time.Duration) {
+	h[p.Envelope.Data.ChatID] = &hold{messageID: p.Envelope.Data.MessageID, until: time.Now().Add(back + holdGrace)}
+}
+
+// admit reports whether p can be translated now. When p is the message its
+// chat was waiting for, the deliveries held back behind it are released.
+func (h chatHolds) admit(p broker.Delivery[broker.MessageSent]) ([]broker.Delivery[broker.MessageSent], bool) {
+	msg := p.Envelope.Data
+	hd := h[msg.ChatID]
+	if hd == nil {
+		return nil, true
+	}
+	if msg.MessageID != hd.messageID {
+		hd.held = append(hd.held, p)
+		return nil, false
+	}
+	delete(h, msg.ChatID)
+	return hd.held, true
+}
+
+// expire lifts the holds whose message did not come back in time and
+// releases what they held.
+func (h chatHolds) expire(now time.Time) []broker.Delivery[broker.MessageSent] {
+	var released []broker.Delivery[broker.MessageSent]
+	for chatID, hd := range h {
+		if now.Before(hd.until) {
+			continue
+		}
+		log.Printf("Message %s of chat %s did not come back, releasing %d held messages", hd.messageID, chatID, len(hd.held))
+		released = append(released, hd.held...)
+		delete(h, chatID)
+	}
+	return released
+}
+
+// drain lifts every hold and returns what they held.
+func (h chatHolds) drain() []broker.Delivery[broker.MessageSent] {
+	var held []broker.Delivery[broker.MessageSent]
+	for chatID, hd := range h {
+		held = append(held, hd.held...)
+		delete(h, chatID)
+	}
+	return held
+}
+
+// flush translates a batch in one service call and settles every delivery on
+// its own: translated messages are published and acked, failed ones retried.
+// Messages refused because a provider is unavailable go back to the queue
+// untouched, and flush returns how long the provider asked to be left alone.
+// Either way the chat of a message sent away is held back until it returns.
+func (c *Consumer) flush(ctx context.Context, ch *amqp.Channel, batch []broker.Delivery[broker.MessageSent], holds chatHolds) time.Duration {
+	items := make([]models.BatchItem, len(batch))
+	for i, p := range batch {
+		msg := p.Envelope.Data
+		items[i] = models.BatchItem{
+			MessageID: msg.MessageID,
+			TranslateRequest: models.TranslateRequest{
+				SourceLang:   msg.SourceLang,
+				TargetLang:   msg.TargetLang,
+				Content:      msg.Content,
+				Context:      contextOf(msg.Context),
+				ChatID:       msg.ChatID,
+				Participants: []string{msg.SenderUsername, msg.ReceiverUsername},
+				Username:     msg.SenderUsername,
+			},
+		}
+	}
+
+	// Each message is processed in the trace it was sent in. The provider
+	// call they share is linked to all of them, or part of the trace when
+	// the batch holds a single message.
+	ctxs := make([]context.Context, len(batch))
+	spans := make([]trace.Span, len(batch))
+	links := make([]trace.Link, len(batch))
+	for i, p := range batch {
+		ctxs[i], spans[i] = broker.StartProcess(ctx, broker.MessageSentEvent, p.Delivery)
+		links[i] = trace.Link{SpanContext: spans[i].SpanContext()}
+	}
+	batchCtx := ctx
+	if len(batch) == 1 {
+		batchCtx = ctxs[0]
+	}
+	batchCtx, cancel := context.WithTimeout(batchCtx, c.batch.Timeout)
+	batchCtx, batchSpan := otel.Tracer("translation-consumer").Start(batchCtx, "TranslateBatch",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("batch.size", len(batch))),
+	)
+	results := c.service.TranslateBatch(batchCtx, items)
+	batchSpan.End()
+	cancel()
+
+	// The later messages of a chat whose message was sent away are held
+	// back, whatever their outcome, so none of them overtakes it.
+	var pause time.Duration
+	for i, p := range batch {
+		if _, ready := holds.admit(p); !ready {
+			broker.EndSpan(spans[i], nil)
+			continue
+		}
+
+		settleCtx, cancel := context.WithTimeout(ctxs[i], settleTimeout)
+		wait, back := c.settle(settleCtx, ch, p, results[i])
+		cancel()
+		if back > 0 {
+			holds.block(p, back)
+		}
+		pause = max(pause, wait)
+		broker.EndSpan(spans[i], results[i].Err)
+	}
+
+	return pause
+}
+
+// settle publishes the outcome of one message and acknowledges, retries or
+// requeues its delivery. It returns how long to pause if the provider is
+// unavailable, and roughly when the delivery comes back if it was sent away.
+func (c *Consumer) settle(ctx context.Context, ch *amqp.Channel, p broker.Delivery[broker.MessageSent], result models.BatchResult) (pause, back time.Duration) {
+	msg := p.Envelope.Data
+	err := result.Err
+
+	var unavailable *models.UnavailableError
+	if errors.As(err, &unavailable) {
+		if err = p.Nack(false, true); err != nil {
+			log.Printf("Failed to requeue message %s: %v", msg.MessageID, err)
+		}
+		pause = max(unavailable.RetryAfter, time.Second)
+		return pause, pause
+	}
+	if err == nil {
+		err = events.PublishTranslated(ctx, ch, p.Envelope.Correlation(), broker.MessageTranslated{
+			MessageID:         msg.MessageID,
+			TargetLang:        msg.TargetLang,
+			TranslatedContent: result.TranslatedContent,
+			Provider:          result.Provider,
+			Model:             result.Model,
+			Success:           true,
+		})
+	}
+
+	if err != nil {
+		log.Printf("Failed to translate message %s (attempt %d): %v", msg.MessageID, broker.RetryCount(p.Delivery)+1, err)
+		// The last attempt goes to the dead-letter queue, so tell the chat
+		// service the message will stay untranslated. An exhausted quota
+		// does not recover within the retry delays and gives up at once.
+		attempt := broker.RetryCount(p.Delivery) + 1
+		quotaExceeded := errors.Is(err, models.ErrQuotaExceeded)
+		if quotaExceeded || attempt > c.policy.MaxRetries {
+			failed := broker.MessageTranslated{MessageID: msg.MessageID, TargetLang: msg.TargetLang, Error: err.Error()}
+			if pubErr := events.PublishTranslated(ctx, ch, p.Envelope.Correlation(), failed); pubErr != nil {
+				log.Printf("Failed to publish failed translation of message %s: %v", msg.MessageID, pubErr)
 			}
 		}
-	}()
+		if quotaExceeded {
+			err = broker.DeadLetterDelivery(ctx, ch, broker.MessageSentEvent, p.Delivery, err)
+		} else {
+			err = broker.Retry(ctx, ch, broker.MessageSentEvent, p.Delivery, c.policy, err)
+			if attempt <= c.policy.MaxRetries {
+				back = c.policy.Delay(attempt)
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to retry or dead-letter message %s: %v", msg.MessageID, err)
+		}
+		return 0, back
+	}
 
-	<-forever
+	if err = p.Ack(false); err != nil {
+		log.Printf("Failed to acknowledge message %s: %v", msg.MessageID, err)
+	}
+	return 0, 0
+}
+
+func contextOf(messages []broker.ContextMessage) []models.ContextMessage {
+	if len(messages) == 0 {
+		return nil
+	}
+	history := make([]models.ContextMessage, len(messages))
+	for i, msg := range messages {
+		history[i] = models.ContextMessage{Sender: msg.Sender, Content: msg.Content}
+	}
+	return history
 }
@@ -0,0 +1,121 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HJyup/translatify-common/broker"
+	"github.com/HJyup/translatify-translation/internal/models"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// batchService answers TranslateBatch with fixed results.
+type batchService struct {
+	models.TranslationService
+	results  []models.BatchResult
+	deadline time.Time
+}
+
+func (s *batchService) TranslateBatch(ctx context.Context, items []models.BatchItem) []models.BatchResult {
+	s.deadline, _ = ctx.Deadline()
+	return s.results
+}
+
+// acknowledger records how each delivery tag was settled.
+type acknowledger struct {
+	settled map[uint64]string
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.settled[tag] = "ack"
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	if requeue {
+		a.settled[tag] = "requeue"
+	} else {
+		a.settled[tag] = "nack"
+	}
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	a.settled[tag] = "reject"
+	return nil
+}
+
+func TestFlushHoldsBackTheRestOfAnUnavailableChat(t *testing.T) {
+	ack := &acknowledger{settled: make(map[uint64]string)}
+	delivery := func(tag uint64, messageID, chatID string) broker.Delivery[broker.MessageSent] {
+		return broker.Delivery[broker.MessageSent]{
+			Delivery: amqp.Delivery{Acknowledger: ack, DeliveryTag: tag},
+			Envelope: broker.Envelope[broker.MessageSent]{Data: broker.MessageSent{MessageID: messageID, ChatID: chatID, SourceLang: "en", TargetLang: "de", Content: "hi"}},
+		}
+	}
+	batch := []broker.Delivery[broker.MessageSent]{delivery(1, "a1", "a"), delivery(2, "a2", "a"), delivery(3, "a3", "a")}
+
+	service := &batchService{results: []models.BatchResult{
+		{Err: &models.UnavailableError{Provider: "openai", RetryAfter: 5 * time.Second, Err: errors.New("rate limited")}},
+		{TranslatedContent: "hallo"},
+		{Err: errors.New("boom")},
+	}}
+	c := NewConsumer(service, broker.DefaultRetryPolicy, DefaultBatchConfig, DefaultPoolConfig)
+	holds := make(chatHolds)
+
+	if pause := c.flush(context.Background(), nil, batch, holds); pause != 5*time.Second {
+		t.Errorf("pause = %s, want 5s", pause)
+	}
+	want := map[uint64]string{1: "requeue"}
+	if !reflect.DeepEqual(ack.settled, want) {
+		t.Errorf("settled = %v, want %v", ack.settled, want)
+	}
+	if left := time.Until(service.deadline); left <= 0 || left > DefaultBatchConfig.Timeout {
+		t.Errorf("batch deadline in %s, want within %s", left, DefaultBatchConfig.Timeout)
+	}
+
+	// The requeued message releases the rest of its chat, in order.
+	if _, ready := holds.admit(delivery(4, "a4", "a")); ready {
+		t.Error("a later message of the chat was admitted while a1 is away")
+	}
+	if _, ready := holds.admit(delivery(5, "b1", "b")); !ready {
+		t.Error("another chat was held back")
+	}
+	released, ready := holds.admit(delivery(6, "a1", "a"))
+	if !ready {
+		t.Fatal("a1 was held back behind itself")
+	}
+	var ids []string
+	for _, p := range released {
+		ids = append(ids, p.Envelope.Data.MessageID)
+	}
+	if want := []string{"a2", "a3", "a4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("released %v, want %v", ids, want)
+	}
+	if len(holds) != 0 {
+		t.Errorf("%d holds left", len(holds))
+	}
+}
+
+func TestChatHoldsExpire(t *testing.T) {
+	message := func(messageID string) broker.Delivery[broker.MessageSent] {
+		return broker.Delivery[broker.MessageSent]{Envelope: broker.Envelope[broker.MessageSent]{Data: broker.MessageSent{MessageID: messageID, ChatID: "a"}}}
+	}
+	holds := make(chatHolds)
+	holds.block(message("a1"), time.Second)
+	holds.admit(message("a2"))
+
+	if released := holds.expire(time.Now()); len(released) != 0 {
+		t.Errorf("released %d messages before the hold expired", len(released))
+	}
+	released := holds.expire(time.Now().Add(time.Second + holdGrace))
+	if len(released) != 1 || released[0].Envelope.Data.MessageID != "a2" {
+		t.Errorf("released %v, want a2", released)
+	}
+	if _, ready := holds.admit(message("a3")); !ready {
+		t.Error("the chat is still held back after its hold expired")
+	}
+}
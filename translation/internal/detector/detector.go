@@ -0,0 +1,279 @@
+package detector
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// mixedShare is the share of letters a second language needs before a text
+// counts as mixed.
+const mixedShare = 0.2
+
+// identifiedConfidence is reported for languages named by the fallback. It
+// does not give a score, and asking it means the local guess was too weak.
+const identifiedConfidence = 0.9
+
+// LanguageIdentifier is an external, usually LLM-backed, detector asked when
+// the local one is unsure.
+type LanguageIdentifier interface {
+	IdentifyLanguage(ctx context.Context, text string) (string, error)
+}
+
+// usageMetered is implemented by identifiers that bill per token.
+type usageMetered interface {
+	WithUsage(meter *models.UsageMeter) models.TranslatorModel
+}
+
+// Detector recognises languages locally: scripts with a single language are
+// mapped directly, Latin text is scored against common words and diacritics
+// per sentence. Texts whose sentences or scripts disagree are flagged mixed.
+type Detector struct {
+	fallback  LanguageIdentifier
+	provider  string
+	threshold float64
+}
+
+// New returns a local detector. If fallback is set, it is asked whenever the
+// local confidence stays below threshold; provider names it in the usage.
+func New(fallback LanguageIdentifier, provider string, threshold float64) *Detector {
+	return &Detector{fallback: fallback, provider: provider, threshold: threshold}
+}
+
+func (d *Detector) Detect(ctx context.Context, text string) (*models.Detection, error) {
+	detection := detectLocal(text)
+
+	if d.fallback != nil && detection.Confidence < d.threshold && strings.TrimSpace(text) != "" {
+		language, err := d.identify(ctx, detection, text)
+		if err != nil {
+			log.Printf("Language identification fallback failed, keeping local result: %v", err)
+			return detection, nil
+		}
+		if language = normalise(language); language != "" {
+			detection.Language = language
+			detection.Confidence = identifiedConfidence
+			if len(detection.Languages) == 0 || detection.Languages[0] != language {
+				detection.Languages = append([]string{language}, without(detection.Languages, language)...)
+			}
+		}
+	}
+
+	return detection, nil
+}
+
+// identify asks the fallback and notes what that cost on detection.
+func (d *Detector) identify(ctx context.Context, detection *models.Detection, text string) (string, error) {
+	identifier := d.fallback
+	meter := &models.UsageMeter{}
+	if m, ok := identifier.(usageMetered); ok {
+		if metered, ok := m.WithUsage(meter).(LanguageIdentifier); ok {
+			identifier = metered
+		}
+	}
+
+	language, err := identifier.IdentifyLanguage(ctx, text)
+	if usage := meter.Usage(); !usage.IsZero() {
+		detection.Provider, detection.Usage = d.provider, usage
+		if namer, ok := d.fallback.(interface{ ModelName() string }); ok {
+			detection.Model = namer.ModelName()
+		}
+	}
+	return language, err
+}
+
+type segmentScore struct {
+	letters    int
+	confidence float64
+}
+
+func detectLocal(text string) *models.Detection {
+	hasKana := strings.IndexFunc(text, func(r rune) bool {
+		return unicode.In(r, unicode.Hiragana, unicode.Katakana)
+	}) >= 0
+
+	shares := make(map[string]*segmentScore)
+	add := func(language string, letters int, confidence float64) {
+		if language == "" || letters == 0 {
+			return
+		}
+		s, ok := shares[language]
+		if !ok {
+			s = &segmentScore{}
+			shares[language] = s
+		}
+		// Keep a letter-weighted average of the segment confidences.
+		s.confidence = (s.confidence*float64(s.letters) + confidence*float64(letters)) / float64(s.letters+letters)
+		s.letters += letters
+	}
+
+	for _, sentence := range splitSentences(text) {
+		var latin []string
+		for _, word := range strings.FieldsFunc(sentence, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' }) {
+			if language, ok := scriptLanguage(word, hasKana); ok {
+				add(language, letterCount(word), 1)
+				continue
+			}
+			latin = append(latin, strings.ToLower(word))
+		}
+
+		if len(latin) > 0 {
+			language, confidence := scoreLatin(latin)
+			add(language, letterCount(strings.Join(latin, "")), confidence)
+		}
+	}
+
+	detection := &models.Detection{}
+	if len(shares) == 0 {
+		return detection
+	}
+
+	total := 0
+	for language, s := range shares {
+		total += s.letters
+		detection.Languages = append(detection.Languages, language)
+	}
+	sort.Slice(detection.Languages, func(i, j int) bool {
+		a, b := shares[detection.Languages[i]], shares[detection.Languages[j]]
+		if a.letters != b.letters {
+			return a.letters > b.letters
+		}
+		return detection.Languages[i] < detection.Languages[j]
+	})
+
+	primary := shares[detection.Languages[0]]
+	detection.Language = detection.Languages[0]
+	detection.Confidence = float64(primary.letters) / float64(total) * primary.confidence
+
+	significant := 0
+	for _, language := range detection.Languages {
+		if float64(shares[language].letters)/float64(total) >= mixedShare {
+			significant++
+		}
+	}
+	detection.Mixed = significant > 1
+
+	return detection
+}
+
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		switch r {
+		case '.', '!', '?', '\n', '。', '！', '？', ';':
+			return true
+		}
+		return false
+	})
+}
+
+func letterCount(word string) int {
+	n := 0
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// scriptLanguage maps words written in a script used by essentially one
+// language. Latin words report false.
+func scriptLanguage(word string, hasKana bool) (string, bool) {
+	for _, r := range word {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			return "ja", true
+		case unicode.Is(unicode.Han, r):
+			if hasKana {
+				return "ja", true
+			}
+			return "zh", true
+		case unicode.Is(unicode.Hangul, r):
+			return "ko", true
+		case unicode.Is(unicode.Cyrillic, r):
+			if strings.ContainsAny(strings.ToLower(word), "іїєґ") {
+				return "uk", true
+			}
+			return "ru", true
+		case unicode.Is(unicode.Greek, r):
+			return "el", true
+		case unicode.Is(unicode.Arabic, r):
+			return "ar", true
+		case unicode.Is(unicode.Hebrew, r):
+			return "he", true
+		case unicode.Is(unicode.Thai, r):
+			return "th", true
+		case unicode.Is(unicode.Devanagari, r):
+			return "hi", true
+		case unicode.Is(unicode.Latin, r):
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// scoreLatin picks the Latin-script language whose common words and
+// diacritics match best. The confidence grows with the margin over the
+// runner-up and with the number of matches.
+func scoreLatin(words []string) (string, float64) {
+	scores := make(map[string]float64)
+	for _, word := range words {
+		for language, common := range commonWords {
+			if _, ok := common[word]; ok {
+				scores[language]++
+			}
+		}
+		for _, r := range word {
+			for language, weight := range diacritics[r] {
+				scores[language] += weight
+			}
+		}
+	}
+
+	var best, runnerUp float64
+	language := ""
+	for _, candidate := range latinLanguages {
+		score := scores[candidate]
+		switch {
+		case score > best:
+			runnerUp, best, language = best, score, candidate
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if best == 0 {
+		return "", 0
+	}
+
+	margin := (best - runnerUp) / best
+	coverage := best / 3
+	if coverage > 1 {
+		coverage = 1
+	}
+	return language, margin*0.5 + coverage*0.5
+}
+
+func normalise(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	language = strings.Trim(language, ".\"'`")
+	if i := strings.IndexAny(language, "-_ "); i > 0 {
+		language = language[:i]
+	}
+	if len(language) < 2 || len(language) > 3 {
+		return ""
+	}
+	return language
+}
+
+func without(languages []string, language string) []string {
+	out := make([]string, 0, len(languages))
+	for _, l := range languages {
+		if l != language {
+			out = append(out, l)
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+func TestDetectLocal(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		wantLanguage  string
+		wantMixed     bool
+		wantConfident bool
+	}{
+		{name: "empty", text: ""},
+		{name: "japanese", text: "今日は天気がいいですね", wantLanguage: "ja", wantConfident: true},
+		{name: "chinese", text: "你好世界", wantLanguage: "zh", wantConfident: true},
+		{name: "ukrainian", text: "Привіт", wantLanguage: "uk", wantConfident: true},
+		{name: "english", text: "How are you doing? Thanks for the help with this.", wantLanguage: "en", wantConfident: true},
+		{name: "german", text: "Ich bin nicht sicher, aber das ist gut.", wantLanguage: "de", wantConfident: true},
+		{name: "mixed sentences", text: "Thanks for the help with this. Ich bin nicht sicher, aber das ist gut.", wantLanguage: "de", wantMixed: true},
+		{name: "unknown words", text: "Xyzzy plugh", wantLanguage: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectLocal(tt.text)
+			if got.Language != tt.wantLanguage || got.Mixed != tt.wantMixed {
+				t.Errorf("detectLocal = %s (mixed %v), want %s (mixed %v)", got.Language, got.Mixed, tt.wantLanguage, tt.wantMixed)
+			}
+			if confident := got.Confidence >= 0.6; confident != tt.wantConfident {
+				t.Errorf("Confidence = %.2f, want confident %v", got.Confidence, tt.wantConfident)
+			}
+		})
+	}
+}
+
+// identifier answers with language, or fails with err, and bills usage when
+// metered.
+type identifier struct {
+	models.TranslatorModel
+	language string
+	err      error
+	usage    models.Usage
+	meter    *models.UsageMeter
+	calls    int
+}
+
+func (i *identifier) IdentifyLanguage(ctx context.Context, text string) (string, error) {
+	i.calls++
+	if i.meter != nil {
+		i.meter.Add(i.usage)
+	}
+	return i.language, i.err
+}
+
+func (i *identifier) WithUsage(meter *models.UsageMeter) models.TranslatorModel {
+	i.meter = meter
+	return i
+}
+
+func (i *identifier) ModelName() string {
+	return "gpt-test"
+}
+
+func TestDetectFallback(t *testing.T) {
+	usage := models.Usage{PromptTokens: 12, CompletionTokens: 1}
+
+	tests := []struct {
+		name           string
+		text           string
+		identifier     *identifier
+		wantLanguage   string
+		wantConfidence float64
+		wantCalls      int
+		wantUsage      models.Usage
+	}{
+		{
+			name:           "weak local guess",
+			text:           "Xyzzy plugh",
+			identifier:     &identifier{language: " PT-br.", usage: usage},
+			wantLanguage:   "pt",
+			wantConfidence: identifiedConfidence,
+			wantCalls:      1,
+			wantUsage:      usage,
+		},
+		{
+			name:           "confident local guess",
+			text:           "今日は天気がいいですね",
+			identifier:     &identifier{language: "zh", usage: usage},
+			wantLanguage:   "ja",
+			wantConfidence: 1,
+		},
+		{
+			name:       "fallback fails",
+			text:       "Xyzzy plugh",
+			identifier: &identifier{err: errors.New("down"), usage: usage},
+			wantCalls:  1,
+			wantUsage:  usage,
+		},
+		{
+			name:       "unusable answer",
+			text:       "Xyzzy plugh",
+			identifier: &identifier{language: "I am not sure"},
+			wantCalls:  1,
+		},
+		{
+			name:       "blank text",
+			text:       "  ",
+			identifier: &identifier{language: "en"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.identifier, "openai", 0.5).Detect(context.Background(), tt.text)
+			if err != nil {
+				t.Fatalf("Detect: %v", err)
+			}
+			if got.Language != tt.wantLanguage || got.Confidence != tt.wantConfidence {
+				t.Errorf("Detect = %s (%.2f), want %s (%.2f)", got.Language, got.Confidence, tt.wantLanguage, tt.wantConfidence)
+			}
+			if tt.identifier.calls != tt.wantCalls {
+				t.Errorf("identifier called %d times, want %d", tt.identifier.calls, tt.wantCalls)
+			}
+			if got.Usage != tt.wantUsage {
+				t.Errorf("Usage = %+v, want %+v", got.Usage, tt.wantUsage)
+			}
+			if !tt.wantUsage.IsZero() && (got.Provider != "openai" || got.Model != "gpt-test") {
+				t.Errorf("billed to %s/%s, want openai/gpt-test", got.Provider, got.Model)
+			}
+		})
+	}
+}
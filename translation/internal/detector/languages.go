@@ -0,0 +1,66 @@
+package detector
+
+// latinLanguages fixes the order in which ties are broken.
+var latinLanguages = []string{"en", "es", "fr", "de", "it", "pt", "nl", "pl", "tr", "sv"}
+
+func wordSet(words ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		set[w] = struct{}{}
+	}
+	return set
+}
+
+// commonWords holds the most frequent function words and chat phrases per
+// language. Words shared by several languages simply score for all of them.
+var commonWords = map[string]map[string]struct{}{
+	"en": wordSet("the", "and", "is", "are", "you", "to", "of", "it", "that", "this", "what", "with", "have", "for",
+		"not", "was", "my", "me", "how", "be", "do", "we", "they", "your", "can", "will", "just", "hello", "thanks", "yes", "i'm"),
+	"es": wordSet("el", "la", "los", "las", "que", "de", "y", "es", "en", "un", "una", "por", "para", "con", "no", "lo",
+		"se", "está", "estás", "como", "pero", "muy", "yo", "tu", "hola", "gracias", "qué", "sí", "bien"),
+	"fr": wordSet("le", "la", "les", "des", "et", "est", "je", "tu", "vous", "nous", "pas", "que", "qui", "une", "un",
+		"du", "dans", "pour", "avec", "ce", "mais", "suis", "bonjour", "merci", "oui", "très", "c'est", "ça"),
+	"de": wordSet("der", "die", "das", "und", "ist", "ich", "du", "nicht", "ein", "eine", "zu", "mit", "sie", "wir",
+		"es", "auf", "für", "auch", "aber", "wie", "was", "danke", "hallo", "bin", "sind", "ja", "gut"),
+	"it": wordSet("il", "lo", "la", "gli", "le", "che", "di", "e", "è", "un", "una", "per", "con", "non", "sono",
+		"sei", "ciao", "grazie", "come", "ma", "io", "tu", "molto", "anche", "bene"),
+	"pt": wordSet("o", "a", "os", "as", "que", "de", "e", "é", "um", "uma", "não", "com", "para", "por", "eu",
+		"você", "obrigado", "obrigada", "olá", "está", "muito", "mas", "como", "sim", "tudo"),
+	"nl": wordSet("de", "het", "een", "en", "is", "ik", "je", "niet", "van", "dat", "met", "op", "zijn", "wat",
+		"hoe", "maar", "ook", "dank", "hallo", "jij", "wij", "goed"),
+	"pl": wordSet("i", "w", "nie", "jest", "to", "się", "na", "że", "z", "co", "jak", "ale", "tak", "ja", "ty",
+		"dziękuję", "cześć", "dobrze"),
+	"tr": wordSet("ve", "bir", "bu", "ne", "ben", "sen", "çok", "için", "ile", "değil", "merhaba", "teşekkürler",
+		"nasıl", "var", "yok", "evet"),
+	"sv": wordSet("och", "att", "det", "är", "jag", "du", "inte", "en", "ett", "som", "på", "med", "för", "hej",
+		"tack", "vad", "bra"),
+}
+
+// diacritics are letters that point to a few languages only.
+var diacritics = map[rune]map[string]float64{
+	'ñ': {"es": 2},
+	'ß': {"de": 2},
+	'ä': {"de": 1, "sv": 1},
+	'ö': {"de": 1, "sv": 1, "tr": 1},
+	'ü': {"de": 1, "tr": 1},
+	'ç': {"fr": 1, "pt": 1, "tr": 1},
+	'ã': {"pt": 2},
+	'õ': {"pt": 2},
+	'ł': {"pl": 2},
+	'ą': {"pl": 2},
+	'ę': {"pl": 2},
+	'ś': {"pl": 2},
+	'ź': {"pl": 2},
+	'ż': {"pl": 2},
+	'ğ': {"tr": 2},
+	'ş': {"tr": 2},
+	'ı': {"tr": 2},
+	'å': {"sv": 2},
+	'œ': {"fr": 2},
+	'è': {"fr": 1, "it": 1},
+	'ê': {"fr": 1, "pt": 1},
+	'à': {"fr": 1, "it": 1, "pt": 1},
+	'ù': {"fr": 1},
+	'ì': {"it": 1},
+	'ò': {"it": 1},
+}
@@ -0,0 +1,19 @@
+package events
+
+import (
+	"context"
+
+	"github.com/HJyup/translatify-common/broker"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Producer names the translation service in the envelopes it publishes.
+const Producer = "translation"
+
+// PublishTranslated hands a finished or given-up translation to the chat
+// service, which stores it on the message and pushes it to subscribers.
+// correlationID is the one of the request being answered.
+func PublishTranslated(ctx context.Context, ch *amqp.Channel, correlationID string, event broker.MessageTranslated) error {
+	meta := broker.Meta{Producer: Producer, CorrelationID: correlationID}
+	return broker.Publish(ctx, ch, broker.TranslatedKey(event.Success, event.TargetLang), meta, event)
+}
@@ -2,7 +2,13 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"log"
+	"time"
+
 	pb "github.com/HJyup/translatify-common/api"
+	"github.com/HJyup/translatify-common/broker"
+	"github.com/HJyup/translatify-translation/internal/events"
 	"github.com/HJyup/translatify-translation/internal/models"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"google.golang.org/grpc"
@@ -10,6 +16,8 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+const maxBatchItems = 100
+
 type GrpcHandler struct {
 	pb.UnimplementedTranslationServiceServer
 
@@ -26,15 +34,311 @@ func NewGrpcHandler(grpcServer *grpc.Server, service models.TranslationService,
 	pb.RegisterTranslationServiceServer(grpcServer, handler)
 }
 
+func contextFromProto(messages []*pb.ContextMessage) []models.ContextMessage {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	history := make([]models.ContextMessage, len(messages))
+	for i, msg := range messages {
+		history[i] = models.ContextMessage{Sender: msg.GetSender(), Content: msg.GetContent()}
+	}
+	return history
+}
+
+func requestFromProto(req *pb.TranslationRequest) models.TranslateRequest {
+	return models.TranslateRequest{
+		SourceLang:   req.GetSourceLanguage(),
+		TargetLang:   req.GetTargetLanguage(),
+		Content:      req.GetContent(),
+		Context:      contextFromProto(req.GetContext()),
+		ChatID:       req.GetChatId(),
+		Participants: req.GetParticipants(),
+		Style:        models.TranslationStyle(req.GetStyle()),
+		Provider:     req.GetProvider(),
+		SkipCache:    req.GetSkipCache(),
+		Username:     req.GetUsername(),
+	}
+}
+
+// translateError keeps bad input, exhausted quotas and providers that are
+// known to be down apart from other failures.
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrInvalidStyle), errors.Is(err, models.ErrUnknownProvider):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, models.ErrProviderUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "failed to translate the message: %v", err)
+	}
+}
+
 func (h *GrpcHandler) TranslateMessage(ctx context.Context, req *pb.TranslationRequest) (*pb.TranslationResponse, error) {
-	msg, err := h.service.TranslateMessage(req.GetSourceLanguage(), req.GetTargetLanguage(), req.GetContent())
+	msg, err := h.service.TranslateMessage(ctx, requestFromProto(req))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to translate the message: %v", err)
+		return nil, translateError(err)
 	}
 
 	return &pb.TranslationResponse{
-		MessageId:         req.GetMessageId(),
-		TranslatedContent: msg.TranslatedContent,
-		Success:           true,
+		MessageId:          req.GetMessageId(),
+		TranslatedContent:  msg.TranslatedContent,
+		Success:            true,
+		GlossaryApplied:    msg.Glossary.Applied,
+		GlossaryViolations: msg.Glossary.Violations,
+		Provider:           msg.Provider,
+		Model:              msg.Model,
+	}, nil
+}
+
+// StreamTranslate forwards deltas as they arrive. The final text goes to the
+// cache on the way out of the translator and, for a message, to the chat
+// service like any queued translation.
+func (h *GrpcHandler) StreamTranslate(req *pb.TranslationRequest, stream pb.TranslationService_StreamTranslateServer) error {
+	if req.GetSourceLanguage() == "" || req.GetTargetLanguage() == "" || req.GetContent() == "" {
+		return status.Error(codes.InvalidArgument, "source_language, target_language and content must be provided")
+	}
+
+	ctx := stream.Context()
+	msg, err := h.service.StreamTranslate(ctx, requestFromProto(req), func(delta string) error {
+		return stream.Send(&pb.TranslationChunk{MessageId: req.GetMessageId(), Delta: delta})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return translateError(err)
+	}
+
+	if req.GetMessageId() != "" {
+		err = events.PublishTranslated(ctx, h.channel, req.GetMessageId(), broker.MessageTranslated{
+			MessageID:         req.GetMessageId(),
+			TargetLang:        req.GetTargetLanguage(),
+			TranslatedContent: msg.TranslatedContent,
+			Provider:          msg.Provider,
+			Model:             msg.Model,
+			Success:           true,
+		})
+		if err != nil {
+			log.Printf("Failed to publish streamed translation of message %s: %v", req.GetMessageId(), err)
+		}
+	}
+
+	return stream.Send(&pb.TranslationChunk{
+		MessageId:          req.GetMessageId(),
+		Done:               true,
+		TranslatedContent:  msg.TranslatedContent,
+		GlossaryApplied:    msg.Glossary.Applied,
+		GlossaryViolations: msg.Glossary.Violations,
+		Provider:           msg.Provider,
+		Model:              msg.Model,
+	})
+}
+
+func (h *GrpcHandler) TranslateBatch(ctx context.Context, req *pb.TranslateBatchRequest) (*pb.TranslateBatchResponse, error) {
+	if len(req.GetItems()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "items must be provided")
+	}
+	if len(req.GetItems()) > maxBatchItems {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d items can be translated per call", maxBatchItems)
+	}
+
+	items := make([]models.BatchItem, len(req.GetItems()))
+	for i, item := range req.GetItems() {
+		items[i] = models.BatchItem{
+			MessageID:        item.GetMessageId(),
+			TranslateRequest: requestFromProto(item),
+		}
+	}
+
+	results := h.service.TranslateBatch(ctx, items)
+
+	resp := &pb.TranslateBatchResponse{Results: make([]*pb.TranslationResponse, len(results))}
+	for i, result := range results {
+		resp.Results[i] = &pb.TranslationResponse{
+			MessageId:          result.MessageID,
+			TranslatedContent:  result.TranslatedContent,
+			Success:            result.Err == nil,
+			GlossaryApplied:    result.Glossary.Applied,
+			GlossaryViolations: result.Glossary.Violations,
+			Provider:           result.Provider,
+			Model:              result.Model,
+		}
+		if result.Err != nil {
+			resp.Results[i].Error = result.Err.Error()
+		}
+	}
+	return resp, nil
+}
+
+func (h *GrpcHandler) DetectLanguage(ctx context.Context, req *pb.DetectLanguageRequest) (*pb.DetectLanguageResponse, error) {
+	if req.GetContent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "content must be provided")
+	}
+
+	detection, err := h.service.DetectLanguage(ctx, req.GetContent(), req.GetChatId(), req.GetUsername())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to detect the language: %v", err)
+	}
+
+	return &pb.DetectLanguageResponse{
+		Language:   detection.Language,
+		Confidence: detection.Confidence,
+		Mixed:      detection.Mixed,
+		Languages:  detection.Languages,
+	}, nil
+}
+
+func (h *GrpcHandler) InvalidateCache(ctx context.Context, req *pb.InvalidateCacheRequest) (*pb.InvalidateCacheResponse, error) {
+	if req.GetSourceLanguage() == "" || req.GetTargetLanguage() == "" {
+		return nil, status.Error(codes.InvalidArgument, "source_language and target_language must be provided")
+	}
+
+	removed, err := h.service.InvalidateCache(ctx, req.GetSourceLanguage(), req.GetTargetLanguage())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to invalidate the cache: %v", err)
+	}
+
+	return &pb.InvalidateCacheResponse{Removed: removed}, nil
+}
+
+func (h *GrpcHandler) GetCacheStats(_ context.Context, _ *pb.GetCacheStatsRequest) (*pb.GetCacheStatsResponse, error) {
+	stats := h.service.CacheStats()
+	return &pb.GetCacheStatsResponse{
+		Backend: stats.Backend,
+		Hits:    stats.Hits,
+		Misses:  stats.Misses,
+		Errors:  stats.Errors,
 	}, nil
 }
+
+var scopesFromProto = map[pb.GlossaryScope]models.GlossaryScope{
+	pb.GlossaryScope_GLOSSARY_SCOPE_CHAT: models.GlossaryScopeChat,
+	pb.GlossaryScope_GLOSSARY_SCOPE_USER: models.GlossaryScopeUser,
+}
+
+func scopeToProto(scope models.GlossaryScope) pb.GlossaryScope {
+	for protoScope, s := range scopesFromProto {
+		if s == scope {
+			return protoScope
+		}
+	}
+	return pb.GlossaryScope_GLOSSARY_SCOPE_UNSPECIFIED
+}
+
+func entryToProto(entry *models.GlossaryEntry) *pb.GlossaryEntry {
+	return &pb.GlossaryEntry{
+		EntryId:        entry.EntryID,
+		Scope:          scopeToProto(entry.Scope),
+		OwnerId:        entry.OwnerID,
+		Term:           entry.Term,
+		SourceLanguage: entry.SourceLang,
+		TargetLanguage: entry.TargetLang,
+		Rendering:      entry.Rendering,
+		DoNotTranslate: entry.DoNotTranslate,
+		CreatedAt:      entry.CreatedAt.Unix(),
+	}
+}
+
+func glossaryError(err error, action string) error {
+	switch {
+	case errors.Is(err, models.ErrInvalidGlossaryEntry):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrGlossaryEntryNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ErrGlossaryEntryExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "failed to %s: %v", action, err)
+	}
+}
+
+func (h *GrpcHandler) CreateGlossaryEntry(ctx context.Context, req *pb.CreateGlossaryEntryRequest) (*pb.CreateGlossaryEntryResponse, error) {
+	if req.GetEntry() == nil {
+		return nil, status.Error(codes.InvalidArgument, "entry must be provided")
+	}
+
+	in := req.GetEntry()
+	entry, err := h.service.CreateGlossaryEntry(ctx, &models.GlossaryEntry{
+		Scope:          scopesFromProto[in.GetScope()],
+		OwnerID:        in.GetOwnerId(),
+		Term:           in.GetTerm(),
+		SourceLang:     in.GetSourceLanguage(),
+		TargetLang:     in.GetTargetLanguage(),
+		Rendering:      in.GetRendering(),
+		DoNotTranslate: in.GetDoNotTranslate(),
+	})
+	if err != nil {
+		return nil, glossaryError(err, "create the glossary entry")
+	}
+
+	return &pb.CreateGlossaryEntryResponse{Entry: entryToProto(entry)}, nil
+}
+
+func (h *GrpcHandler) ListGlossaryEntries(ctx context.Context, req *pb.ListGlossaryEntriesRequest) (*pb.ListGlossaryEntriesResponse, error) {
+	entries, err := h.service.ListGlossaryEntries(ctx, scopesFromProto[req.GetScope()], req.GetOwnerId())
+	if err != nil {
+		return nil, glossaryError(err, "list the glossary")
+	}
+
+	resp := &pb.ListGlossaryEntriesResponse{Entries: make([]*pb.GlossaryEntry, len(entries))}
+	for i, entry := range entries {
+		resp.Entries[i] = entryToProto(entry)
+	}
+	return resp, nil
+}
+
+func (h *GrpcHandler) DeleteGlossaryEntry(ctx context.Context, req *pb.DeleteGlossaryEntryRequest) (*pb.DeleteGlossaryEntryResponse, error) {
+	err := h.service.DeleteGlossaryEntry(ctx, scopesFromProto[req.GetScope()], req.GetOwnerId(), req.GetEntryId())
+	if err != nil {
+		return nil, glossaryError(err, "delete the glossary entry")
+	}
+
+	return &pb.DeleteGlossaryEntryResponse{Success: true}, nil
+}
+
+func (h *GrpcHandler) GetUsageReport(ctx context.Context, req *pb.GetUsageReportRequest) (*pb.GetUsageReportResponse, error) {
+	filter := models.UsageFilter{
+		Username: req.GetUsername(),
+		ChatID:   req.GetChatId(),
+		Since:    time.Unix(req.GetSince(), 0),
+	}
+	if req.GetUntil() > 0 {
+		filter.Until = time.Unix(req.GetUntil(), 0)
+	}
+
+	report, err := h.service.UsageReport(ctx, filter)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidUsageFilter) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "failed to report the usage: %v", err)
+	}
+
+	resp := &pb.GetUsageReportResponse{Summaries: make([]*pb.UsageSummary, len(report.Summaries))}
+	for i, summary := range report.Summaries {
+		resp.Summaries[i] = &pb.UsageSummary{
+			Username:         summary.Username,
+			ChatId:           summary.ChatID,
+			SourceLanguage:   summary.SourceLang,
+			TargetLanguage:   summary.TargetLang,
+			Provider:         summary.Provider,
+			Model:            summary.Model,
+			PromptTokens:     summary.PromptTokens,
+			CompletionTokens: summary.CompletionTokens,
+			Requests:         summary.Requests,
+		}
+	}
+	if quota := report.Quota; quota != nil {
+		resp.Quota = &pb.QuotaStatus{
+			DailyUsed:    quota.DailyUsed,
+			DailyLimit:   quota.DailyLimit,
+			MonthlyUsed:  quota.MonthlyUsed,
+			MonthlyLimit: quota.MonthlyLimit,
+			Exceeded:     quota.Exceeded,
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"unicode"
+)
+
+// GlossaryTerm is a glossary entry resolved for one language pair.
+type GlossaryTerm struct {
+	Term           string
+	Rendering      string
+	DoNotTranslate bool
+}
+
+// Expected is what the term has to become in the translation.
+func (t GlossaryTerm) Expected() string {
+	if t.DoNotTranslate || t.Rendering == "" {
+		return t.Term
+	}
+	return t.Rendering
+}
+
+type Glossary []GlossaryTerm
+
+// GlossaryReport lists the terms of the source text that were rendered as the
+// glossary demands and those that were not.
+type GlossaryReport struct {
+	Applied    []string `json:"applied,omitempty"`
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Relevant keeps the terms that occur in text.
+func (g Glossary) Relevant(text string) Glossary {
+	var relevant Glossary
+	for _, term := range g {
+		if ContainsTerm(text, term.Term) {
+			relevant = append(relevant, term)
+		}
+	}
+	return relevant
+}
+
+// Check verifies that every term found in source survived into translated.
+func (g Glossary) Check(source, translated string) GlossaryReport {
+	var report GlossaryReport
+	for _, term := range g {
+		if !ContainsTerm(source, term.Term) {
+			continue
+		}
+		if ContainsTerm(translated, term.Expected()) {
+			report.Applied = append(report.Applied, term.Term)
+		} else {
+			report.Violations = append(report.Violations, term.Term)
+		}
+	}
+	return report
+}
+
+// ContainsTerm reports whether term occurs in text as a whole word, ignoring
+// case. Scripts written without spaces only need the characters to match.
+func ContainsTerm(text, term string) bool {
+	start, _ := TermIndex(text, term)
+	return start >= 0
+}
+
+// TermIndex returns the byte offsets of the first whole-word occurrence of
+// term in text, or -1, -1.
+func TermIndex(text, term string) (int, int) {
+	needle := lowerRunes(term)
+	if len(needle) == 0 {
+		return -1, -1
+	}
+	haystack := lowerRunes(text)
+
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if !equalRunes(haystack[i:i+len(needle)], needle) {
+			continue
+		}
+		if i > 0 && joins(haystack[i-1], needle[0]) {
+			continue
+		}
+		end := i + len(needle)
+		if end < len(haystack) && joins(haystack[end], needle[len(needle)-1]) {
+			continue
+		}
+		return byteOffset(text, i), byteOffset(text, end)
+	}
+	return -1, -1
+}
+
+func byteOffset(text string, runeIndex int) int {
+	n := 0
+	for offset := range text {
+		if n == runeIndex {
+			return offset
+		}
+		n++
+	}
+	return len(text)
+}
+
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func equalRunes(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// joins reports whether two adjacent runes belong to the same word, in which
+// case a match ending between them is only part of a longer word.
+func joins(a, b rune) bool {
+	return wordRune(a) && wordRune(b)
+}
+
+func wordRune(r rune) bool {
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return false
+	}
+	return !unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTermIndex(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		term      string
+		wantStart int
+		wantEnd   int
+	}{
+		{name: "whole word", text: "Use Go here", term: "go", wantStart: 4, wantEnd: 6},
+		{name: "prefix of a word", text: "Gopher", term: "go", wantStart: -1, wantEnd: -1},
+		{name: "suffix of a word first", text: "ago go", term: "go", wantStart: 4, wantEnd: 6},
+		{name: "punctuation boundary", text: "go-to", term: "go", wantStart: 0, wantEnd: 2},
+		{name: "symbol at the end", text: "C++ rocks", term: "c++", wantStart: 0, wantEnd: 3},
+		{name: "multibyte before", text: "Straße Berlin", term: "berlin", wantStart: 8, wantEnd: 14},
+		{name: "multibyte case", text: "ÉCOLE", term: "école", wantStart: 0, wantEnd: 6},
+		{name: "no spaces", text: "東京タワーに行く", term: "タワー", wantStart: 6, wantEnd: 15},
+		{name: "empty term", text: "hello", term: "", wantStart: -1, wantEnd: -1},
+		{name: "term longer than text", text: "go", term: "gopher", wantStart: -1, wantEnd: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := TermIndex(tt.text, tt.term)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("TermIndex(%q, %q) = %d, %d, want %d, %d", tt.text, tt.term, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestGlossaryCheck(t *testing.T) {
+	glossary := Glossary{
+		{Term: "Translatify", DoNotTranslate: true},
+		{Term: "chat room", Rendering: "Chatraum"},
+		{Term: "invoice", Rendering: "Rechnung"},
+		{Term: "API"},
+	}
+
+	tests := []struct {
+		name       string
+		source     string
+		translated string
+		want       GlossaryReport
+	}{
+		{name: "no terms", source: "hello", translated: "hallo"},
+		{name: "kept", source: "Open Translatify", translated: "Öffne Translatify", want: GlossaryReport{Applied: []string{"Translatify"}}},
+		{name: "rendered", source: "Join the chat room", translated: "Tritt dem Chatraum bei", want: GlossaryReport{Applied: []string{"chat room"}}},
+		{name: "lost", source: "Join the chat room", translated: "Tritt dem Raum bei", want: GlossaryReport{Violations: []string{"chat room"}}},
+		{name: "no rendering keeps the term", source: "Call the API", translated: "Rufe die API auf", want: GlossaryReport{Applied: []string{"API"}}},
+		{
+			name:       "case and word boundaries",
+			source:     "translatify invoice",
+			translated: "TRANSLATIFY Rechnungen",
+			want:       GlossaryReport{Applied: []string{"Translatify"}, Violations: []string{"invoice"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := glossary.Check(tt.source, tt.translated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
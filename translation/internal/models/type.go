@@ -1,20 +1,281 @@
 package models
 
-type ConsumerResponse struct {
-	SourceLang string `json:"sourceLang"`
-	TargetLang string `json:"targetLang"`
-	MessageID  string `json:"messageID"`
-	Content    string `json:"content"`
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ContextMessage is an earlier message of the conversation. It is shown to
+// the provider to resolve references, but never translated itself.
+type ContextMessage struct {
+	Sender  string `json:"sender"`
+	Content string `json:"content"`
 }
 
 type TranslationResponse struct {
-	TranslatedContent string `json:"translatedContent"`
+	TranslatedContent string         `json:"translatedContent"`
+	Provider          string         `json:"provider"`
+	Model             string         `json:"model"`
+	Glossary          GlossaryReport `json:"glossary"`
+}
+
+// TranslateRequest is a single message to translate. ChatID and Participants
+// are optional and select the chat and user glossaries that apply. Provider
+// and SkipCache ask for a fresh translation, e.g. when a user wants another
+// take on a message. Username is who the usage is billed to. Degrade is set
+// for users over quota and, unlike Provider, only swaps the provider that
+// translates uncached texts.
+type TranslateRequest struct {
+	SourceLang   string
+	TargetLang   string
+	Content      string
+	Context      []ContextMessage
+	ChatID       string
+	Participants []string
+	Style        TranslationStyle
+	Provider     string
+	SkipCache    bool
+	Username     string
+	Degrade      string
+}
+
+// Fresh reports whether the request bypasses the cache and the routes.
+func (r TranslateRequest) Fresh() bool {
+	return r.Provider != "" || r.SkipCache
+}
+
+// Payer is the user the request is billed to, the first participant unless
+// set explicitly.
+func (r TranslateRequest) Payer() string {
+	if r.Username != "" {
+		return r.Username
+	}
+	for _, username := range r.Participants {
+		if username != "" {
+			return username
+		}
+	}
+	return ""
+}
+
+// BatchItem is one message of a batch; items of a batch may use different
+// language pairs.
+type BatchItem struct {
+	MessageID string
+	TranslateRequest
+}
+
+type BatchResult struct {
+	MessageID         string
+	TranslatedContent string
+	Provider          string
+	Model             string
+	Glossary          GlossaryReport
+	Err               error
 }
 
 type TranslationService interface {
-	TranslateMessage(content, sourceLanguage, targetLanguage string) (*TranslationResponse, error)
+	TranslateMessage(ctx context.Context, req TranslateRequest) (*TranslationResponse, error)
+	TranslateBatch(ctx context.Context, items []BatchItem) []BatchResult
+	StreamTranslate(ctx context.Context, req TranslateRequest, onDelta func(delta string) error) (*TranslationResponse, error)
+	DetectLanguage(ctx context.Context, content, chatID, username string) (*Detection, error)
+	InvalidateCache(ctx context.Context, sourceLanguage, targetLanguage string) (int64, error)
+	CacheStats() CacheStats
+
+	CreateGlossaryEntry(ctx context.Context, entry *GlossaryEntry) (*GlossaryEntry, error)
+	ListGlossaryEntries(ctx context.Context, scope GlossaryScope, ownerID string) ([]*GlossaryEntry, error)
+	DeleteGlossaryEntry(ctx context.Context, scope GlossaryScope, ownerID, entryID string) error
+
+	UsageReport(ctx context.Context, filter UsageFilter) (*UsageReport, error)
+}
+
+// TranslateOptions holds the optional inputs of a translation. Providers that
+// cannot use an option ignore it. Provider replaces the route of the language
+// pair, without fallback, while the cache is still used.
+type TranslateOptions struct {
+	Context  []ContextMessage
+	Glossary Glossary
+	Style    TranslationStyle
+	Provider string
+}
+
+// IsZero reports whether the options leave the text to a plain translation;
+// Provider only picks who makes it.
+func (o TranslateOptions) IsZero() bool {
+	return len(o.Context) == 0 && len(o.Glossary) == 0 && o.Style == StyleDefault
+}
+
+// TranslationStyle steers the register of a translation. The default leaves
+// it to the provider.
+type TranslationStyle string
+
+const (
+	StyleDefault TranslationStyle = ""
+	StyleFormal  TranslationStyle = "formal"
+	StyleCasual  TranslationStyle = "casual"
+	StyleLiteral TranslationStyle = "literal"
+)
+
+func (s TranslationStyle) Valid() bool {
+	switch s {
+	case StyleDefault, StyleFormal, StyleCasual, StyleLiteral:
+		return true
+	default:
+		return false
+	}
+}
+
+// Translation is a finished translation and the provider and model that
+// produced it. Model is empty for providers that serve a single model.
+type Translation struct {
+	Text     string
+	Provider string
+	Model    string
+	Usage    Usage
+}
+
+// Translator is what the service translates with: it routes each language
+// pair to a TranslatorModel and takes care of caching and fallback.
+type Translator interface {
+	Translate(ctx context.Context, text string, opts TranslateOptions, sourceLanguage, targetLanguage string) (*Translation, error)
+	TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]*Translation, error)
+	TranslateStream(ctx context.Context, text string, opts TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(delta string) error) (*Translation, error)
+	// Retranslate asks the provider again without looking at the cache. An
+	// empty provider uses the route of the language pair.
+	Retranslate(ctx context.Context, text string, opts TranslateOptions, provider, sourceLanguage, targetLanguage string) (*Translation, error)
+	RetranslateStream(ctx context.Context, text string, opts TranslateOptions, provider, sourceLanguage, targetLanguage string, onDelta func(delta string) error) (*Translation, error)
 }
 
 type TranslatorModel interface {
-	TranslateText(text, sourceLanguage, targetLanguage string) (string, error)
+	TranslateText(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error)
+	TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]string, error)
+	TranslateWithOptions(ctx context.Context, text string, opts TranslateOptions, sourceLanguage, targetLanguage string) (string, error)
+	// TranslateStream hands the translation to onDelta piece by piece as the
+	// provider produces it and returns the complete text. Providers without
+	// streaming deliver it as a single delta.
+	TranslateStream(ctx context.Context, text string, opts TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(delta string) error) (string, error)
+}
+
+// Detection is the outcome of language detection. Language is empty when
+// nothing could be recognised; Languages lists every language found, the
+// dominant one first.
+type Detection struct {
+	Language   string
+	Confidence float64
+	Mixed      bool
+	Languages  []string
+
+	// Provider, Model and Usage tell what asking an LLM cost, if one was.
+	Provider string
+	Model    string
+	Usage    Usage
+}
+
+type LanguageDetector interface {
+	Detect(ctx context.Context, text string) (*Detection, error)
+}
+
+// CacheKey identifies a cached translation. Provider, model and prompt version
+// are part of the key, so changing any of them never serves stale output.
+// Options is a digest of the translate options (conversation context and
+// glossary), empty without any.
+type CacheKey struct {
+	Provider      string
+	Model         string
+	PromptVersion string
+	SourceLang    string
+	TargetLang    string
+	Text          string
+	Options       string
+}
+
+type TranslationCache interface {
+	Get(ctx context.Context, key CacheKey) (string, bool, error)
+	Set(ctx context.Context, key CacheKey, translatedText string, ttl time.Duration) error
+	InvalidatePair(ctx context.Context, sourceLang, targetLang string) (int64, error)
+}
+
+type InstrumentedCache interface {
+	TranslationCache
+	Stats() CacheStats
+}
+
+type CacheStats struct {
+	Backend string
+	Hits    int64
+	Misses  int64
+	Errors  int64
+}
+
+type GlossaryScope string
+
+const (
+	GlossaryScopeChat GlossaryScope = "chat"
+	GlossaryScopeUser GlossaryScope = "user"
+)
+
+var (
+	ErrInvalidGlossaryEntry  = errors.New("invalid glossary entry")
+	ErrGlossaryEntryNotFound = errors.New("glossary entry not found")
+	ErrGlossaryEntryExists   = errors.New("glossary entry already exists")
+
+	ErrInvalidStyle    = errors.New("style must be formal, casual or literal")
+	ErrUnknownProvider = errors.New("unknown translation provider")
+
+	ErrProviderUnavailable = errors.New("translation provider unavailable")
+)
+
+// UnavailableError is returned while a provider is rate limited or its
+// circuit breaker is open. Callers should not ask it again before RetryAfter
+// has passed. It matches ErrProviderUnavailable.
+type UnavailableError struct {
+	Provider   string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *UnavailableError) Error() string {
+	msg := fmt.Sprintf("%s is unavailable for %s", e.Provider, e.RetryAfter.Round(time.Second))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *UnavailableError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrProviderUnavailable}
+	}
+	return []error{ErrProviderUnavailable, e.Err}
+}
+
+// GlossaryEntry pins how a term is rendered for a language pair. Either
+// language may be "*" to match every language. DoNotTranslate entries keep
+// the term as written and have no rendering.
+type GlossaryEntry struct {
+	EntryID        string
+	Scope          GlossaryScope
+	OwnerID        string
+	Term           string
+	SourceLang     string
+	TargetLang     string
+	Rendering      string
+	DoNotTranslate bool
+	CreatedAt      time.Time
+}
+
+// GlossaryOwner names a chat or a user whose glossary applies.
+type GlossaryOwner struct {
+	Scope   GlossaryScope
+	OwnerID string
+}
+
+type GlossaryStore interface {
+	CreateEntry(ctx context.Context, entry *GlossaryEntry) (string, error)
+	ListEntries(ctx context.Context, scope GlossaryScope, ownerID string) ([]*GlossaryEntry, error)
+	DeleteEntry(ctx context.Context, scope GlossaryScope, ownerID, entryID string) error
+	// FindEntries returns the entries of all owners that match the pair.
+	FindEntries(ctx context.Context, owners []GlossaryOwner, sourceLang, targetLang string) ([]*GlossaryEntry, error)
 }
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQuotaExceeded      = errors.New("translation quota exceeded")
+	ErrInvalidUsageFilter = errors.New("invalid usage filter")
+)
+
+// Usage counts the tokens a provider billed. Providers that do not bill per
+// token, or served the text from the cache, report none.
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+func (u Usage) Total() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+func (u Usage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0
+}
+
+// Share returns the part of u that falls on part of whole, e.g. one text's
+// share of a batch by its length.
+func (u Usage) Share(part, whole int) Usage {
+	if whole <= 0 {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     u.PromptTokens * int64(part) / int64(whole),
+		CompletionTokens: u.CompletionTokens * int64(part) / int64(whole),
+	}
+}
+
+// UsageMeter adds up the usage of the provider calls made for one request,
+// failed ones and glossary retries included.
+type UsageMeter struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+func (m *UsageMeter) Add(u Usage) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.PromptTokens += u.PromptTokens
+	m.usage.CompletionTokens += u.CompletionTokens
+}
+
+func (m *UsageMeter) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// BilledError is a failed provider call that was billed all the same, e.g. a
+// completion whose answer could not be used.
+type BilledError struct {
+	Provider string
+	Model    string
+	Usage    Usage
+	Err      error
+}
+
+func (e *BilledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BilledError) Unwrap() error {
+	return e.Err
+}
+
+// BilledErrors collects the billed failures in err, which may join the
+// failures of several providers.
+func BilledErrors(err error) []*BilledError {
+	var billed []*BilledError
+	switch e := err.(type) {
+	case nil:
+	case *BilledError:
+		billed = append(billed, e)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			billed = append(billed, BilledErrors(err)...)
+		}
+	case interface{ Unwrap() error }:
+		billed = BilledErrors(e.Unwrap())
+	}
+	return billed
+}
+
+// UsageRecord is the usage of one translation, attributed to the user and
+// chat it was made for. Language detection by an LLM is recorded with the
+// detected language as SourceLang and no TargetLang.
+type UsageRecord struct {
+	Username   string
+	ChatID     string
+	SourceLang string
+	TargetLang string
+	Provider   string
+	Model      string
+	Usage
+	CreatedAt time.Time
+}
+
+// UsageFilter selects records for a report. Empty fields match everything;
+// a zero Until means up to now.
+type UsageFilter struct {
+	Username string
+	ChatID   string
+	Since    time.Time
+	Until    time.Time
+}
+
+// UsageSummary is the usage of one user, chat, language pair and model over
+// the reported period.
+type UsageSummary struct {
+	Username   string
+	ChatID     string
+	SourceLang string
+	TargetLang string
+	Provider   string
+	Model      string
+	Usage
+	Requests int64
+}
+
+type UsageStore interface {
+	RecordUsage(ctx context.Context, record *UsageRecord) error
+	// TotalUsage returns the tokens billed to username since the given time.
+	TotalUsage(ctx context.Context, username string, since time.Time) (Usage, error)
+	SummarizeUsage(ctx context.Context, filter UsageFilter) ([]*UsageSummary, error)
+}
+
+// QuotaAction is what happens to translations of a user over quota.
+type QuotaAction string
+
+const (
+	QuotaReject  QuotaAction = "reject"
+	QuotaDegrade QuotaAction = "degrade"
+)
+
+// QuotaPolicy limits the tokens a user may spend per UTC day and month. A
+// zero limit is unlimited. Degrading sends the user's translations to
+// DegradeProvider, which should be cheap, instead of rejecting them.
+type QuotaPolicy struct {
+	DailyTokens     int64
+	MonthlyTokens   int64
+	Action          QuotaAction
+	DegradeProvider string
+}
+
+func (p QuotaPolicy) Enabled() bool {
+	return p.DailyTokens > 0 || p.MonthlyTokens > 0
+}
+
+// QuotaStatus is what a user spent against the policy's limits.
+type QuotaStatus struct {
+	DailyUsed    int64
+	DailyLimit   int64
+	MonthlyUsed  int64
+	MonthlyLimit int64
+	Exceeded     bool
+}
+
+// UsageReport is the usage over a period and, for a single user, the quota
+// status right now.
+type UsageReport struct {
+	Summaries []*UsageSummary
+	Quota     *QuotaStatus
+}
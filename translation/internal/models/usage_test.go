@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBilledErrors(t *testing.T) {
+	primary := &BilledError{Provider: "openai", Usage: Usage{PromptTokens: 10}, Err: errors.New("bad answer")}
+	fallback := &BilledError{Provider: "other", Usage: Usage{CompletionTokens: 3}, Err: errors.New("bad answer")}
+
+	tests := []struct {
+		name string
+		err  error
+		want []*BilledError
+	}{
+		{name: "nil"},
+		{name: "unbilled", err: errors.New("timeout")},
+		{name: "wrapped", err: fmt.Errorf("openai: %w", primary), want: []*BilledError{primary}},
+		{
+			name: "joined",
+			err:  errors.Join(fmt.Errorf("openai: %w", primary), errors.New("libre: down"), fmt.Errorf("other: %w", fallback)),
+			want: []*BilledError{primary, fallback},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BilledErrors(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BilledErrors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageShare(t *testing.T) {
+	u := Usage{PromptTokens: 100, CompletionTokens: 40}
+
+	tests := []struct {
+		part, whole int
+		want        Usage
+	}{
+		{part: 1, whole: 4, want: Usage{PromptTokens: 25, CompletionTokens: 10}},
+		{part: 4, whole: 4, want: u},
+		{part: 1, whole: 0, want: Usage{}},
+	}
+	for _, tt := range tests {
+		if got := u.Share(tt.part, tt.whole); got != tt.want {
+			t.Errorf("Share(%d, %d) = %+v, want %+v", tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package placeholder
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	zeroWidthJoiner = '\u200d'
+	variationEmoji  = '\ufe0f'
+	keycap          = '\u20e3'
+)
+
+// isEmoji covers the pictographic blocks, including flags and skin tones.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1f000 && r <= 0x1faff:
+		return true
+	case r >= 0x2600 && r <= 0x27bf:
+		return true
+	case r >= 0x2b00 && r <= 0x2bff:
+		return true
+	}
+	return false
+}
+
+// isEmojiModifier is a rune that only extends the emoji before it.
+func isEmojiModifier(r rune) bool {
+	return r == zeroWidthJoiner || r == variationEmoji || r == keycap || (r >= 0xe0020 && r <= 0xe007f)
+}
+
+// isKeycapBase is a rune that turns into an emoji when followed by the
+// combining keycap, as in 1️⃣.
+func isKeycapBase(r rune) bool {
+	return r >= '0' && r <= '9' || r == '#' || r == '*'
+}
+
+// keycapAt reports whether a keycap sequence, a base with an optional
+// variation selector and the combining keycap, starts at i.
+func keycapAt(text string, i int) bool {
+	r, size := utf8.DecodeRuneInString(text[i:])
+	if !isKeycapBase(r) {
+		return false
+	}
+	next, nextSize := utf8.DecodeRuneInString(text[i+size:])
+	if next == variationEmoji {
+		next, _ = utf8.DecodeRuneInString(text[i+size+nextSize:])
+	}
+	return next == keycap
+}
+
+// emojiSpans finds runs of emoji. A run keeps its joiners, variation
+// selectors and tags, so family and flag sequences stay in one piece.
+func emojiSpans(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		switch {
+		case isEmoji(r), keycapAt(text, i):
+			if start < 0 {
+				start = i
+			}
+		case isEmojiModifier(r) && start >= 0:
+		default:
+			if start >= 0 {
+				spans = append(spans, span{start, i})
+				start = -1
+			}
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
@@ -0,0 +1,212 @@
+package placeholder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	openBracket  = "⟦"
+	closeBracket = "⟧"
+)
+
+var (
+	ErrMissingPlaceholder    = errors.New("placeholder missing from translation")
+	ErrUnexpectedPlaceholder = errors.New("unexpected placeholder in translation")
+)
+
+var (
+	codeFence  = regexp.MustCompile("(?s)```.*?```")
+	inlineCode = regexp.MustCompile("`[^`\n]+`")
+	url        = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+	email      = regexp.MustCompile(`[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)+`)
+	mention    = regexp.MustCompile(`@[\p{L}\p{N}_][\p{L}\p{N}_.-]*`)
+	token      = regexp.MustCompile(openBracket + `\s*(\d+)\s*` + closeBracket)
+)
+
+// span is a protected byte range of the original text.
+type span struct {
+	start, end int
+}
+
+// Protected is a text whose protected spans were swapped for placeholders.
+type Protected struct {
+	// Text is what the provider gets to see.
+	Text  string
+	spans []string
+}
+
+// Protect replaces code, URLs, email addresses, @mentions and emoji with
+// numbered placeholders such as ⟦0⟧. Texts that already contain the
+// placeholder brackets are passed through unchanged.
+func Protect(text string) *Protected {
+	if strings.Contains(text, openBracket) || strings.Contains(text, closeBracket) {
+		return &Protected{Text: text}
+	}
+
+	// Earlier matchers win where spans overlap, so a URL inside inline code
+	// stays part of the code.
+	var spans []span
+	add := func(start, end int) {
+		for _, s := range spans {
+			if start < s.end && s.start < end {
+				return
+			}
+		}
+		spans = append(spans, span{start, end})
+	}
+
+	for _, re := range []*regexp.Regexp{codeFence, inlineCode, url, email} {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			end := m[1]
+			if re == url {
+				end = m[0] + len(strings.TrimRight(text[m[0]:end], ".,;:!?)]}'\""))
+			}
+			add(m[0], end)
+		}
+	}
+	for _, m := range mention.FindAllStringIndex(text, -1) {
+		// An @ inside a word is not a mention.
+		if before, _ := utf8.DecodeLastRuneInString(text[:m[0]]); m[0] > 0 && isWordRune(before) {
+			continue
+		}
+		add(m[0], m[0]+len(strings.TrimRight(text[m[0]:m[1]], ".-")))
+	}
+	for _, s := range emojiSpans(text) {
+		add(s.start, s.end)
+	}
+
+	if len(spans) == 0 {
+		return &Protected{Text: text}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	p := &Protected{spans: make([]string, len(spans))}
+	var b strings.Builder
+	last := 0
+	for i, s := range spans {
+		b.WriteString(text[last:s.start])
+		b.WriteString(openBracket + strconv.Itoa(i) + closeBracket)
+		p.spans[i] = text[s.start:s.end]
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	p.Text = b.String()
+
+	return p
+}
+
+// Restore puts the protected spans back into a translation of p.Text. Every
+// placeholder has to come back exactly once; providers may add spaces inside
+// the brackets, which are tolerated.
+func (p *Protected) Restore(translated string) (string, error) {
+	if len(p.spans) == 0 {
+		return translated, nil
+	}
+
+	seen := make([]bool, len(p.spans))
+	var err error
+	restored := token.ReplaceAllStringFunc(translated, func(match string) string {
+		i, ok := p.index(match)
+		if !ok || seen[i] {
+			err = fmt.Errorf("%w: %s", ErrUnexpectedPlaceholder, match)
+			return match
+		}
+		seen[i] = true
+		return p.spans[i]
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for i, ok := range seen {
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingPlaceholder, openBracket+strconv.Itoa(i)+closeBracket)
+		}
+	}
+	return restored, nil
+}
+
+// Check reports whether translated, a translation of the protected text,
+// brought back every placeholder of text exactly once, which is what Restore
+// will demand. Texts without placeholders always pass.
+func Check(text, translated string) error {
+	n := 0
+	for _, match := range token.FindAllStringSubmatch(text, -1) {
+		i, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil
+		}
+		n = max(n, i+1)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	_, err := (&Protected{spans: make([]string, n)}).Restore(translated)
+	return err
+}
+
+func (p *Protected) index(match string) (int, bool) {
+	i, err := strconv.Atoi(token.FindStringSubmatch(match)[1])
+	return i, err == nil && i < len(p.spans)
+}
+
+// Protects reports whether the text had anything to protect.
+func (p *Protected) Protects() bool {
+	return len(p.spans) > 0
+}
+
+// maxTokenLen bounds how long a possibly unfinished placeholder is held back.
+const maxTokenLen = 24
+
+// Stream restores placeholders in a translation that arrives in pieces. It is
+// best effort for display; Restore on the complete text has the final word.
+type Stream struct {
+	p       *Protected
+	pending string
+}
+
+func (p *Protected) Stream() *Stream {
+	return &Stream{p: p}
+}
+
+// Next returns the part of the translation so far that can be shown. A
+// placeholder split across deltas is held back until it is complete.
+func (s *Stream) Next(delta string) string {
+	s.pending += delta
+
+	cut := len(s.pending)
+	if i := strings.LastIndex(s.pending, openBracket); i >= 0 && len(s.pending)-i < maxTokenLen &&
+		!strings.Contains(s.pending[i:], closeBracket) {
+		cut = i
+	}
+
+	ready := s.pending[:cut]
+	s.pending = s.pending[cut:]
+	return s.replace(ready)
+}
+
+// Flush returns whatever is still held back.
+func (s *Stream) Flush() string {
+	rest := s.pending
+	s.pending = ""
+	return s.replace(rest)
+}
+
+func (s *Stream) replace(text string) string {
+	if len(s.p.spans) == 0 {
+		return text
+	}
+	return token.ReplaceAllStringFunc(text, func(match string) string {
+		if i, ok := s.p.index(match); ok {
+			return s.p.spans[i]
+		}
+		return match
+	})
+}
@@ -0,0 +1,170 @@
+package placeholder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProtect(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantText  string
+		wantSpans []string
+	}{
+		{
+			name:      "url",
+			text:      "see https://example.com/a?b=1.",
+			wantText:  "see ⟦0⟧.",
+			wantSpans: []string{"https://example.com/a?b=1"},
+		},
+		{
+			name:      "mention and email",
+			text:      "@bob wrote to bob@example.com",
+			wantText:  "⟦0⟧ wrote to ⟦1⟧",
+			wantSpans: []string{"@bob", "bob@example.com"},
+		},
+		{
+			name:      "inline code keeps its url",
+			text:      "run `curl https://example.com` now",
+			wantText:  "run ⟦0⟧ now",
+			wantSpans: []string{"`curl https://example.com`"},
+		},
+		{
+			name:      "code fence",
+			text:      "look:\n```\nx := 1\n```",
+			wantText:  "look:\n⟦0⟧",
+			wantSpans: []string{"```\nx := 1\n```"},
+		},
+		{
+			name:      "emoji sequence",
+			text:      "hi 👨‍👩‍👧 there",
+			wantText:  "hi ⟦0⟧ there",
+			wantSpans: []string{"👨‍👩‍👧"},
+		},
+		{
+			name:      "keycap",
+			text:      "step 1️⃣ then #⃣",
+			wantText:  "step ⟦0⟧ then ⟦1⟧",
+			wantSpans: []string{"1️⃣", "#⃣"},
+		},
+		{
+			name:     "plain digits",
+			text:     "call 123 at 5",
+			wantText: "call 123 at 5",
+		},
+		{
+			name:     "at inside a word",
+			text:     "meet me at home@7",
+			wantText: "meet me at home@7",
+		},
+		{
+			name:     "brackets pass through",
+			text:     "keep ⟦0⟧ and https://example.com",
+			wantText: "keep ⟦0⟧ and https://example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Protect(tt.text)
+			if p.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", p.Text, tt.wantText)
+			}
+			if !reflect.DeepEqual(p.spans, tt.wantSpans) {
+				t.Errorf("spans = %q, want %q", p.spans, tt.wantSpans)
+			}
+			if p.Protects() != (len(tt.wantSpans) > 0) {
+				t.Errorf("Protects = %v with %d spans", p.Protects(), len(tt.wantSpans))
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	p := Protect("ask @bob about https://example.com 1️⃣")
+
+	tests := []struct {
+		name       string
+		translated string
+		want       string
+		wantErr    error
+	}{
+		{
+			name:       "in order",
+			translated: "frag ⟦0⟧ nach ⟦1⟧ ⟦2⟧",
+			want:       "frag @bob nach https://example.com 1️⃣",
+		},
+		{
+			name:       "reordered with spaces",
+			translated: "⟦2⟧ nach ⟦ 1 ⟧ frag ⟦0⟧",
+			want:       "1️⃣ nach https://example.com frag @bob",
+		},
+		{
+			name:       "missing",
+			translated: "frag ⟦0⟧ nach ⟦1⟧",
+			wantErr:    ErrMissingPlaceholder,
+		},
+		{
+			name:       "duplicated",
+			translated: "frag ⟦0⟧ ⟦0⟧ nach ⟦1⟧ ⟦2⟧",
+			wantErr:    ErrUnexpectedPlaceholder,
+		},
+		{
+			name:       "unknown",
+			translated: "frag ⟦0⟧ nach ⟦1⟧ ⟦2⟧ ⟦3⟧",
+			wantErr:    ErrUnexpectedPlaceholder,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Restore(tt.translated)
+			checkErr(t, err, tt.wantErr)
+			if got != tt.want {
+				t.Errorf("Restore = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		translated string
+		wantErr    error
+	}{
+		{name: "nothing protected", text: "hello", translated: "hallo"},
+		{name: "kept", text: "hi ⟦0⟧ and ⟦1⟧", translated: "⟦1⟧ und hallo ⟦0⟧"},
+		{name: "lost", text: "hi ⟦0⟧ and ⟦1⟧", translated: "hallo ⟦0⟧", wantErr: ErrMissingPlaceholder},
+		{name: "doubled", text: "hi ⟦0⟧", translated: "⟦0⟧ hallo ⟦0⟧", wantErr: ErrUnexpectedPlaceholder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, Check(tt.text, tt.translated), tt.wantErr)
+		})
+	}
+}
+
+func checkErr(t *testing.T, err, want error) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Errorf("error = %v, want %v", err, want)
+	}
+}
+
+func TestStream(t *testing.T) {
+	p := Protect("see https://example.com")
+
+	s := p.Stream()
+	var got string
+	for _, delta := range []string{"sieh ", "⟦", "0", "⟧ an"} {
+		got += s.Next(delta)
+	}
+	got += s.Flush()
+
+	if want := "sieh https://example.com an"; got != want {
+		t.Errorf("stream = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package placeholder
+
+import (
+	"context"
+	"log"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// Translator protects every text before it reaches the wrapped translator and
+// restores the spans afterwards. When a placeholder does not come back, the
+// original text is returned instead of a translation with holes in it.
+type Translator struct {
+	next models.Translator
+}
+
+func NewTranslator(next models.Translator) *Translator {
+	return &Translator{next: next}
+}
+
+func (t *Translator) Translate(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) (*models.Translation, error) {
+	p := Protect(text)
+	translated, err := t.next.Translate(ctx, p.Text, opts, sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+	return restore(p, text, translated), nil
+}
+
+func (t *Translator) Retranslate(ctx context.Context, text string, opts models.TranslateOptions, provider, sourceLanguage, targetLanguage string) (*models.Translation, error) {
+	p := Protect(text)
+	translated, err := t.next.Retranslate(ctx, p.Text, opts, provider, sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+	return restore(p, text, translated), nil
+}
+
+// TranslateStream restores placeholders in the deltas as they arrive. The
+// returned text is validated like any other translation, so callers should
+// prefer it over the concatenated deltas.
+func (t *Translator) TranslateStream(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (*models.Translation, error) {
+	return stream(text, onDelta, func(protected string, onDelta func(string) error) (*models.Translation, error) {
+		return t.next.TranslateStream(ctx, protected, opts, sourceLanguage, targetLanguage, onDelta)
+	})
+}
+
+func (t *Translator) RetranslateStream(ctx context.Context, text string, opts models.TranslateOptions, provider, sourceLanguage, targetLanguage string, onDelta func(string) error) (*models.Translation, error) {
+	return stream(text, onDelta, func(protected string, onDelta func(string) error) (*models.Translation, error) {
+		return t.next.RetranslateStream(ctx, protected, opts, provider, sourceLanguage, targetLanguage, onDelta)
+	})
+}
+
+func stream(text string, onDelta func(string) error, translate func(protected string, onDelta func(string) error) (*models.Translation, error)) (*models.Translation, error) {
+	p := Protect(text)
+	s := p.Stream()
+
+	translated, err := translate(p.Text, func(delta string) error {
+		if ready := s.Next(delta); ready != "" {
+			return onDelta(ready)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rest := s.Flush(); rest != "" {
+		if err = onDelta(rest); err != nil {
+			return nil, err
+		}
+	}
+	return restore(p, text, translated), nil
+}
+
+func (t *Translator) TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]*models.Translation, error) {
+	protected := make([]*Protected, len(texts))
+	pending := make([]string, len(texts))
+	for i, text := range texts {
+		protected[i] = Protect(text)
+		pending[i] = protected[i].Text
+	}
+
+	translated, err := t.next.TranslateBatch(ctx, pending, sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range translated {
+		translated[i] = restore(protected[i], texts[i], translated[i])
+	}
+	return translated, nil
+}
+
+// restore returns a copy of translated with the spans put back, keeping the
+// provider and model so callers still see who was asked.
+func restore(p *Protected, original string, translated *models.Translation) *models.Translation {
+	restored := *translated
+	var err error
+	if restored.Text, err = p.Restore(translated.Text); err != nil {
+		log.Printf("Translation broke a protected span, keeping the original text: %v", err)
+		restored.Text = original
+	}
+	return &restored
+}
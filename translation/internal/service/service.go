@@ -1,30 +1,340 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
+
 	"github.com/HJyup/translatify-translation/internal/models"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type TranslationService struct {
-	translator models.TranslatorModel
+	translator models.Translator
+	detector   models.LanguageDetector
+	cache      models.InstrumentedCache
+	glossaries models.GlossaryStore
+	usage      models.UsageStore
+	quota      models.QuotaPolicy
 
 	channel *amqp.Channel
 }
 
-func NewTranslationService(translator models.TranslatorModel) *TranslationService {
-	return &TranslationService{translator: translator}
+func NewTranslationService(translator models.Translator, detector models.LanguageDetector, cache models.InstrumentedCache, glossaries models.GlossaryStore, usage models.UsageStore, quota models.QuotaPolicy) *TranslationService {
+	return &TranslationService{
+		translator: translator,
+		detector:   detector,
+		cache:      cache,
+		glossaries: glossaries,
+		usage:      usage,
+		quota:      quota,
+	}
+}
+
+func (s *TranslationService) TranslateMessage(ctx context.Context, req models.TranslateRequest) (*models.TranslationResponse, error) {
+	if !req.Style.Valid() {
+		return nil, models.ErrInvalidStyle
+	}
+	if err := s.admit(ctx, &req); err != nil {
+		return nil, err
+	}
+	return s.translate(ctx, req, s.glossaryFor(ctx, req))
 }
 
-func (s *TranslationService) TranslateMessage(sourceLang, targetLang, content string) (*models.TranslationResponse, error) {
-	translatedText, err := s.translator.TranslateText(content, sourceLang, targetLang)
+func (s *TranslationService) translate(ctx context.Context, req models.TranslateRequest, glossary models.Glossary) (*models.TranslationResponse, error) {
+	opts := models.TranslateOptions{Context: req.Context, Glossary: glossary, Style: req.Style, Provider: req.Degrade}
+
+	var (
+		translated *models.Translation
+		err        error
+	)
+	if req.Fresh() {
+		// Over quota, the cheaper provider wins over the one asked for.
+		provider := req.Provider
+		if req.Degrade != "" {
+			provider = req.Degrade
+		}
+		translated, err = s.translator.Retranslate(ctx, req.Content, opts, provider, req.SourceLang, req.TargetLang)
+	} else {
+		translated, err = s.translator.Translate(ctx, req.Content, opts, req.SourceLang, req.TargetLang)
+	}
 	if err != nil {
+		s.recordFailedUsage(ctx, req, err)
 		return nil, fmt.Errorf("translation error: %w", err)
 	}
+	s.recordUsage(ctx, req, translated)
 
 	response := &models.TranslationResponse{
-		TranslatedContent: translatedText,
+		TranslatedContent: translated.Text,
+		Glossary:          glossary.Check(req.Content, translated.Text),
+		Provider:          translated.Provider,
+		Model:             translated.Model,
 	}
 
 	return response, nil
 }
+
+// StreamTranslate passes the translation to onDelta as it is produced and
+// returns the complete, validated result once the provider is done. Fresh
+// requests skip the cache like TranslateMessage does.
+func (s *TranslationService) StreamTranslate(ctx context.Context, req models.TranslateRequest, onDelta func(string) error) (*models.TranslationResponse, error) {
+	if req.SourceLang == "" || req.TargetLang == "" || req.Content == "" {
+		return nil, errors.New("sourceLanguage, targetLanguage and content are required")
+	}
+
+	if !req.Style.Valid() {
+		return nil, models.ErrInvalidStyle
+	}
+	if err := s.admit(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	glossary := s.glossaryFor(ctx, req)
+	opts := models.TranslateOptions{Context: req.Context, Glossary: glossary, Style: req.Style, Provider: req.Degrade}
+
+	var (
+		translated *models.Translation
+		err        error
+	)
+	if req.Fresh() {
+		provider := req.Provider
+		if req.Degrade != "" {
+			provider = req.Degrade
+		}
+		translated, err = s.translator.RetranslateStream(ctx, req.Content, opts, provider, req.SourceLang, req.TargetLang, onDelta)
+	} else {
+		translated, err = s.translator.TranslateStream(ctx, req.Content, opts, req.SourceLang, req.TargetLang, onDelta)
+	}
+	if err != nil {
+		s.recordFailedUsage(ctx, req, err)
+		return nil, fmt.Errorf("translation error: %w", err)
+	}
+	s.recordUsage(ctx, req, translated)
+
+	return &models.TranslationResponse{
+		TranslatedContent: translated.Text,
+		Glossary:          glossary.Check(req.Content, translated.Text),
+		Provider:          translated.Provider,
+		Model:             translated.Model,
+	}, nil
+}
+
+// glossaryFor resolves the chat and participant glossaries for the request's
+// language pair, keeping only terms that occur in the content. A chat entry
+// beats a user entry for the same term, and an entry for the exact pair beats
+// a wildcard one. Lookup failures are logged and translate without glossary.
+func (s *TranslationService) glossaryFor(ctx context.Context, req models.TranslateRequest) models.Glossary {
+	var owners []models.GlossaryOwner
+	if req.ChatID != "" {
+		owners = append(owners, models.GlossaryOwner{Scope: models.GlossaryScopeChat, OwnerID: req.ChatID})
+	}
+	for _, username := range req.Participants {
+		if username != "" {
+			owners = append(owners, models.GlossaryOwner{Scope: models.GlossaryScopeUser, OwnerID: username})
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+
+	entries, err := s.glossaries.FindEntries(ctx, owners, req.SourceLang, req.TargetLang)
+	if err != nil {
+		log.Printf("Failed to load glossaries, translating without: %v", err)
+		return nil
+	}
+
+	rank := func(entry *models.GlossaryEntry) int {
+		r := 0
+		if entry.Scope != models.GlossaryScopeChat {
+			r += 2
+		}
+		if entry.SourceLang == "*" || entry.TargetLang == "*" {
+			r++
+		}
+		return r
+	}
+
+	best := make(map[string]*models.GlossaryEntry)
+	for _, entry := range entries {
+		term := strings.ToLower(entry.Term)
+		if current, ok := best[term]; !ok || rank(entry) < rank(current) {
+			best[term] = entry
+		}
+	}
+
+	var glossary models.Glossary
+	for _, entry := range best {
+		glossary = append(glossary, models.GlossaryTerm{
+			Term:           entry.Term,
+			Rendering:      entry.Rendering,
+			DoNotTranslate: entry.DoNotTranslate,
+		})
+	}
+	sort.Slice(glossary, func(i, j int) bool {
+		return strings.ToLower(glossary[i].Term) < strings.ToLower(glossary[j].Term)
+	})
+
+	return glossary.Relevant(req.Content)
+}
+
+// TranslateBatch groups items by language pair so every pair costs a single
+// provider call, and returns one result per item in the original order. Items
+// carrying conversation context, glossary terms, a style or a provider, or
+// degraded by the quota, need a request of their own and are translated one
+// by one.
+func (s *TranslationService) TranslateBatch(ctx context.Context, items []models.BatchItem) []models.BatchResult {
+	results := make([]models.BatchResult, len(items))
+
+	type pair struct{ source, target string }
+	groups := make(map[pair][]int)
+	var order []pair
+	for i, item := range items {
+		results[i].MessageID = item.MessageID
+		if item.SourceLang == "" || item.TargetLang == "" || item.Content == "" {
+			results[i].Err = errors.New("sourceLanguage, targetLanguage and content are required")
+			continue
+		}
+		if !item.Style.Valid() {
+			results[i].Err = models.ErrInvalidStyle
+			continue
+		}
+		if err := s.admit(ctx, &items[i].TranslateRequest); err != nil {
+			results[i].Err = err
+			continue
+		}
+		item = items[i]
+
+		if glossary := s.glossaryFor(ctx, item.TranslateRequest); len(item.Context) > 0 || len(glossary) > 0 || item.Style != models.StyleDefault || item.Fresh() || item.Degrade != "" {
+			resp, err := s.translate(ctx, item.TranslateRequest, glossary)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].TranslatedContent = resp.TranslatedContent
+			results[i].Glossary = resp.Glossary
+			results[i].Provider = resp.Provider
+			results[i].Model = resp.Model
+			continue
+		}
+
+		p := pair{item.SourceLang, item.TargetLang}
+		if _, ok := groups[p]; !ok {
+			order = append(order, p)
+		}
+		groups[p] = append(groups[p], i)
+	}
+
+	for _, p := range order {
+		indexes := groups[p]
+		texts := make([]string, len(indexes))
+		for j, i := range indexes {
+			texts[j] = items[i].Content
+		}
+
+		translated, err := s.translator.TranslateBatch(ctx, texts, p.source, p.target)
+		if err != nil {
+			s.recordFailedBatchUsage(ctx, items, indexes, err)
+		}
+		for j, i := range indexes {
+			if err != nil {
+				results[i].Err = fmt.Errorf("translation error: %w", err)
+				continue
+			}
+			results[i].TranslatedContent = translated[j].Text
+			results[i].Provider = translated[j].Provider
+			results[i].Model = translated[j].Model
+			s.recordUsage(ctx, items[i].TranslateRequest, translated[j])
+		}
+	}
+
+	return results
+}
+
+// DetectLanguage bills an LLM asked for help to username, like a translation.
+func (s *TranslationService) DetectLanguage(ctx context.Context, content, chatID, username string) (*models.Detection, error) {
+	if content == "" {
+		return nil, errors.New("content is required")
+	}
+
+	detection, err := s.detector.Detect(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	s.recordUsage(ctx, models.TranslateRequest{Username: username, ChatID: chatID, SourceLang: detection.Language}, &models.Translation{
+		Provider: detection.Provider,
+		Model:    detection.Model,
+		Usage:    detection.Usage,
+	})
+	return detection, nil
+}
+
+func (s *TranslationService) InvalidateCache(ctx context.Context, sourceLang, targetLang string) (int64, error) {
+	if sourceLang == "" || targetLang == "" {
+		return 0, errors.New("sourceLanguage and targetLanguage are required")
+	}
+	return s.cache.InvalidatePair(ctx, sourceLang, targetLang)
+}
+
+func (s *TranslationService) CacheStats() models.CacheStats {
+	return s.cache.Stats()
+}
+
+// CreateGlossaryEntry validates and stores an entry. Missing languages default
+// to "*", so the entry applies to every pair.
+func (s *TranslationService) CreateGlossaryEntry(ctx context.Context, entry *models.GlossaryEntry) (*models.GlossaryEntry, error) {
+	if err := validScope(entry.Scope, entry.OwnerID); err != nil {
+		return nil, err
+	}
+
+	entry.Term = strings.TrimSpace(entry.Term)
+	entry.Rendering = strings.TrimSpace(entry.Rendering)
+	if entry.Term == "" {
+		return nil, fmt.Errorf("%w: term is required", models.ErrInvalidGlossaryEntry)
+	}
+	if entry.DoNotTranslate {
+		entry.Rendering = ""
+	} else if entry.Rendering == "" {
+		return nil, fmt.Errorf("%w: rendering is required unless the term is not to be translated", models.ErrInvalidGlossaryEntry)
+	}
+	if entry.SourceLang == "" {
+		entry.SourceLang = "*"
+	}
+	if entry.TargetLang == "" {
+		entry.TargetLang = "*"
+	}
+
+	if _, err := s.glossaries.CreateEntry(ctx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *TranslationService) ListGlossaryEntries(ctx context.Context, scope models.GlossaryScope, ownerID string) ([]*models.GlossaryEntry, error) {
+	if err := validScope(scope, ownerID); err != nil {
+		return nil, err
+	}
+	return s.glossaries.ListEntries(ctx, scope, ownerID)
+}
+
+func (s *TranslationService) DeleteGlossaryEntry(ctx context.Context, scope models.GlossaryScope, ownerID, entryID string) error {
+	if err := validScope(scope, ownerID); err != nil {
+		return err
+	}
+	if entryID == "" {
+		return fmt.Errorf("%w: entryID is required", models.ErrInvalidGlossaryEntry)
+	}
+	return s.glossaries.DeleteEntry(ctx, scope, ownerID, entryID)
+}
+
+func validScope(scope models.GlossaryScope, ownerID string) error {
+	if scope != models.GlossaryScopeChat && scope != models.GlossaryScopeUser {
+		return fmt.Errorf("%w: scope must be chat or user", models.ErrInvalidGlossaryEntry)
+	}
+	if ownerID == "" {
+		return fmt.Errorf("%w: ownerID is required", models.ErrInvalidGlossaryEntry)
+	}
+	return nil
+}
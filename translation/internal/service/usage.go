@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// admit checks the quota of the user a request is billed to. Over quota, it
+// either rejects the request or has it degraded to the cheaper provider.
+// Failing to read the usage lets the request through.
+func (s *TranslationService) admit(ctx context.Context, req *models.TranslateRequest) error {
+	username := req.Payer()
+	if !s.quota.Enabled() || username == "" {
+		return nil
+	}
+
+	quota, err := s.quotaStatus(ctx, username, time.Now())
+	if err != nil {
+		log.Printf("Failed to check the translation quota of %s, translating anyway: %v", username, err)
+		return nil
+	}
+	if !quota.Exceeded {
+		return nil
+	}
+
+	if s.quota.Action == models.QuotaDegrade {
+		req.Degrade = s.quota.DegradeProvider
+		return nil
+	}
+	if quota.DailyLimit > 0 && quota.DailyUsed >= quota.DailyLimit {
+		return fmt.Errorf("%w: %s used %d of %d tokens today", models.ErrQuotaExceeded, username, quota.DailyUsed, quota.DailyLimit)
+	}
+	return fmt.Errorf("%w: %s used %d of %d tokens this month", models.ErrQuotaExceeded, username, quota.MonthlyUsed, quota.MonthlyLimit)
+}
+
+// quotaStatus adds up what username spent this UTC day and month.
+func (s *TranslationService) quotaStatus(ctx context.Context, username string, now time.Time) (*models.QuotaStatus, error) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	daily, err := s.usage.TotalUsage(ctx, username, day)
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := s.usage.TotalUsage(ctx, username, month)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.QuotaStatus{
+		DailyUsed:    daily.Total(),
+		DailyLimit:   s.quota.DailyTokens,
+		MonthlyUsed:  monthly.Total(),
+		MonthlyLimit: s.quota.MonthlyTokens,
+		Exceeded: s.quota.DailyTokens > 0 && daily.Total() >= s.quota.DailyTokens ||
+			s.quota.MonthlyTokens > 0 && monthly.Total() >= s.quota.MonthlyTokens,
+	}, nil
+}
+
+// recordUsage stores what a translation was billed. Translations served from
+// the cache or by providers that do not bill per token are not recorded. The
+// translation already happened, so a failed write is only logged.
+func (s *TranslationService) recordUsage(ctx context.Context, req models.TranslateRequest, translated *models.Translation) {
+	if translated.Usage.IsZero() {
+		return
+	}
+
+	err := s.usage.RecordUsage(context.WithoutCancel(ctx), &models.UsageRecord{
+		Username:   req.Payer(),
+		ChatID:     req.ChatID,
+		SourceLang: req.SourceLang,
+		TargetLang: req.TargetLang,
+		Provider:   translated.Provider,
+		Model:      translated.Model,
+		Usage:      translated.Usage,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Failed to record translation usage of %s: %v", req.Payer(), err)
+	}
+}
+
+// recordFailedUsage stores what the failed provider calls behind err were
+// billed, e.g. completions whose answer could not be used.
+func (s *TranslationService) recordFailedUsage(ctx context.Context, req models.TranslateRequest, err error) {
+	for _, billed := range models.BilledErrors(err) {
+		s.recordUsage(ctx, req, &models.Translation{Provider: billed.Provider, Model: billed.Model, Usage: billed.Usage})
+	}
+}
+
+// recordFailedBatchUsage splits what a failed batch was billed over its items
+// by length, as for a successful one.
+func (s *TranslationService) recordFailedBatchUsage(ctx context.Context, items []models.BatchItem, indexes []int, err error) {
+	total := 0
+	for _, i := range indexes {
+		total += len(items[i].Content)
+	}
+	for _, billed := range models.BilledErrors(err) {
+		for _, i := range indexes {
+			s.recordUsage(ctx, items[i].TranslateRequest, &models.Translation{
+				Provider: billed.Provider,
+				Model:    billed.Model,
+				Usage:    billed.Usage.Share(len(items[i].Content), total),
+			})
+		}
+	}
+}
+
+// UsageReport summarizes the usage matching filter. With a username, the
+// report includes where the user stands against the quota.
+func (s *TranslationService) UsageReport(ctx context.Context, filter models.UsageFilter) (*models.UsageReport, error) {
+	if filter.Username == "" && filter.ChatID == "" {
+		return nil, fmt.Errorf("%w: username or chatID is required", models.ErrInvalidUsageFilter)
+	}
+	if !filter.Until.IsZero() && filter.Until.Before(filter.Since) {
+		return nil, fmt.Errorf("%w: until is before since", models.ErrInvalidUsageFilter)
+	}
+
+	summaries, err := s.usage.SummarizeUsage(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.UsageReport{Summaries: summaries}
+	if filter.Username != "" {
+		if report.Quota, err = s.quotaStatus(ctx, filter.Username, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to read the quota: %w", err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type GlossaryStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewGlossaryStore(pool *pgxpool.Pool) *GlossaryStore {
+	return &GlossaryStore{pool: pool}
+}
+
+const glossaryColumns = `entry_id, scope, owner_id, term, source_lang, target_lang, rendering, do_not_translate, created_at`
+
+func (s *GlossaryStore) CreateEntry(ctx context.Context, entry *models.GlossaryEntry) (string, error) {
+	query := `
+		INSERT INTO glossary_entries (scope, owner_id, term, source_lang, target_lang, rendering, do_not_translate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING entry_id, created_at
+	`
+	err := s.pool.QueryRow(ctx, query,
+		string(entry.Scope),
+		entry.OwnerID,
+		entry.Term,
+		strings.ToLower(entry.SourceLang),
+		strings.ToLower(entry.TargetLang),
+		entry.Rendering,
+		entry.DoNotTranslate,
+	).Scan(&entry.EntryID, &entry.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", models.ErrGlossaryEntryExists
+		}
+		return "", err
+	}
+	return entry.EntryID, nil
+}
+
+func (s *GlossaryStore) ListEntries(ctx context.Context, scope models.GlossaryScope, ownerID string) ([]*models.GlossaryEntry, error) {
+	query := `
+		SELECT ` + glossaryColumns + `
+		FROM glossary_entries
+		WHERE scope = $1 AND owner_id = $2
+		ORDER BY lower(term), source_lang, target_lang
+	`
+	rows, err := s.pool.Query(ctx, query, string(scope), ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return scanEntries(rows)
+}
+
+func (s *GlossaryStore) DeleteEntry(ctx context.Context, scope models.GlossaryScope, ownerID, entryID string) error {
+	tag, err := s.pool.Exec(ctx,
+		"DELETE FROM glossary_entries WHERE scope = $1 AND owner_id = $2 AND entry_id::text = $3",
+		string(scope), ownerID, entryID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrGlossaryEntryNotFound
+	}
+	return nil
+}
+
+func (s *GlossaryStore) FindEntries(ctx context.Context, owners []models.GlossaryOwner, sourceLang, targetLang string) ([]*models.GlossaryEntry, error) {
+	if len(owners) == 0 {
+		return nil, nil
+	}
+
+	scopes := make([]string, len(owners))
+	ownerIDs := make([]string, len(owners))
+	for i, owner := range owners {
+		scopes[i], ownerIDs[i] = string(owner.Scope), owner.OwnerID
+	}
+
+	query := `
+		SELECT ` + glossaryColumns + `
+		FROM glossary_entries
+		WHERE (scope, owner_id) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+		  AND source_lang IN ($3, '*')
+		  AND target_lang IN ($4, '*')
+	`
+	rows, err := s.pool.Query(ctx, query, scopes, ownerIDs, strings.ToLower(sourceLang), strings.ToLower(targetLang))
+	if err != nil {
+		return nil, err
+	}
+	return scanEntries(rows)
+}
+
+func scanEntries(rows pgx.Rows) ([]*models.GlossaryEntry, error) {
+	defer rows.Close()
+
+	var entries []*models.GlossaryEntry
+	for rows.Next() {
+		var (
+			entry models.GlossaryEntry
+			scope string
+		)
+		err := rows.Scan(
+			&entry.EntryID,
+			&scope,
+			&entry.OwnerID,
+			&entry.Term,
+			&entry.SourceLang,
+			&entry.TargetLang,
+			&entry.Rendering,
+			&entry.DoNotTranslate,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Scope = models.GlossaryScope(scope)
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
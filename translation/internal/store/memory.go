@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryGlossaryStore keeps glossaries in process memory. Entries are lost on
+// restart and not shared between replicas, so it is meant for development.
+type MemoryGlossaryStore struct {
+	mu      sync.RWMutex
+	entries map[string]*models.GlossaryEntry
+}
+
+func NewMemoryGlossaryStore() *MemoryGlossaryStore {
+	return &MemoryGlossaryStore{entries: make(map[string]*models.GlossaryEntry)}
+}
+
+func (s *MemoryGlossaryStore) CreateEntry(_ context.Context, entry *models.GlossaryEntry) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.SourceLang = strings.ToLower(entry.SourceLang)
+	entry.TargetLang = strings.ToLower(entry.TargetLang)
+	for _, existing := range s.entries {
+		if existing.Scope == entry.Scope && existing.OwnerID == entry.OwnerID &&
+			strings.EqualFold(existing.Term, entry.Term) &&
+			existing.SourceLang == entry.SourceLang && existing.TargetLang == entry.TargetLang {
+			return "", models.ErrGlossaryEntryExists
+		}
+	}
+
+	entry.EntryID = uuid.NewString()
+	entry.CreatedAt = time.Now()
+	stored := *entry
+	s.entries[entry.EntryID] = &stored
+	return entry.EntryID, nil
+}
+
+func (s *MemoryGlossaryStore) ListEntries(_ context.Context, scope models.GlossaryScope, ownerID string) ([]*models.GlossaryEntry, error) {
+	return s.find([]models.GlossaryOwner{{Scope: scope, OwnerID: ownerID}}, func(*models.GlossaryEntry) bool { return true }), nil
+}
+
+func (s *MemoryGlossaryStore) DeleteEntry(_ context.Context, scope models.GlossaryScope, ownerID, entryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[entryID]
+	if !ok || entry.Scope != scope || entry.OwnerID != ownerID {
+		return models.ErrGlossaryEntryNotFound
+	}
+	delete(s.entries, entryID)
+	return nil
+}
+
+func (s *MemoryGlossaryStore) FindEntries(_ context.Context, owners []models.GlossaryOwner, sourceLang, targetLang string) ([]*models.GlossaryEntry, error) {
+	sourceLang, targetLang = strings.ToLower(sourceLang), strings.ToLower(targetLang)
+	return s.find(owners, func(entry *models.GlossaryEntry) bool {
+		return (entry.SourceLang == "*" || entry.SourceLang == sourceLang) &&
+			(entry.TargetLang == "*" || entry.TargetLang == targetLang)
+	}), nil
+}
+
+func (s *MemoryGlossaryStore) find(owners []models.GlossaryOwner, match func(*models.GlossaryEntry) bool) []*models.GlossaryEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []*models.GlossaryEntry
+	for _, entry := range s.entries {
+		for _, owner := range owners {
+			if entry.Scope == owner.Scope && entry.OwnerID == owner.OwnerID && match(entry) {
+				copied := *entry
+				found = append(found, &copied)
+				break
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if !strings.EqualFold(a.Term, b.Term) {
+			return strings.ToLower(a.Term) < strings.ToLower(b.Term)
+		}
+		if a.SourceLang != b.SourceLang {
+			return a.SourceLang < b.SourceLang
+		}
+		return a.TargetLang < b.TargetLang
+	})
+	return found
+}
+
+// MemoryUsageStore keeps usage records in process memory, so quotas start over
+// on restart and apply per replica. It is meant for development.
+type MemoryUsageStore struct {
+	mu      sync.RWMutex
+	records []models.UsageRecord
+}
+
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{}
+}
+
+func (s *MemoryUsageStore) RecordUsage(_ context.Context, record *models.UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, *record)
+	return nil
+}
+
+func (s *MemoryUsageStore) TotalUsage(_ context.Context, username string, since time.Time) (models.Usage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var total models.Usage
+	for _, record := range s.records {
+		if record.Username == username && !record.CreatedAt.Before(since) {
+			total.PromptTokens += record.PromptTokens
+			total.CompletionTokens += record.CompletionTokens
+		}
+	}
+	return total, nil
+}
+
+func (s *MemoryUsageStore) SummarizeUsage(_ context.Context, filter models.UsageFilter) ([]*models.UsageSummary, error) {
+	until := filter.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type group struct{ username, chatID, source, target, provider, model string }
+	summaries := make(map[group]*models.UsageSummary)
+	for _, record := range s.records {
+		if filter.Username != "" && record.Username != filter.Username ||
+			filter.ChatID != "" && record.ChatID != filter.ChatID ||
+			record.CreatedAt.Before(filter.Since) || !record.CreatedAt.Before(until) {
+			continue
+		}
+
+		g := group{record.Username, record.ChatID, record.SourceLang, record.TargetLang, record.Provider, record.Model}
+		summary, ok := summaries[g]
+		if !ok {
+			summary = &models.UsageSummary{
+				Username:   record.Username,
+				ChatID:     record.ChatID,
+				SourceLang: record.SourceLang,
+				TargetLang: record.TargetLang,
+				Provider:   record.Provider,
+				Model:      record.Model,
+			}
+			summaries[g] = summary
+		}
+		summary.PromptTokens += record.PromptTokens
+		summary.CompletionTokens += record.CompletionTokens
+		summary.Requests++
+	}
+
+	found := make([]*models.UsageSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		found = append(found, summary)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		for _, cmp := range [][2]string{
+			{a.Username, b.Username}, {a.ChatID, b.ChatID}, {a.SourceLang, b.SourceLang},
+			{a.TargetLang, b.TargetLang}, {a.Provider, b.Provider},
+		} {
+			if cmp[0] != cmp[1] {
+				return cmp[0] < cmp[1]
+			}
+		}
+		return a.Model < b.Model
+	})
+	return found, nil
+}
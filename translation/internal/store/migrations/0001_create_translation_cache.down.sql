@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS translation_cache_pair_idx;
+
+DROP TABLE IF EXISTS translation_cache;
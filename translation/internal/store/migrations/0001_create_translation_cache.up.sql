@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS translation_cache (
+    cache_key       TEXT PRIMARY KEY,
+    provider        TEXT NOT NULL,
+    model           TEXT NOT NULL,
+    prompt_version  TEXT NOT NULL,
+    source_lang     TEXT NOT NULL,
+    target_lang     TEXT NOT NULL,
+    translated_text TEXT NOT NULL,
+    expires_at      TIMESTAMPTZ
+);
+
+-- InvalidatePair drops every entry of a language pair.
+CREATE INDEX IF NOT EXISTS translation_cache_pair_idx ON translation_cache (source_lang, target_lang);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS glossary_entries_term_idx;
+
+DROP TABLE IF EXISTS glossary_entries;
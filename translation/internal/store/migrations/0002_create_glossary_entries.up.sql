@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS glossary_entries (
+    entry_id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    scope            TEXT NOT NULL,
+    owner_id         TEXT NOT NULL,
+    term             TEXT NOT NULL,
+    source_lang      TEXT NOT NULL,
+    target_lang      TEXT NOT NULL,
+    rendering        TEXT NOT NULL DEFAULT '',
+    do_not_translate BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- A term has at most one rendering per owner and language pair.
+CREATE UNIQUE INDEX IF NOT EXISTS glossary_entries_term_idx
+    ON glossary_entries (scope, owner_id, lower(term), source_lang, target_lang);
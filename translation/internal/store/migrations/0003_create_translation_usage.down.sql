@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS translation_usage_chat_idx;
+DROP INDEX IF EXISTS translation_usage_username_idx;
+
+DROP TABLE IF EXISTS translation_usage;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS translation_usage (
+    usage_id          BIGSERIAL PRIMARY KEY,
+    username          TEXT NOT NULL,
+    chat_id           TEXT NOT NULL DEFAULT '',
+    source_lang       TEXT NOT NULL,
+    target_lang       TEXT NOT NULL,
+    provider          TEXT NOT NULL,
+    model             TEXT NOT NULL DEFAULT '',
+    prompt_tokens     BIGINT NOT NULL,
+    completion_tokens BIGINT NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Quota checks add up a user's usage since the start of the day or month.
+CREATE INDEX IF NOT EXISTS translation_usage_username_idx
+    ON translation_usage (username, created_at);
+
+CREATE INDEX IF NOT EXISTS translation_usage_chat_idx
+    ON translation_usage (chat_id, created_at);
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UsageStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewUsageStore(pool *pgxpool.Pool) *UsageStore {
+	return &UsageStore{pool: pool}
+}
+
+func (s *UsageStore) RecordUsage(ctx context.Context, record *models.UsageRecord) error {
+	query := `
+		INSERT INTO translation_usage (username, chat_id, source_lang, target_lang, provider, model, prompt_tokens, completion_tokens, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := s.pool.Exec(ctx, query,
+		record.Username,
+		record.ChatID,
+		record.SourceLang,
+		record.TargetLang,
+		record.Provider,
+		record.Model,
+		record.PromptTokens,
+		record.CompletionTokens,
+		record.CreatedAt,
+	)
+	return err
+}
+
+func (s *UsageStore) TotalUsage(ctx context.Context, username string, since time.Time) (models.Usage, error) {
+	query := `
+		SELECT COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0)
+		FROM translation_usage
+		WHERE username = $1 AND created_at >= $2
+	`
+	var usage models.Usage
+	err := s.pool.QueryRow(ctx, query, username, since).Scan(&usage.PromptTokens, &usage.CompletionTokens)
+	return usage, err
+}
+
+func (s *UsageStore) SummarizeUsage(ctx context.Context, filter models.UsageFilter) ([]*models.UsageSummary, error) {
+	until := filter.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	query := `
+		SELECT username, chat_id, source_lang, target_lang, provider, model,
+		       SUM(prompt_tokens), SUM(completion_tokens), COUNT(*)
+		FROM translation_usage
+		WHERE ($1 = '' OR username = $1)
+		  AND ($2 = '' OR chat_id = $2)
+		  AND created_at >= $3 AND created_at < $4
+		GROUP BY username, chat_id, source_lang, target_lang, provider, model
+		ORDER BY username, chat_id, source_lang, target_lang, provider, model
+	`
+	rows, err := s.pool.Query(ctx, query, filter.Username, filter.ChatID, filter.Since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*models.UsageSummary
+	for rows.Next() {
+		var summary models.UsageSummary
+		err = rows.Scan(
+			&summary.Username,
+			&summary.ChatID,
+			&summary.SourceLang,
+			&summary.TargetLang,
+			&summary.Provider,
+			&summary.Model,
+			&summary.PromptTokens,
+			&summary.CompletionTokens,
+			&summary.Requests,
+		)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, &summary)
+	}
+	return summaries, rows.Err()
+}
@@ -0,0 +1,91 @@
+package translator
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker stops calls to a provider after Failures consecutive
+// failures. Once Cooldown has passed, a single probe call is let through: its
+// success closes the circuit, its failure opens it again.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu         sync.Mutex
+	failures   int
+	openUntil  time.Time
+	probing    bool
+	probeSince time.Time
+	now        func() time.Time
+}
+
+// NewCircuitBreaker returns nil, which never opens, for a zero threshold.
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may go out and, if not, how long the circuit
+// stays open.
+func (b *CircuitBreaker) Allow() (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.failures < b.threshold {
+		return 0, true
+	}
+	if wait := b.openUntil.Sub(now); wait > 0 {
+		return wait, false
+	}
+	// A probe that never reported back, e.g. after a panic, does not keep
+	// the circuit shut forever.
+	if b.probing && now.Sub(b.probeSince) < b.cooldown {
+		return b.cooldown - now.Sub(b.probeSince), false
+	}
+	b.probing, b.probeSince = true, now
+	return 0, true
+}
+
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures >= b.threshold {
+		log.Printf("Provider %s recovered, closing its circuit", b.name)
+	}
+	b.failures, b.probing = 0, false
+}
+
+// Failure counts a failed call. A Retry-After longer than the cooldown keeps
+// the circuit open for that long instead.
+func (b *CircuitBreaker) Failure(retryAfter time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures < b.threshold {
+		return
+	}
+
+	open := max(b.cooldown, retryAfter)
+	b.openUntil = b.now().Add(open)
+	if b.failures == b.threshold {
+		log.Printf("Provider %s failed %d times in a row, opening its circuit for %s", b.name, b.failures, open)
+	}
+}
@@ -0,0 +1,115 @@
+package translator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = time.Minute
+
+	type step struct {
+		advance time.Duration
+		// report is "success", "failure" or empty to only ask Allow.
+		report   string
+		wantOK   bool
+		wantWait time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after the threshold",
+			steps: []step{
+				{report: "failure", wantOK: true},
+				{report: "failure", wantOK: false, wantWait: cooldown},
+				{advance: 20 * time.Second, wantOK: false, wantWait: 40 * time.Second},
+			},
+		},
+		{
+			name: "a success resets the count",
+			steps: []step{
+				{report: "failure", wantOK: true},
+				{report: "success", wantOK: true},
+				{report: "failure", wantOK: true},
+			},
+		},
+		{
+			name: "a probe's success closes the circuit",
+			steps: []step{
+				{report: "failure"},
+				{report: "failure", wantWait: cooldown},
+				{advance: cooldown, wantOK: true},
+				{wantOK: false, wantWait: cooldown},
+				{report: "success", wantOK: true},
+				{wantOK: true},
+			},
+		},
+		{
+			name: "a probe's failure opens it again",
+			steps: []step{
+				{report: "failure"},
+				{report: "failure", wantWait: cooldown},
+				{advance: cooldown, wantOK: true},
+				{report: "failure", wantOK: false, wantWait: cooldown},
+				{advance: cooldown, wantOK: true},
+			},
+		},
+		{
+			name: "a probe that never reports back expires",
+			steps: []step{
+				{report: "failure"},
+				{report: "failure", wantWait: cooldown},
+				{advance: cooldown, wantOK: true},
+				{advance: cooldown / 2, wantOK: false, wantWait: cooldown / 2},
+				{advance: cooldown / 2, wantOK: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			b := NewCircuitBreaker("test", 2, cooldown)
+			b.now = clock.Now
+
+			for i, s := range tt.steps {
+				clock.Advance(s.advance)
+				switch s.report {
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure(0)
+				}
+				// Steps that report only check Allow when they expect something.
+				if s.report != "" && !s.wantOK && s.wantWait == 0 {
+					continue
+				}
+				wait, ok := b.Allow()
+				if ok != s.wantOK || wait != s.wantWait {
+					t.Fatalf("step %d: Allow = %s, %v, want %s, %v", i, wait, ok, s.wantWait, s.wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerRetryAfter(t *testing.T) {
+	clock := newFakeClock()
+	b := NewCircuitBreaker("test", 1, time.Second)
+	b.now = clock.Now
+
+	b.Failure(time.Minute)
+	if wait, ok := b.Allow(); ok || wait != time.Minute {
+		t.Errorf("Allow = %s, %v, want the Retry-After of 1m", wait, ok)
+	}
+}
+
+func TestNilCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker("test", 0, time.Minute)
+	b.Failure(time.Minute)
+	if _, ok := b.Allow(); !ok {
+		t.Error("a disabled breaker refused a call")
+	}
+}
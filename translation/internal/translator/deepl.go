@@ -0,0 +1,143 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+const defaultDeepLBaseURL = "https://api.deepl.com"
+
+// DeepLProvider calls the DeepL v2 REST API, or any service exposing the same
+// /v2/translate contract.
+type DeepLProvider struct {
+	baseURL string
+	apiKey  string
+	guard   *guard
+}
+
+func NewDeepLProvider(cfg ProviderConfig) (*DeepLProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("deepl provider needs an API key")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultDeepLBaseURL
+	}
+
+	return &DeepLProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  cfg.APIKey,
+		guard:   newGuard(cfg),
+	}, nil
+}
+
+type deepLRequest struct {
+	Text        []string `json:"text"`
+	SourceLang  string   `json:"source_lang,omitempty"`
+	TargetLang  string   `json:"target_lang"`
+	Context     string   `json:"context,omitempty"`
+	Formality   string   `json:"formality,omitempty"`
+	TagHandling string   `json:"tag_handling,omitempty"`
+	IgnoreTags  []string `json:"ignore_tags,omitempty"`
+}
+
+// deepLFormality maps styles onto DeepL's formality setting. The "prefer_"
+// values fall back to the default for languages without formality. DeepL
+// has no literal mode, so that style translates as usual.
+var deepLFormality = map[models.TranslationStyle]string{
+	models.StyleFormal: "prefer_more",
+	models.StyleCasual: "prefer_less",
+}
+
+// keepTag wraps glossary renderings in XML requests; DeepL leaves the content
+// of ignored tags untouched.
+const keepTag = "keep"
+
+type deepLResponse struct {
+	Translations []struct {
+		Text string `json:"text"`
+	} `json:"translations"`
+}
+
+func (p *DeepLProvider) TranslateText(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error) {
+	translated, err := p.TranslateBatch(ctx, []string{text}, sourceLanguage, targetLanguage)
+	if err != nil {
+		return "", err
+	}
+	return translated[0], nil
+}
+
+// TranslateWithOptions uses DeepL's context parameter, which influences the
+// translation without being translated or billed. Glossary terms are replaced
+// by their renderings up front and sent as ignored XML tags.
+func (p *DeepLProvider) TranslateWithOptions(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) (string, error) {
+	lines := make([]string, len(opts.Context))
+	for i, msg := range opts.Context {
+		lines[i] = msg.Content
+	}
+
+	req := deepLRequest{
+		Text:       []string{text},
+		SourceLang: strings.ToUpper(sourceLanguage),
+		TargetLang: strings.ToUpper(targetLanguage),
+		Context:    strings.Join(lines, "\n"),
+		Formality:  deepLFormality[opts.Style],
+	}
+	if len(opts.Glossary) > 0 {
+		req.Text[0] = markTerms(text, opts.Glossary, func(rendering string) string {
+			return "<" + keepTag + ">" + html.EscapeString(rendering) + "</" + keepTag + ">"
+		}, html.EscapeString)
+		req.TagHandling = "xml"
+		req.IgnoreTags = []string{keepTag}
+	}
+
+	translated, err := p.send(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if req.TagHandling == "" {
+		return translated[0], nil
+	}
+
+	untagged := strings.NewReplacer("<"+keepTag+">", "", "</"+keepTag+">", "").Replace(translated[0])
+	return html.UnescapeString(untagged), nil
+}
+
+func (p *DeepLProvider) TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]string, error) {
+	return p.send(ctx, deepLRequest{
+		Text:       texts,
+		SourceLang: strings.ToUpper(sourceLanguage),
+		TargetLang: strings.ToUpper(targetLanguage),
+	})
+}
+
+func (p *DeepLProvider) send(ctx context.Context, req deepLRequest) ([]string, error) {
+	var resp deepLResponse
+	headers := map[string]string{"Authorization": "DeepL-Auth-Key " + p.apiKey}
+	err := p.guard.do(ctx, 0, func(ctx context.Context) error {
+		return postJSON(ctx, p.baseURL+"/v2/translate", headers, req, &resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("API error: %w", err)
+	}
+
+	if len(resp.Translations) != len(req.Text) {
+		return nil, fmt.Errorf("got %d of %d translations", len(resp.Translations), len(req.Text))
+	}
+
+	translated := make([]string, len(req.Text))
+	for i, t := range resp.Translations {
+		translated[i] = t.Text
+	}
+	return translated, nil
+}
+
+func (p *DeepLProvider) TranslateStream(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (string, error) {
+	return streamWhole(ctx, p, text, opts, sourceLanguage, targetLanguage, onDelta)
+}
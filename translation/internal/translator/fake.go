@@ -0,0 +1,39 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// FakeProvider translates deterministically without leaving the process,
+// which keeps local runs and tests free of API keys.
+type FakeProvider struct{}
+
+func NewFakeProvider(ProviderConfig) (*FakeProvider, error) {
+	return &FakeProvider{}, nil
+}
+
+func (p *FakeProvider) TranslateText(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error) {
+	return fmt.Sprintf("[%s->%s] %s", sourceLanguage, targetLanguage, text), nil
+}
+
+func (p *FakeProvider) TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]string, error) {
+	return translateEach(ctx, p, texts, sourceLanguage, targetLanguage)
+}
+
+// TranslateWithOptions swaps glossary terms for their renderings, so the
+// glossary behaves as with a real provider, and names the style in the tag.
+func (p *FakeProvider) TranslateWithOptions(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) (string, error) {
+	keep := func(s string) string { return s }
+	text = markTerms(text, opts.Glossary, keep, keep)
+	if opts.Style != models.StyleDefault {
+		return fmt.Sprintf("[%s->%s %s] %s", sourceLanguage, targetLanguage, opts.Style, text), nil
+	}
+	return p.TranslateText(ctx, text, sourceLanguage, targetLanguage)
+}
+
+func (p *FakeProvider) TranslateStream(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (string, error) {
+	return streamWhole(ctx, p, text, opts, sourceLanguage, targetLanguage, onDelta)
+}
@@ -0,0 +1,47 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// markTerms replaces every glossary term in text with mark(expected
+// rendering) and passes the text in between through escape. Providers without
+// a prompt use it to hand the renderings over as untranslatable markup.
+func markTerms(text string, glossary models.Glossary, mark, escape func(string) string) string {
+	var b strings.Builder
+	for text != "" {
+		first, firstEnd, expected := -1, -1, ""
+		for _, term := range glossary {
+			start, end := models.TermIndex(text, term.Term)
+			if start >= 0 && (first < 0 || start < first || start == first && end > firstEnd) {
+				first, firstEnd, expected = start, end, term.Expected()
+			}
+		}
+		if first < 0 {
+			b.WriteString(escape(text))
+			break
+		}
+
+		b.WriteString(escape(text[:first]))
+		b.WriteString(mark(expected))
+		text = text[firstEnd:]
+	}
+	return b.String()
+}
+
+// glossaryPrompt lists the glossary as rules for an LLM.
+func glossaryPrompt(glossary models.Glossary) string {
+	var b strings.Builder
+	b.WriteString("Glossary (mandatory):\n")
+	for _, term := range glossary {
+		if term.DoNotTranslate || term.Rendering == "" {
+			fmt.Fprintf(&b, "- %q: do not translate, keep it exactly as written\n", term.Term)
+		} else {
+			fmt.Fprintf(&b, "- %q: always translate as %q\n", term.Term, term.Rendering)
+		}
+	}
+	return b.String()
+}
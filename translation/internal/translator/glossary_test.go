@@ -0,0 +1,39 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+func TestMarkTerms(t *testing.T) {
+	glossary := models.Glossary{
+		{Term: "chat", Rendering: "Chat"},
+		{Term: "chat room", Rendering: "Chatraum"},
+		{Term: "Go", DoNotTranslate: true},
+	}
+	mark := func(rendering string) string { return "<" + rendering + ">" }
+
+	tests := []struct {
+		name     string
+		text     string
+		glossary models.Glossary
+		want     string
+	}{
+		{name: "empty", text: "", glossary: glossary, want: ""},
+		{name: "no glossary", text: "hello", want: "HELLO"},
+		{name: "no terms", text: "hello", glossary: glossary, want: "HELLO"},
+		{name: "longest term wins", text: "join the chat room now", glossary: glossary, want: "JOIN THE <Chatraum> NOW"},
+		{name: "every occurrence", text: "chat and chat room", glossary: glossary, want: "<Chat> AND <Chatraum>"},
+		{name: "whole words only", text: "Go gophers go", glossary: glossary, want: "<Go> GOPHERS <Go>"},
+		{name: "multibyte text", text: "über chat", glossary: glossary, want: "ÜBER <Chat>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markTerms(tt.text, tt.glossary, mark, strings.ToUpper); got != tt.want {
+				t.Errorf("markTerms = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	maxAttempts = 3
+	// maxRetryWait is the longest a call sleeps before retrying. Providers
+	// asking for more are reported unavailable, so the caller can pause or
+	// fall back instead of holding a worker.
+	maxRetryWait = 10 * time.Second
+)
+
+// guard runs the calls to one provider account: it waits for the rate
+// limiter, fails fast while the circuit is open, applies the per-call
+// timeout within the caller's deadline and retries rate limits and server
+// errors with back-off.
+type guard struct {
+	provider string
+	limiter  *RateLimiter
+	breaker  *CircuitBreaker
+	timeout  time.Duration
+}
+
+func newGuard(cfg ProviderConfig) *guard {
+	return &guard{
+		provider: cfg.Name,
+		limiter:  NewRateLimiter(cfg.RequestsPerMinute, cfg.TokensPerMinute),
+		breaker:  NewCircuitBreaker(cfg.Name, cfg.BreakerFailures, cfg.BreakerCooldown),
+		timeout:  cfg.Timeout,
+	}
+}
+
+// finalError marks a failure that must not be retried, e.g. once a stream
+// handed out deltas. Only a fault of the provider counts for the breaker,
+// not one of whoever received the deltas.
+type finalError struct {
+	err   error
+	fault bool
+}
+
+func (e finalError) Error() string { return e.err.Error() }
+func (e finalError) Unwrap() error { return e.err }
+
+// do runs call, which is estimated to use tokens tokens, until it succeeds or
+// runs out of attempts. Failed attempts give their estimate back to the
+// limiter; the caller corrects the estimate of the successful one.
+func (g *guard) do(ctx context.Context, tokens int, call func(ctx context.Context) error) error {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		if wait, ok := g.breaker.Allow(); !ok {
+			return &models.UnavailableError{Provider: g.provider, RetryAfter: wait, Err: errors.New("circuit open")}
+		}
+		if wait := g.limiter.Paused(); wait > maxRetryWait {
+			return &models.UnavailableError{Provider: g.provider, RetryAfter: wait, Err: errors.New("rate limited")}
+		}
+		if err := g.limiter.Wait(ctx, tokens); err != nil {
+			return err
+		}
+
+		retryAfter, err := g.attempt(ctx, call)
+		if err == nil {
+			g.breaker.Success()
+			return nil
+		}
+		g.limiter.Correct(tokens, 0)
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider.
+			return err
+		}
+		var final finalError
+		if errors.As(err, &final) {
+			if final.fault {
+				g.breaker.Failure(retryAfter)
+			}
+			return final.err
+		}
+
+		if status := statusOf(err); !retryable(status, err) {
+			if status != 0 {
+				// The provider is up, it just refused this request.
+				g.breaker.Success()
+			}
+			return err
+		}
+
+		g.breaker.Failure(retryAfter)
+		if retryAfter > 0 {
+			g.limiter.Pause(retryAfter)
+		}
+
+		wait := delay
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		deadline, hasDeadline := ctx.Deadline()
+		if wait > maxRetryWait || hasDeadline && time.Until(deadline) < wait {
+			return &models.UnavailableError{Provider: g.provider, RetryAfter: wait, Err: err}
+		}
+		if attempt == maxAttempts {
+			return err
+		}
+
+		log.Printf("Provider %s failed (attempt %d/%d), retrying in %s: %v", g.provider, attempt, maxAttempts, wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// attempt makes one call and returns the Retry-After of a failed one.
+func (g *guard) attempt(ctx context.Context, call func(ctx context.Context) error) (time.Duration, error) {
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	hint := &retryAfterHint{}
+	err := call(context.WithValue(ctx, retryAfterKey{}, hint))
+	return hint.wait, err
+}
+
+// retryable tells server-side trouble, which may pass, from requests the
+// provider will never accept.
+func retryable(status int, err error) bool {
+	switch {
+	case status == http.StatusTooManyRequests, status == http.StatusRequestTimeout, status >= 500:
+		return true
+	case status != 0:
+		return false
+	default:
+		// No answer at all: a timeout or a network error.
+		return !errors.Is(err, context.Canceled)
+	}
+}
+
+// httpStatusError is a non-200 answer of a plain HTTP provider.
+type httpStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+// statusOf extracts the HTTP status of a failed call, 0 if there was no
+// answer. OpenAI errors carry it typed.
+func statusOf(err error) int {
+	var (
+		apiErr     *openai.APIError
+		requestErr *openai.RequestError
+		statusErr  *httpStatusError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		return requestErr.HTTPStatusCode
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode
+	default:
+		return 0
+	}
+}
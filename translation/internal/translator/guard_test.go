@@ -0,0 +1,149 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "rate limited", err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "server error", err: &openai.RequestError{HTTPStatusCode: http.StatusBadGateway}, want: true},
+		{name: "request timeout", err: &httpStatusError{StatusCode: http.StatusRequestTimeout}, want: true},
+		{name: "bad request", err: &openai.APIError{HTTPStatusCode: http.StatusBadRequest}},
+		{name: "unauthorized", err: fmt.Errorf("API error: %w", &httpStatusError{StatusCode: http.StatusUnauthorized})},
+		{name: "network", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "deadline", err: context.DeadlineExceeded, want: true},
+		{name: "canceled", err: fmt.Errorf("stream: %w", context.Canceled)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(statusOf(tt.err), tt.err); got != tt.want {
+				t.Errorf("retryable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "none", header: http.Header{}},
+		{name: "milliseconds win", header: http.Header{"Retry-After-Ms": {"1500"}, "Retry-After": {"10"}}, want: 1500 * time.Millisecond},
+		{name: "seconds", header: http.Header{"Retry-After": {"2.5"}}, want: 2500 * time.Millisecond},
+		{name: "date", header: http.Header{"Retry-After": {now.Add(30 * time.Second).Format(http.TimeFormat)}}, want: 30 * time.Second},
+		{name: "past date", header: http.Header{"Retry-After": {now.Add(-time.Minute).Format(http.TimeFormat)}}},
+		{name: "garbage", header: http.Header{"Retry-After": {"soon"}, "Retry-After-Ms": {"-1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("parseRetryAfter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardDo(t *testing.T) {
+	refused := &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "refused"}
+	delivered := errors.New("client went away")
+	broken := errors.New("stream broke")
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantErr      error
+		wantCalls    int
+		wantFailures int
+	}{
+		{name: "success", errs: []error{nil}, wantCalls: 1},
+		{name: "not retryable", errs: []error{refused}, wantErr: refused, wantCalls: 1},
+		{name: "final, not the provider's fault", errs: []error{finalError{err: delivered}}, wantErr: delivered, wantCalls: 1},
+		{name: "final, the provider's fault", errs: []error{finalError{err: broken, fault: true}}, wantErr: broken, wantCalls: 1, wantFailures: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGuard(ProviderConfig{Name: "test", BreakerFailures: 5, BreakerCooldown: time.Minute})
+
+			calls := 0
+			err := g.do(context.Background(), 0, func(context.Context) error {
+				err := tt.errs[min(calls, len(tt.errs)-1)]
+				calls++
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("do error = %v, want %v", err, tt.wantErr)
+			}
+			var final finalError
+			if errors.As(err, &final) {
+				t.Errorf("do leaked the finalError wrapper: %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if g.breaker.failures != tt.wantFailures {
+				t.Errorf("breaker failures = %d, want %d", g.breaker.failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestGuardReportsLongRetryAfterAsUnavailable(t *testing.T) {
+	g := newGuard(ProviderConfig{Name: "test"})
+
+	calls := 0
+	err := g.do(context.Background(), 0, func(ctx context.Context) error {
+		calls++
+		ctx.Value(retryAfterKey{}).(*retryAfterHint).wait = time.Minute
+		return &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}
+	})
+
+	var unavailable *models.UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.RetryAfter != time.Minute || calls != 1 {
+		t.Errorf("do = %v after %d calls, want unavailable for 1m after one call", err, calls)
+	}
+	if got := g.limiter.Paused(); got <= maxRetryWait {
+		t.Errorf("limiter paused for %s, want the Retry-After", got)
+	}
+}
+
+func TestGuardGivesBackFailedEstimates(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{name: "failed", err: &openai.APIError{HTTPStatusCode: http.StatusBadRequest}},
+		{name: "succeeded", want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			g := newGuard(ProviderConfig{Name: "test"})
+			g.limiter = newRateLimiter(0, 600, clock.Now)
+
+			_ = g.do(context.Background(), 600, func(context.Context) error { return tt.err })
+
+			if wait := g.limiter.reserve(600); wait != tt.want {
+				t.Errorf("next request waits %s, want %s", wait, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// httpClient has no timeout of its own; every call runs under the guard's
+// per-call timeout and the caller's deadline.
+var httpClient = &http.Client{}
+
+func postJSON(ctx context.Context, url string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := retryAfterDoer{httpClient}.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &httpStatusError{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(msg))}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type retryAfterKey struct{}
+
+// retryAfterHint receives the Retry-After of a failed call. The guard puts
+// one into the context of every attempt.
+type retryAfterHint struct {
+	wait time.Duration
+}
+
+// retryAfterDoer records the Retry-After of rate limited and unavailable
+// answers in the request's hint. The OpenAI client does not expose headers
+// of failed requests, so it sends through this doer as well.
+type retryAfterDoer struct {
+	client *http.Client
+}
+
+func (d retryAfterDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	if hint, ok := req.Context().Value(retryAfterKey{}).(*retryAfterHint); ok {
+		hint.wait = parseRetryAfter(resp.Header, time.Now())
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads OpenAI's retry-after-ms or the standard Retry-After,
+// given in seconds or as a date relative to now.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
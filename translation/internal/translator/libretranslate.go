@@ -0,0 +1,98 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// LibreTranslateProvider calls a self-hosted LibreTranslate server.
+type LibreTranslateProvider struct {
+	baseURL string
+	apiKey  string
+	guard   *guard
+}
+
+func NewLibreTranslateProvider(cfg ProviderConfig) (*LibreTranslateProvider, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("libretranslate provider needs a base URL")
+	}
+
+	return &LibreTranslateProvider{
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:  cfg.APIKey,
+		guard:   newGuard(cfg),
+	}, nil
+}
+
+type libreTranslateRequest struct {
+	Q      interface{} `json:"q"`
+	Source string      `json:"source"`
+	Target string      `json:"target"`
+	Format string      `json:"format"`
+	APIKey string      `json:"api_key,omitempty"`
+}
+
+type libreTranslateResponse struct {
+	TranslatedText string `json:"translatedText"`
+}
+
+func (p *LibreTranslateProvider) TranslateText(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error) {
+	req := libreTranslateRequest{
+		Q:      text,
+		Source: sourceLanguage,
+		Target: targetLanguage,
+		Format: "text",
+		APIKey: p.apiKey,
+	}
+
+	var resp libreTranslateResponse
+	err := p.guard.do(ctx, 0, func(ctx context.Context) error {
+		return postJSON(ctx, p.baseURL+"/translate", nil, req, &resp)
+	})
+	if err != nil {
+		return "", fmt.Errorf("API error: %w", err)
+	}
+
+	return resp.TranslatedText, nil
+}
+
+// TranslateBatch passes q as an array, which LibreTranslate answers with an
+// array of translations in the same order.
+func (p *LibreTranslateProvider) TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]string, error) {
+	req := libreTranslateRequest{
+		Q:      texts,
+		Source: sourceLanguage,
+		Target: targetLanguage,
+		Format: "text",
+		APIKey: p.apiKey,
+	}
+
+	var resp struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	err := p.guard.do(ctx, 0, func(ctx context.Context) error {
+		return postJSON(ctx, p.baseURL+"/translate", nil, req, &resp)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("API error: %w", err)
+	}
+
+	if len(resp.TranslatedText) != len(texts) {
+		return nil, fmt.Errorf("got %d of %d translations", len(resp.TranslatedText), len(texts))
+	}
+	return resp.TranslatedText, nil
+}
+
+// TranslateWithOptions drops the options: LibreTranslate has no way to use
+// context, a glossary or a style, so glossary violations show up in the post-check.
+func (p *LibreTranslateProvider) TranslateWithOptions(ctx context.Context, text string, _ models.TranslateOptions, sourceLanguage, targetLanguage string) (string, error) {
+	return p.TranslateText(ctx, text, sourceLanguage, targetLanguage)
+}
+
+func (p *LibreTranslateProvider) TranslateStream(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (string, error) {
+	return streamWhole(ctx, p, text, opts, sourceLanguage, targetLanguage, onDelta)
+}
@@ -0,0 +1,140 @@
+package translator
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter keeps a provider account within its requests and tokens per
+// minute. Both are token buckets that refill continuously and start full, so
+// a minute's budget may be spent at once.
+type RateLimiter struct {
+	mu          sync.Mutex
+	requests    *bucket
+	tokens      *bucket
+	pausedUntil time.Time
+	now         func() time.Time
+}
+
+type bucket struct {
+	capacity  float64
+	available float64
+	perSecond float64
+	updated   time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{
+		capacity:  float64(perMinute),
+		available: float64(perMinute),
+		perSecond: float64(perMinute) / 60,
+		updated:   now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.available = math.Min(b.capacity, b.available+now.Sub(b.updated).Seconds()*b.perSecond)
+	b.updated = now
+}
+
+// wait is how long until n can be taken.
+func (b *bucket) wait(n float64) time.Duration {
+	if b == nil || b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.perSecond * float64(time.Second))
+}
+
+// NewRateLimiter takes zero for a limit that is not enforced. Without either
+// limit, the limiter still honours pauses.
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return newRateLimiter(requestsPerMinute, tokensPerMinute, time.Now)
+}
+
+func newRateLimiter(requestsPerMinute, tokensPerMinute int, now func() time.Time) *RateLimiter {
+	return &RateLimiter{
+		requests: newBucket(requestsPerMinute, now()),
+		tokens:   newBucket(tokensPerMinute, now()),
+		now:      now,
+	}
+}
+
+// Wait blocks until a request of about tokens tokens fits the budget, or ctx
+// is done. Requests larger than a minute's budget wait for a full bucket.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	for {
+		wait := l.reserve(float64(tokens))
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *RateLimiter) reserve(tokens float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	wait := l.pausedUntil.Sub(now)
+	if l.requests != nil {
+		l.requests.refill(now)
+		wait = max(wait, l.requests.wait(1))
+	}
+	if l.tokens != nil {
+		l.tokens.refill(now)
+		tokens = math.Min(tokens, l.tokens.capacity)
+		wait = max(wait, l.tokens.wait(tokens))
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	if l.requests != nil {
+		l.requests.available--
+	}
+	if l.tokens != nil {
+		l.tokens.available -= tokens
+	}
+	return 0
+}
+
+// Correct settles the difference between the tokens a request was estimated
+// at and what the provider billed. The bucket may go into debt.
+func (l *RateLimiter) Correct(estimated, actual int) {
+	if l.tokens == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens.available -= float64(actual - estimated)
+}
+
+// Paused returns how long requests are still held back by Pause.
+func (l *RateLimiter) Paused() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return max(l.pausedUntil.Sub(l.now()), 0)
+}
+
+// Pause holds every request back for d, e.g. after the provider answered with
+// a Retry-After.
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := l.now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
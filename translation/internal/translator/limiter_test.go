@@ -0,0 +1,121 @@
+package translator
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	type step struct {
+		advance  time.Duration
+		correct  [2]int // estimated, actual
+		pause    time.Duration
+		tokens   float64
+		wantWait time.Duration
+	}
+
+	tests := []struct {
+		name              string
+		requestsPerMinute int
+		tokensPerMinute   int
+		steps             []step
+	}{
+		{
+			name:              "starts full, then refills continuously",
+			requestsPerMinute: 2,
+			steps: []step{
+				{wantWait: 0},
+				{wantWait: 0},
+				{wantWait: 30 * time.Second},
+				{advance: 15 * time.Second, wantWait: 15 * time.Second},
+				{advance: 15 * time.Second, wantWait: 0},
+			},
+		},
+		{
+			name:            "tokens",
+			tokensPerMinute: 600,
+			steps: []step{
+				{tokens: 500, wantWait: 0},
+				{tokens: 200, wantWait: 10 * time.Second},
+				{advance: 10 * time.Second, tokens: 200, wantWait: 0},
+			},
+		},
+		{
+			name:            "requests over the budget wait for a full bucket",
+			tokensPerMinute: 600,
+			steps: []step{
+				{tokens: 100, wantWait: 0},
+				{tokens: 5000, wantWait: 10 * time.Second},
+				{advance: 10 * time.Second, tokens: 5000, wantWait: 0},
+			},
+		},
+		{
+			name:            "corrections settle the estimate",
+			tokensPerMinute: 600,
+			steps: []step{
+				{tokens: 600, wantWait: 0},
+				{correct: [2]int{600, 0}, tokens: 600, wantWait: 0},
+				{correct: [2]int{0, 300}, tokens: 300, wantWait: time.Minute},
+			},
+		},
+		{
+			name: "pauses hold back unlimited requests",
+			steps: []step{
+				{pause: 5 * time.Second, wantWait: 5 * time.Second},
+				{advance: 5 * time.Second, wantWait: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			l := newRateLimiter(tt.requestsPerMinute, tt.tokensPerMinute, clock.Now)
+
+			for i, s := range tt.steps {
+				clock.Advance(s.advance)
+				if s.correct != [2]int{} {
+					l.Correct(s.correct[0], s.correct[1])
+				}
+				if s.pause > 0 {
+					l.Pause(s.pause)
+				}
+				if wait := l.reserve(s.tokens); wait != s.wantWait {
+					t.Fatalf("step %d: reserve(%v) waits %s, want %s", i, s.tokens, wait, s.wantWait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterPaused(t *testing.T) {
+	clock := newFakeClock()
+	l := newRateLimiter(0, 0, clock.Now)
+
+	l.Pause(10 * time.Second)
+	l.Pause(2 * time.Second)
+	if got := l.Paused(); got != 10*time.Second {
+		t.Errorf("Paused = %s, want the longer pause of 10s", got)
+	}
+
+	clock.Advance(time.Minute)
+	if got := l.Paused(); got != 0 {
+		t.Errorf("Paused = %s after the pause, want 0", got)
+	}
+}
@@ -0,0 +1,350 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/sashabaranov/go-openai"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// placeholderRule keeps the placeholders that stand in for protected spans.
+const placeholderRule = "Keep tokens such as ⟦0⟧ exactly as they are; they stand for links, mentions, code or emoji. "
+
+// styleRules tell the model which register to use; the default style adds
+// nothing.
+var styleRules = map[models.TranslationStyle]string{
+	models.StyleFormal:  "Use a formal, polite register, even if the original is casual. ",
+	models.StyleCasual:  "Use a casual, conversational register, as between friends. ",
+	models.StyleLiteral: "Translate as literally as the target language allows, keeping the original wording and sentence structure. ",
+}
+
+// OpenAIProvider talks to the OpenAI chat completions API or any server that
+// speaks it, such as Ollama or vLLM, when BaseURL is set.
+type OpenAIProvider struct {
+	client *openai.Client
+	model  string
+	guard  *guard
+	meter  *models.UsageMeter
+}
+
+func NewOpenAIProvider(cfg ProviderConfig) (*OpenAIProvider, error) {
+	if cfg.APIKey == "" && cfg.BaseURL == "" {
+		return nil, errors.New("openai provider needs an API key or a base URL")
+	}
+
+	clientCfg := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		clientCfg.BaseURL = cfg.BaseURL
+	}
+	clientCfg.HTTPClient = retryAfterDoer{httpClient}
+
+	model := cfg.Model
+	if model == "" {
+		model = openai.GPT4oMini
+	}
+
+	return &OpenAIProvider{
+		client: openai.NewClientWithConfig(clientCfg),
+		model:  model,
+		guard:  newGuard(cfg),
+	}, nil
+}
+
+func (p *OpenAIProvider) ModelName() string {
+	return p.model
+}
+
+// WithUsage returns a copy of the provider that adds the tokens of every
+// completion to meter. The copy shares the client.
+func (p *OpenAIProvider) WithUsage(meter *models.UsageMeter) models.TranslatorModel {
+	metered := *p
+	metered.meter = meter
+	return &metered
+}
+
+func usageOf(u openai.Usage) models.Usage {
+	return models.Usage{PromptTokens: int64(u.PromptTokens), CompletionTokens: int64(u.CompletionTokens)}
+}
+
+func (p *OpenAIProvider) TranslateText(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error) {
+	return p.complete(ctx, p.textRequest(text, sourceLanguage, targetLanguage))
+}
+
+func (p *OpenAIProvider) textRequest(text, sourceLanguage, targetLanguage string) openai.ChatCompletionRequest {
+	systemPrompt := fmt.Sprintf(
+		"You are an expert translation assistant specialized in casual chat communications. "+
+			"Translate the given text from %s to %s, preserving the original tone and cultural context. "+
+			placeholderRule+
+			"Provide only the translated text, without any additional commentary.",
+		sourceLanguage, targetLanguage,
+	)
+
+	userPrompt := fmt.Sprintf(
+		"Translate the following text from %s to %s:\n\n%s",
+		sourceLanguage, targetLanguage, text,
+	)
+
+	return openai.ChatCompletionRequest{
+		Model: p.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+		Temperature: 0.3,
+	}
+}
+
+// TranslateWithOptions shows the earlier messages to the model but asks it to
+// translate the new message only, and lists the glossary as mandatory rules.
+func (p *OpenAIProvider) TranslateWithOptions(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) (string, error) {
+	return p.complete(ctx, p.optionsRequest(text, opts, sourceLanguage, targetLanguage))
+}
+
+// TranslateStream forwards the content deltas of a streamed completion.
+func (p *OpenAIProvider) TranslateStream(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (string, error) {
+	return p.completeStream(ctx, p.optionsRequest(text, opts, sourceLanguage, targetLanguage), onDelta)
+}
+
+func (p *OpenAIProvider) optionsRequest(text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) openai.ChatCompletionRequest {
+	if opts.IsZero() {
+		return p.textRequest(text, sourceLanguage, targetLanguage)
+	}
+
+	systemPrompt := "You are an expert translation assistant specialized in casual chat communications. "
+	if len(opts.Context) > 0 {
+		systemPrompt += fmt.Sprintf(
+			"You get the latest messages of a conversation for context and a new message. "+
+				"Translate only the new message from %s to %s, using the context to resolve references, "+
+				"short replies and idioms, and preserving the original tone and cultural context. "+
+				"Never translate or repeat the context. ",
+			sourceLanguage, targetLanguage,
+		)
+	} else {
+		systemPrompt += fmt.Sprintf(
+			"Translate the given text from %s to %s, preserving the original tone and cultural context. ",
+			sourceLanguage, targetLanguage,
+		)
+	}
+	systemPrompt += styleRules[opts.Style]
+	if len(opts.Glossary) > 0 {
+		systemPrompt += "Follow the glossary exactly: its terms are names, products or jargon, " +
+			"and must appear in the translation as the glossary says, even where a translation would read more naturally. "
+	}
+	systemPrompt += placeholderRule + "Provide only the translated text, without any additional commentary."
+
+	var userPrompt strings.Builder
+	if len(opts.Context) > 0 {
+		userPrompt.WriteString("Conversation so far (context only):\n")
+		for _, msg := range opts.Context {
+			fmt.Fprintf(&userPrompt, "%s: %s\n", msg.Sender, msg.Content)
+		}
+		userPrompt.WriteString("\n")
+	}
+	if len(opts.Glossary) > 0 {
+		userPrompt.WriteString(glossaryPrompt(opts.Glossary))
+		userPrompt.WriteString("\n")
+	}
+	fmt.Fprintf(&userPrompt, "Translate the following new message from %s to %s:\n\n%s", sourceLanguage, targetLanguage, text)
+
+	return openai.ChatCompletionRequest{
+		Model: p.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: userPrompt.String()},
+		},
+		Temperature: 0.3,
+	}
+}
+
+// IdentifyLanguage asks the model for the ISO 639-1 code of text.
+func (p *OpenAIProvider) IdentifyLanguage(ctx context.Context, text string) (string, error) {
+	return p.complete(ctx, openai.ChatCompletionRequest{
+		Model: p.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: "Identify the language of the user's message. Reply with its ISO 639-1 code only, e.g. \"en\"."},
+			{Role: "user", Content: text},
+		},
+		Temperature: 0,
+	})
+}
+
+type batchItem struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type batchPayload struct {
+	Translations []batchItem `json:"translations"`
+}
+
+// TranslateBatch sends all texts in one structured request and matches the
+// answers back by ID. If the model returns something that does not line up,
+// the texts are translated one by one instead.
+func (p *OpenAIProvider) TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]string, error) {
+	if len(texts) == 1 {
+		translated, err := p.TranslateText(ctx, texts[0], sourceLanguage, targetLanguage)
+		if err != nil {
+			return nil, err
+		}
+		return []string{translated}, nil
+	}
+
+	items := make([]batchItem, len(texts))
+	for i, text := range texts {
+		items[i] = batchItem{ID: strconv.Itoa(i), Text: text}
+	}
+	input, err := json.Marshal(batchPayload{Translations: items})
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt := fmt.Sprintf(
+		"You are an expert translation assistant specialized in casual chat communications. "+
+			"Translate every item from %s to %s, preserving the original tone and cultural context. "+
+			placeholderRule+
+			"The input is a JSON object with a \"translations\" array of {\"id\", \"text\"} items. "+
+			"Reply with a JSON object of the same shape, keeping every id and replacing each text with its translation.",
+		sourceLanguage, targetLanguage,
+	)
+
+	content, err := p.complete(ctx, openai.ChatCompletionRequest{
+		Model: p.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: string(input)},
+		},
+		Temperature:    0.3,
+		ResponseFormat: &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	translated, err := splitBatch(content, len(texts))
+	if err != nil {
+		log.Printf("Batch of %d translations was malformed, translating one by one: %v", len(texts), err)
+		return translateEach(ctx, p, texts, sourceLanguage, targetLanguage)
+	}
+	return translated, nil
+}
+
+func splitBatch(content string, n int) ([]string, error) {
+	var payload batchPayload
+	if err := json.Unmarshal([]byte(content), &payload); err != nil {
+		return nil, err
+	}
+
+	translated := make([]string, n)
+	// An empty translation is a valid answer, so duplicates are tracked
+	// separately from the text.
+	filled := make([]bool, n)
+	seen := 0
+	for _, item := range payload.Translations {
+		i, err := strconv.Atoi(item.ID)
+		if err != nil || i < 0 || i >= n || filled[i] {
+			return nil, fmt.Errorf("unexpected item id %q", item.ID)
+		}
+		translated[i] = item.Text
+		filled[i] = true
+		seen++
+	}
+	if seen != n {
+		return nil, fmt.Errorf("got %d of %d translations", seen, n)
+	}
+	return translated, nil
+}
+
+// estimateTokens guesses what a request costs before it is sent, at about
+// four bytes per token and an answer as long as the prompt. The limiter is
+// corrected with the real usage afterwards.
+func estimateTokens(req openai.ChatCompletionRequest) int {
+	n := 0
+	for _, msg := range req.Messages {
+		n += len(msg.Content)
+	}
+	return n / 2
+}
+
+func (p *OpenAIProvider) complete(ctx context.Context, req openai.ChatCompletionRequest) (string, error) {
+	estimate := estimateTokens(req)
+
+	var resp openai.ChatCompletionResponse
+	err := p.guard.do(ctx, estimate, func(ctx context.Context) error {
+		var err error
+		resp, err = p.client.CreateChatCompletion(ctx, req)
+		return err
+	})
+	if err != nil {
+		// The guard already gave back the estimate of every failed attempt.
+		return "", fmt.Errorf("API error: %w", err)
+	}
+	p.meter.Add(usageOf(resp.Usage))
+	p.guard.limiter.Correct(estimate, resp.Usage.TotalTokens)
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no translation received")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+// completeStream retries only until the first delta went out; after that a
+// retry would start the translation over.
+func (p *OpenAIProvider) completeStream(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(string) error) (string, error) {
+	req.Stream = true
+	// The usage only comes with the last chunk, and only when asked for.
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	estimate := estimateTokens(req)
+
+	var translated strings.Builder
+	used := 0
+	err := p.guard.do(ctx, estimate, func(ctx context.Context) error {
+		stream, err := p.client.CreateChatCompletionStream(ctx, req)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				err = fmt.Errorf("stream error: %w", err)
+				if translated.Len() > 0 {
+					return finalError{err: err, fault: true}
+				}
+				return err
+			}
+			if resp.Usage != nil {
+				p.meter.Add(usageOf(*resp.Usage))
+				used += resp.Usage.TotalTokens
+			}
+			if len(resp.Choices) == 0 || resp.Choices[0].Delta.Content == "" {
+				continue
+			}
+
+			delta := resp.Choices[0].Delta.Content
+			translated.WriteString(delta)
+			if err = onDelta(delta); err != nil {
+				return finalError{err: err}
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	p.guard.limiter.Correct(estimate, used)
+
+	if translated.Len() == 0 {
+		return "", fmt.Errorf("no translation received")
+	}
+	return translated.String(), nil
+}
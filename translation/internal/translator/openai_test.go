@@ -0,0 +1,88 @@
+package translator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "in order",
+			content: `{"translations":[{"id":"0","text":"hallo"},{"id":"1","text":"welt"}]}`,
+			n:       2,
+			want:    []string{"hallo", "welt"},
+		},
+		{
+			name:    "out of order",
+			content: `{"translations":[{"id":"1","text":"welt"},{"id":"0","text":"hallo"}]}`,
+			n:       2,
+			want:    []string{"hallo", "welt"},
+		},
+		{
+			name:    "empty translation",
+			content: `{"translations":[{"id":"0","text":""},{"id":"1","text":"welt"}]}`,
+			n:       2,
+			want:    []string{"", "welt"},
+		},
+		{
+			name:    "duplicate id",
+			content: `{"translations":[{"id":"0","text":"hallo"},{"id":"0","text":"welt"}]}`,
+			n:       2,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate of empty translation",
+			content: `{"translations":[{"id":"0","text":""},{"id":"0","text":"hallo"}]}`,
+			n:       2,
+			wantErr: true,
+		},
+		{
+			name:    "missing item",
+			content: `{"translations":[{"id":"0","text":"hallo"}]}`,
+			n:       2,
+			wantErr: true,
+		},
+		{
+			name:    "id out of range",
+			content: `{"translations":[{"id":"0","text":"hallo"},{"id":"2","text":"welt"}]}`,
+			n:       2,
+			wantErr: true,
+		},
+		{
+			name:    "negative id",
+			content: `{"translations":[{"id":"-1","text":"hallo"}]}`,
+			n:       1,
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric id",
+			content: `{"translations":[{"id":"a","text":"hallo"}]}`,
+			n:       1,
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			content: "hallo\nwelt",
+			n:       2,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitBatch(tt.content, tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitBatch = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+// ProviderConfig configures one named provider. The limits are those of the
+// provider account and zero means unlimited; a zero BreakerFailures never
+// opens the circuit.
+type ProviderConfig struct {
+	Name    string
+	APIKey  string
+	BaseURL string
+	Model   string
+
+	RequestsPerMinute int
+	TokensPerMinute   int
+	Timeout           time.Duration
+	BreakerFailures   int
+	BreakerCooldown   time.Duration
+}
+
+type Factory func(cfg ProviderConfig) (models.TranslatorModel, error)
+
+// factories maps a provider kind to its constructor. Several named providers
+// may share a kind, e.g. OpenAI itself and a local Ollama server.
+var factories = map[string]Factory{
+	"openai": func(cfg ProviderConfig) (models.TranslatorModel, error) {
+		return NewOpenAIProvider(cfg)
+	},
+	"deepl": func(cfg ProviderConfig) (models.TranslatorModel, error) {
+		return NewDeepLProvider(cfg)
+	},
+	"libretranslate": func(cfg ProviderConfig) (models.TranslatorModel, error) {
+		return NewLibreTranslateProvider(cfg)
+	},
+	"fake": func(cfg ProviderConfig) (models.TranslatorModel, error) {
+		return NewFakeProvider(cfg)
+	},
+}
+
+func Kinds() []string {
+	kinds := make([]string, 0, len(factories))
+	for kind := range factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func NewProvider(kind string, cfg ProviderConfig) (models.TranslatorModel, error) {
+	factory, ok := factories[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown translation provider kind %q, expected one of %v", kind, Kinds())
+	}
+	return factory(cfg)
+}
+
+// translateEach is the batch fallback for providers without a batch endpoint.
+func translateEach(ctx context.Context, provider models.TranslatorModel, texts []string, sourceLanguage, targetLanguage string) ([]string, error) {
+	translated := make([]string, len(texts))
+	for i, text := range texts {
+		var err error
+		if translated[i], err = provider.TranslateText(ctx, text, sourceLanguage, targetLanguage); err != nil {
+			return nil, err
+		}
+	}
+	return translated, nil
+}
+
+// streamWhole serves TranslateStream for providers that cannot stream: the
+// whole translation arrives as one delta.
+func streamWhole(ctx context.Context, provider models.TranslatorModel, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (string, error) {
+	translated, err := provider.TranslateWithOptions(ctx, text, opts, sourceLanguage, targetLanguage)
+	if err != nil {
+		return "", err
+	}
+	if err = onDelta(translated); err != nil {
+		return "", err
+	}
+	return translated, nil
+}
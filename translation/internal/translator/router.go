@@ -0,0 +1,532 @@
+package translator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/cache"
+	"github.com/HJyup/translatify-translation/internal/models"
+)
+
+const anyLanguage = "*"
+
+// PromptVersion is part of every cache key. Bump it whenever the prompts sent
+// to LLM providers change, so old translations are not served anymore.
+const PromptVersion = "4"
+
+// firstGetter is implemented by caches that count lookups, so the keys of
+// one request count as a single lookup.
+type firstGetter interface {
+	GetFirst(ctx context.Context, keys []models.CacheKey, accept func(value string) bool) (int, string, error)
+}
+
+// modelNamer is implemented by providers that can serve several models.
+type modelNamer interface {
+	ModelName() string
+}
+
+// usageMetered is implemented by providers that bill per token and report
+// what each call used.
+type usageMetered interface {
+	WithUsage(meter *models.UsageMeter) models.TranslatorModel
+}
+
+// Route sends a language pair to a provider, optionally falling back to a
+// second one. Either language may be "*" to match every language.
+type Route struct {
+	SourceLang string
+	TargetLang string
+	Primary    string
+	Fallback   string
+
+	// override is the provider that replaced the route's for one request;
+	// the cache of the route's providers still counts.
+	override string
+}
+
+func (r Route) matches(sourceLanguage, targetLanguage string) bool {
+	return (r.SourceLang == anyLanguage || strings.EqualFold(r.SourceLang, sourceLanguage)) &&
+		(r.TargetLang == anyLanguage || strings.EqualFold(r.TargetLang, targetLanguage))
+}
+
+// ParseRoutes reads rules such as "en:ja=deepl,openai;*:fr=libretranslate",
+// where the provider after the comma is the fallback.
+func ParseRoutes(spec string) ([]Route, error) {
+	var routes []Route
+	for _, rule := range strings.Split(spec, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		pair, providers, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route %q, expected source:target=provider[,fallback]", rule)
+		}
+		source, target, ok := strings.Cut(pair, ":")
+		if !ok || source == "" || target == "" {
+			return nil, fmt.Errorf("invalid language pair in route %q", rule)
+		}
+		primary, fallback, _ := strings.Cut(providers, ",")
+		if primary == "" {
+			return nil, fmt.Errorf("route %q has no provider", rule)
+		}
+
+		routes = append(routes, Route{
+			SourceLang: strings.TrimSpace(source),
+			TargetLang: strings.TrimSpace(target),
+			Primary:    strings.TrimSpace(primary),
+			Fallback:   strings.TrimSpace(fallback),
+		})
+	}
+	return routes, nil
+}
+
+// Validator rejects a provider's translation of text, e.g. one that lost a
+// placeholder, before it is cached.
+type Validator func(text, translated string) error
+
+// Router picks a provider per language pair: the first matching route wins and
+// pairs without a route use the default route. Results are cached under the
+// provider that produced them.
+type Router struct {
+	providers    map[string]models.TranslatorModel
+	routes       []Route
+	defaultRoute Route
+	cache        models.TranslationCache
+	cacheTTL     time.Duration
+	validate     Validator
+}
+
+// NewRouter takes a nil validate to accept every translation.
+func NewRouter(providers map[string]models.TranslatorModel, primary, fallback string, routes []Route, cache models.TranslationCache, cacheTTL time.Duration, validate Validator) (*Router, error) {
+	if validate == nil {
+		validate = func(string, string) error { return nil }
+	}
+	r := &Router{
+		providers:    providers,
+		routes:       routes,
+		defaultRoute: Route{SourceLang: anyLanguage, TargetLang: anyLanguage, Primary: primary, Fallback: fallback},
+		cache:        cache,
+		cacheTTL:     cacheTTL,
+		validate:     validate,
+	}
+
+	for _, route := range append([]Route{r.defaultRoute}, routes...) {
+		for _, name := range []string{route.Primary, route.Fallback} {
+			if name == "" {
+				continue
+			}
+			if _, ok := providers[name]; !ok {
+				return nil, fmt.Errorf("route %s:%s uses unconfigured provider %q", route.SourceLang, route.TargetLang, name)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (r *Router) Provider(name string) (models.TranslatorModel, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+func (r *Router) cacheKey(provider, text, optionsDigest, sourceLanguage, targetLanguage string) models.CacheKey {
+	key := models.CacheKey{
+		Provider:      provider,
+		PromptVersion: PromptVersion,
+		SourceLang:    sourceLanguage,
+		TargetLang:    targetLanguage,
+		Text:          text,
+		Options:       optionsDigest,
+	}
+	key.Model = r.modelOf(provider)
+	return key
+}
+
+func (r *Router) modelOf(provider string) string {
+	if namer, ok := r.providers[provider].(modelNamer); ok {
+		return namer.ModelName()
+	}
+	return ""
+}
+
+func (r *Router) translation(provider, text string, usage models.Usage) *models.Translation {
+	return &models.Translation{Text: text, Provider: provider, Model: r.modelOf(provider), Usage: usage}
+}
+
+// metered returns the named provider reporting its usage to meter.
+func (r *Router) metered(name string, meter *models.UsageMeter) models.TranslatorModel {
+	provider := r.providers[name]
+	if m, ok := provider.(usageMetered); ok {
+		return m.WithUsage(meter)
+	}
+	return provider
+}
+
+// digestOptions condenses the conversation context and the glossary for the
+// cache key, so the same text translated with different options is cached
+// separately.
+func digestOptions(opts models.TranslateOptions) string {
+	if opts.IsZero() {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, msg := range opts.Context {
+		h.Write([]byte(msg.Sender))
+		h.Write([]byte{0})
+		h.Write([]byte(msg.Content))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{1})
+	for _, term := range opts.Glossary {
+		h.Write([]byte(term.Term))
+		h.Write([]byte{0})
+		h.Write([]byte(term.Expected()))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{1})
+	h.Write([]byte(opts.Style))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cached looks the text up under every provider of the route, so translations
+// produced by the fallback are reused as well. Cache failures and entries the
+// validator rejects count as misses.
+func (r *Router) cached(ctx context.Context, route Route, text, optionsDigest, sourceLanguage, targetLanguage string) (*models.Translation, bool) {
+	var (
+		providers []string
+		keys      []models.CacheKey
+	)
+	for _, provider := range []string{route.Primary, route.Fallback, route.override} {
+		if provider != "" {
+			providers = append(providers, provider)
+			keys = append(keys, r.cacheKey(provider, text, optionsDigest, sourceLanguage, targetLanguage))
+		}
+	}
+
+	accept := func(value string) bool { return r.validate(text, value) == nil }
+	var (
+		i     int
+		value string
+		err   error
+	)
+	if getter, ok := r.cache.(firstGetter); ok {
+		i, value, err = getter.GetFirst(ctx, keys, accept)
+	} else {
+		i, value, err = cache.First(ctx, r.cache, keys, accept)
+	}
+	if err != nil {
+		log.Printf("Failed to read translation cache: %v", err)
+	}
+	if i < 0 {
+		return nil, false
+	}
+	return r.translation(providers[i], value, models.Usage{}), true
+}
+
+func (r *Router) route(sourceLanguage, targetLanguage string) Route {
+	for _, route := range r.routes {
+		if route.matches(sourceLanguage, targetLanguage) {
+			return route
+		}
+	}
+	return r.defaultRoute
+}
+
+// routeFor is the route of the language pair, unless opts names a provider.
+// Translations then come from that provider alone, but what the route's
+// providers have cached is still served.
+func (r *Router) routeFor(opts models.TranslateOptions, sourceLanguage, targetLanguage string) (Route, error) {
+	route := r.route(sourceLanguage, targetLanguage)
+	if opts.Provider == "" {
+		return route, nil
+	}
+	if _, ok := r.providers[opts.Provider]; !ok {
+		return route, fmt.Errorf("%w: %q", models.ErrUnknownProvider, opts.Provider)
+	}
+	route.override = opts.Provider
+	return route, nil
+}
+
+// withFallback runs call against the route's primary provider and, if that
+// fails, against its fallback. It reports the provider that succeeded; meter
+// gets the usage of both attempts, since a failed one may be billed too.
+func withFallback[T any](r *Router, route Route, meter *models.UsageMeter, sourceLanguage, targetLanguage string, call func(models.TranslatorModel) (T, error)) (T, string, error) {
+	if route.override != "" {
+		route.Primary, route.Fallback = route.override, ""
+	}
+
+	result, err := attempt(r, route.Primary, meter, call)
+	if err == nil {
+		return result, route.Primary, nil
+	}
+	if route.Fallback == "" || route.Fallback == route.Primary {
+		return result, "", fmt.Errorf("%s: %w", route.Primary, err)
+	}
+
+	log.Printf("Provider %s failed for %s->%s, falling back to %s: %v", route.Primary, sourceLanguage, targetLanguage, route.Fallback, err)
+
+	result, fallbackErr := attempt(r, route.Fallback, meter, call)
+	if fallbackErr != nil {
+		return result, "", errors.Join(
+			fmt.Errorf("%s: %w", route.Primary, err),
+			fmt.Errorf("%s: %w", route.Fallback, fallbackErr),
+		)
+	}
+	return result, route.Fallback, nil
+}
+
+// attempt runs call against the named provider. A failed call that was billed
+// anyway returns a models.BilledError, so the usage can still be recorded.
+func attempt[T any](r *Router, name string, meter *models.UsageMeter, call func(models.TranslatorModel) (T, error)) (T, error) {
+	m := &models.UsageMeter{}
+	result, err := call(r.metered(name, m))
+	usage := m.Usage()
+	meter.Add(usage)
+	if err != nil && !usage.IsZero() {
+		err = &models.BilledError{Provider: name, Model: r.modelOf(name), Usage: usage, Err: err}
+	}
+	return result, err
+}
+
+func (r *Router) store(ctx context.Context, provider, text, optionsDigest, sourceLanguage, targetLanguage, translatedText string) {
+	key := r.cacheKey(provider, text, optionsDigest, sourceLanguage, targetLanguage)
+	// The translation is paid for; keep it even if the caller already left.
+	if err := r.cache.Set(context.WithoutCancel(ctx), key, translatedText, r.cacheTTL); err != nil {
+		log.Printf("Failed to write translation cache: %v", err)
+	}
+}
+
+// Translate picks the route's provider, serving and filling the cache.
+func (r *Router) Translate(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) (*models.Translation, error) {
+	route, err := r.routeFor(opts, sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+	optionsDigest := digestOptions(opts)
+
+	if cached, found := r.cached(ctx, route, text, optionsDigest, sourceLanguage, targetLanguage); found {
+		return cached, nil
+	}
+
+	// A translation the validator rejects counts as a failed attempt, so the
+	// fallback gets its turn. If no provider does better, the last rejected
+	// one is returned uncached and the caller decides what to make of it.
+	var (
+		rejected         string
+		rejectedProvider string
+		attempt          int
+	)
+	meter := &models.UsageMeter{}
+	translatedText, provider, err := withFallback(r, route, meter, sourceLanguage, targetLanguage, func(p models.TranslatorModel) (string, error) {
+		name := route.Primary
+		if route.override != "" {
+			name = route.override
+		} else if attempt++; attempt > 1 {
+			name = route.Fallback
+		}
+
+		var translated string
+		var err error
+		if opts.IsZero() {
+			translated, err = p.TranslateText(ctx, text, sourceLanguage, targetLanguage)
+		} else {
+			translated, err = enforceGlossary(ctx, p, text, opts, sourceLanguage, targetLanguage)
+		}
+		if err != nil {
+			return translated, err
+		}
+		if err = r.validate(text, translated); err != nil {
+			rejected, rejectedProvider = translated, name
+			return translated, fmt.Errorf("rejected translation: %w", err)
+		}
+		return translated, nil
+	})
+	if err != nil {
+		if rejectedProvider == "" {
+			return nil, err
+		}
+		log.Printf("No provider produced a valid translation %s->%s: %v", sourceLanguage, targetLanguage, err)
+		return r.translation(rejectedProvider, rejected, meter.Usage()), nil
+	}
+
+	r.store(ctx, provider, text, optionsDigest, sourceLanguage, targetLanguage, translatedText)
+
+	return r.translation(provider, translatedText, meter.Usage()), nil
+}
+
+// Retranslate skips the cache both ways: the fresh translation is not stored,
+// so it does not replace what other messages with the same text get. A named
+// provider is asked without fallback.
+func (r *Router) Retranslate(ctx context.Context, text string, opts models.TranslateOptions, provider, sourceLanguage, targetLanguage string) (*models.Translation, error) {
+	call := func(p models.TranslatorModel) (string, error) {
+		if opts.IsZero() {
+			return p.TranslateText(ctx, text, sourceLanguage, targetLanguage)
+		}
+		return enforceGlossary(ctx, p, text, opts, sourceLanguage, targetLanguage)
+	}
+
+	meter := &models.UsageMeter{}
+	if provider == "" {
+		translatedText, provider, err := withFallback(r, r.route(sourceLanguage, targetLanguage), meter, sourceLanguage, targetLanguage, call)
+		if err != nil {
+			return nil, err
+		}
+		return r.translation(provider, translatedText, meter.Usage()), nil
+	}
+
+	if _, ok := r.providers[provider]; !ok {
+		return nil, fmt.Errorf("%w: %q", models.ErrUnknownProvider, provider)
+	}
+	translatedText, err := attempt(r, provider, meter, call)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	return r.translation(provider, translatedText, meter.Usage()), nil
+}
+
+// enforceGlossary post-checks the glossary. Providers do not always follow
+// it, so a translation that lost a term is retried once and whichever attempt
+// kept more terms wins.
+func enforceGlossary(ctx context.Context, p models.TranslatorModel, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) (string, error) {
+	translated, err := p.TranslateWithOptions(ctx, text, opts, sourceLanguage, targetLanguage)
+	if err != nil || len(opts.Glossary) == 0 {
+		return translated, err
+	}
+
+	violations := opts.Glossary.Check(text, translated).Violations
+	if len(violations) == 0 {
+		return translated, nil
+	}
+	log.Printf("Translation %s->%s lost glossary terms %q, retrying", sourceLanguage, targetLanguage, violations)
+
+	retried, err := p.TranslateWithOptions(ctx, text, opts, sourceLanguage, targetLanguage)
+	if err != nil {
+		return translated, nil
+	}
+	if len(opts.Glossary.Check(text, retried).Violations) < len(violations) {
+		return retried, nil
+	}
+	return translated, nil
+}
+
+// errStreamStarted stops the fallback once deltas reached the caller, since a
+// second provider would start the translation over.
+var errStreamStarted = errors.New("stream failed after sending output")
+
+// TranslateStream replays a cached translation as one delta. Glossaries are
+// not retried while streaming; the caller sees every attempt as it happens.
+func (r *Router) TranslateStream(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (*models.Translation, error) {
+	route, err := r.routeFor(opts, sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+	optionsDigest := digestOptions(opts)
+
+	if cached, found := r.cached(ctx, route, text, optionsDigest, sourceLanguage, targetLanguage); found {
+		if err := onDelta(cached.Text); err != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+
+	translated, err := r.stream(ctx, route, text, opts, sourceLanguage, targetLanguage, onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stream cannot be taken back, but a broken translation is not kept.
+	if err = r.validate(text, translated.Text); err == nil {
+		r.store(ctx, translated.Provider, text, optionsDigest, sourceLanguage, targetLanguage, translated.Text)
+	}
+
+	return translated, nil
+}
+
+// RetranslateStream is Retranslate for streaming callers: nothing is read
+// from or written to the cache, and a named provider has no fallback.
+func (r *Router) RetranslateStream(ctx context.Context, text string, opts models.TranslateOptions, provider, sourceLanguage, targetLanguage string, onDelta func(string) error) (*models.Translation, error) {
+	opts.Provider = provider
+	route, err := r.routeFor(opts, sourceLanguage, targetLanguage)
+	if err != nil {
+		return nil, err
+	}
+	return r.stream(ctx, route, text, opts, sourceLanguage, targetLanguage, onDelta)
+}
+
+func (r *Router) stream(ctx context.Context, route Route, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (*models.Translation, error) {
+	started := false
+	meter := &models.UsageMeter{}
+	translatedText, provider, err := withFallback(r, route, meter, sourceLanguage, targetLanguage, func(p models.TranslatorModel) (string, error) {
+		if started {
+			return "", errStreamStarted
+		}
+		return p.TranslateStream(ctx, text, opts, sourceLanguage, targetLanguage, func(delta string) error {
+			started = true
+			return onDelta(delta)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.translation(provider, translatedText, meter.Usage()), nil
+}
+
+// TranslateBatch serves what it can from the cache and sends only the
+// remaining texts to the provider, in a single batch. The batch's usage is
+// split over its texts by length.
+func (r *Router) TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]*models.Translation, error) {
+	route := r.route(sourceLanguage, targetLanguage)
+
+	translated := make([]*models.Translation, len(texts))
+	var missing []int
+	for i, text := range texts {
+		if cached, found := r.cached(ctx, route, text, "", sourceLanguage, targetLanguage); found {
+			translated[i] = cached
+			continue
+		}
+		missing = append(missing, i)
+	}
+	if len(missing) == 0 {
+		return translated, nil
+	}
+
+	pending := make([]string, len(missing))
+	pendingLen := 0
+	for j, i := range missing {
+		pending[j] = texts[i]
+		pendingLen += len(texts[i])
+	}
+
+	meter := &models.UsageMeter{}
+	results, provider, err := withFallback(r, route, meter, sourceLanguage, targetLanguage, func(p models.TranslatorModel) ([]string, error) {
+		results, err := p.TranslateBatch(ctx, pending, sourceLanguage, targetLanguage)
+		if err == nil && len(results) != len(pending) {
+			err = fmt.Errorf("got %d of %d translations", len(results), len(pending))
+		}
+		return results, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := meter.Usage()
+	for j, i := range missing {
+		translated[i] = r.translation(provider, results[j], usage.Share(len(texts[i]), pendingLen))
+		if err = r.validate(texts[i], results[j]); err != nil {
+			log.Printf("Not caching a rejected batch translation %s->%s: %v", sourceLanguage, targetLanguage, err)
+			continue
+		}
+		r.store(ctx, provider, texts[i], "", sourceLanguage, targetLanguage, results[j])
+	}
+
+	return translated, nil
+}
@@ -0,0 +1,387 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HJyup/translatify-translation/internal/cache"
+	"github.com/HJyup/translatify-translation/internal/models"
+	"github.com/HJyup/translatify-translation/internal/placeholder"
+)
+
+// stubProvider answers every text with reply, or fails with err.
+type stubProvider struct {
+	FakeProvider
+	reply string
+	err   error
+	calls int
+}
+
+func (p *stubProvider) TranslateText(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error) {
+	p.calls++
+	return p.reply, p.err
+}
+
+func (p *stubProvider) TranslateBatch(ctx context.Context, texts []string, sourceLanguage, targetLanguage string) ([]string, error) {
+	return translateEach(ctx, p, texts, sourceLanguage, targetLanguage)
+}
+
+func (p *stubProvider) TranslateWithOptions(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string) (string, error) {
+	return p.TranslateText(ctx, text, sourceLanguage, targetLanguage)
+}
+
+func (p *stubProvider) TranslateStream(ctx context.Context, text string, opts models.TranslateOptions, sourceLanguage, targetLanguage string, onDelta func(string) error) (string, error) {
+	return streamWhole(ctx, p, text, opts, sourceLanguage, targetLanguage, onDelta)
+}
+
+func newTestRouter(t *testing.T, primary, fallback models.TranslatorModel, c models.TranslationCache) *Router {
+	t.Helper()
+
+	providers := map[string]models.TranslatorModel{"primary": primary}
+	fallbackName := ""
+	if fallback != nil {
+		providers["fallback"] = fallback
+		fallbackName = "fallback"
+	}
+	r, err := NewRouter(providers, "primary", fallbackName, nil, c, time.Hour, placeholder.Check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRouterValidation(t *testing.T) {
+	const text = "open ⟦0⟧"
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		primary      string
+		fallback     string
+		want         string
+		wantProvider string
+		wantCached   bool
+	}{
+		{name: "valid", primary: "öffne ⟦0⟧", fallback: "öffne ⟦0⟧!", want: "öffne ⟦0⟧", wantProvider: "primary", wantCached: true},
+		{name: "fallback repairs", primary: "öffne", fallback: "öffne ⟦0⟧!", want: "öffne ⟦0⟧!", wantProvider: "fallback", wantCached: true},
+		{name: "both rejected", primary: "öffne", fallback: "öffne ⟦0⟧ ⟦0⟧", want: "öffne ⟦0⟧ ⟦0⟧", wantProvider: "fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cache.NewMemoryCache()
+			primary := &stubProvider{reply: tt.primary}
+			r := newTestRouter(t, primary, &stubProvider{reply: tt.fallback}, c)
+
+			got, err := r.Translate(ctx, text, models.TranslateOptions{}, "en", "de")
+			if err != nil {
+				t.Fatalf("Translate: %v", err)
+			}
+			if got.Text != tt.want || got.Provider != tt.wantProvider {
+				t.Errorf("Translate = %q by %s, want %q by %s", got.Text, got.Provider, tt.want, tt.wantProvider)
+			}
+
+			_, cached, _ := c.Get(ctx, r.cacheKey(tt.wantProvider, text, "", "en", "de"))
+			if cached != tt.wantCached {
+				t.Errorf("cached = %v, want %v", cached, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestRouterIgnoresInvalidCacheEntries(t *testing.T) {
+	const text = "open ⟦0⟧"
+	ctx := context.Background()
+
+	c := cache.NewMemoryCache()
+	primary := &stubProvider{reply: "öffne ⟦0⟧"}
+	r := newTestRouter(t, primary, nil, c)
+
+	// An entry written before translations were validated.
+	if err := c.Set(ctx, r.cacheKey("primary", text, "", "en", "de"), "öffne", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.Translate(ctx, text, models.TranslateOptions{}, "en", "de")
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if got.Text != "öffne ⟦0⟧" || primary.calls != 1 {
+		t.Errorf("Translate = %q after %d calls, want a fresh translation", got.Text, primary.calls)
+	}
+}
+
+func TestRouterBatchSkipsRejected(t *testing.T) {
+	ctx := context.Background()
+
+	c := cache.NewMemoryCache()
+	r := newTestRouter(t, &stubProvider{reply: "öffne"}, nil, c)
+
+	texts := []string{"open ⟦0⟧", "open"}
+	got, err := r.TranslateBatch(ctx, texts, "en", "de")
+	if err != nil {
+		t.Fatalf("TranslateBatch: %v", err)
+	}
+	if len(got) != len(texts) {
+		t.Fatalf("got %d translations, want %d", len(got), len(texts))
+	}
+
+	for i, wantCached := range []bool{false, true} {
+		_, cached, _ := c.Get(ctx, r.cacheKey("primary", texts[i], "", "en", "de"))
+		if cached != wantCached {
+			t.Errorf("%q cached = %v, want %v", texts[i], cached, wantCached)
+		}
+	}
+}
+
+func TestRouterProviderErrorIsNotMaskedByValidation(t *testing.T) {
+	boom := errors.New("boom")
+	r := newTestRouter(t, &stubProvider{err: boom}, nil, cache.NewMemoryCache())
+
+	_, err := r.Translate(context.Background(), "open ⟦0⟧", models.TranslateOptions{}, "en", "de")
+	if !errors.Is(err, boom) || !strings.HasPrefix(err.Error(), "primary") {
+		t.Errorf("Translate error = %v, want primary: boom", err)
+	}
+}
+
+func TestRouterProviderOverride(t *testing.T) {
+	ctx := context.Background()
+
+	c := cache.NewMemoryCache()
+	primary := &stubProvider{reply: "teuer"}
+	cheap := &stubProvider{reply: "billig"}
+	r, err := NewRouter(map[string]models.TranslatorModel{"primary": primary, "cheap": cheap}, "primary", "", nil, c, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set(ctx, r.cacheKey("primary", "cached", "", "en", "de"), "zwischengespeichert", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	opts := models.TranslateOptions{Provider: "cheap"}
+
+	got, err := r.Translate(ctx, "cached", opts, "en", "de")
+	if err != nil || got.Text != "zwischengespeichert" || got.Provider != "primary" {
+		t.Errorf("Translate(cached) = %+v, %v, want the primary's cache entry", got, err)
+	}
+
+	var deltas []string
+	got, err = r.TranslateStream(ctx, "new", opts, "en", "de", func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || got.Text != "billig" || got.Provider != "cheap" || len(deltas) == 0 {
+		t.Errorf("TranslateStream(new) = %+v with %q, %v, want a streamed translation by cheap", got, deltas, err)
+	}
+	if primary.calls != 0 {
+		t.Errorf("primary was called %d times, want 0", primary.calls)
+	}
+
+	got, err = r.Translate(ctx, "new", opts, "en", "de")
+	if err != nil || got.Text != "billig" || cheap.calls != 1 {
+		t.Errorf("Translate(new) = %+v, %v after %d calls, want the cached stream", got, err, cheap.calls)
+	}
+
+	if _, err = r.Translate(ctx, "x", models.TranslateOptions{Provider: "missing"}, "en", "de"); !errors.Is(err, models.ErrUnknownProvider) {
+		t.Errorf("Translate with an unknown provider: %v, want %v", err, models.ErrUnknownProvider)
+	}
+}
+
+// billingProvider bills usage for every call, failed or not.
+type billingProvider struct {
+	stubProvider
+	usage models.Usage
+	meter *models.UsageMeter
+}
+
+func (p *billingProvider) WithUsage(meter *models.UsageMeter) models.TranslatorModel {
+	metered := *p
+	metered.meter = meter
+	return &metered
+}
+
+func (p *billingProvider) TranslateText(ctx context.Context, text, sourceLanguage, targetLanguage string) (string, error) {
+	p.meter.Add(p.usage)
+	return p.reply, p.err
+}
+
+func TestRouterReportsBilledFailures(t *testing.T) {
+	bad := errors.New("unusable answer")
+	primary := &billingProvider{stubProvider: stubProvider{err: bad}, usage: models.Usage{PromptTokens: 7}}
+	fallback := &stubProvider{err: errors.New("down")}
+	r := newTestRouter(t, primary, fallback, cache.NewMemoryCache())
+
+	_, err := r.Translate(context.Background(), "hello", models.TranslateOptions{}, "en", "de")
+	if !errors.Is(err, bad) {
+		t.Fatalf("Translate error = %v, want %v", err, bad)
+	}
+
+	billed := models.BilledErrors(err)
+	if len(billed) != 1 || billed[0].Provider != "primary" || billed[0].Usage != primary.usage {
+		t.Errorf("BilledErrors = %+v, want the primary's usage", billed)
+	}
+}
+
+func TestRouterRetranslateStream(t *testing.T) {
+	ctx := context.Background()
+
+	c := cache.NewMemoryCache()
+	primary := &stubProvider{reply: "neu"}
+	cheap := &stubProvider{reply: "billig"}
+	r, err := NewRouter(map[string]models.TranslatorModel{"primary": primary, "cheap": cheap}, "primary", "", nil, c, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set(ctx, r.cacheKey("primary", "hello", "", "en", "de"), "alt", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		provider     string
+		want         string
+		wantProvider string
+	}{
+		{name: "route", want: "neu", wantProvider: "primary"},
+		{name: "named provider", provider: "cheap", want: "billig", wantProvider: "cheap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var streamed string
+			got, err := r.RetranslateStream(ctx, "hello", models.TranslateOptions{}, tt.provider, "en", "de", func(delta string) error {
+				streamed += delta
+				return nil
+			})
+			if err != nil || got.Text != tt.want || got.Provider != tt.wantProvider || streamed != tt.want {
+				t.Errorf("RetranslateStream = %+v streaming %q, %v, want %q by %s", got, streamed, err, tt.want, tt.wantProvider)
+			}
+		})
+	}
+
+	if value, _, _ := c.Get(ctx, r.cacheKey("primary", "hello", "", "en", "de")); value != "alt" {
+		t.Errorf("cache entry = %q, want it untouched", value)
+	}
+	if _, found, _ := c.Get(ctx, r.cacheKey("cheap", "hello", "", "en", "de")); found {
+		t.Error("the retranslation was cached")
+	}
+}
+
+func TestRouterCountsOneLookupPerRequest(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := cache.NewInstrumented("memory", cache.NewMemoryCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter(t, &stubProvider{reply: "hallo"}, &stubProvider{reply: "servus"}, c)
+
+	for _, want := range []models.CacheStats{{Misses: 1}, {Hits: 1, Misses: 1}} {
+		if _, err = r.Translate(ctx, "hello", models.TranslateOptions{}, "en", "de"); err != nil {
+			t.Fatalf("Translate: %v", err)
+		}
+		want.Backend = "memory"
+		if got := c.Stats(); got != want {
+			t.Errorf("Stats = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []Route
+		wantErr bool
+	}{
+		{spec: ""},
+		{
+			spec: "en:ja=deepl,openai; *:fr = libre ;",
+			want: []Route{
+				{SourceLang: "en", TargetLang: "ja", Primary: "deepl", Fallback: "openai"},
+				{SourceLang: "*", TargetLang: "fr", Primary: "libre"},
+			},
+		},
+		{spec: "en:ja", wantErr: true},
+		{spec: "en=deepl", wantErr: true},
+		{spec: ":ja=deepl", wantErr: true},
+		{spec: "en:ja=,openai", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRoutes(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoutes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterWithFakeProvider(t *testing.T) {
+	ctx := context.Background()
+	down := errors.New("down")
+
+	tests := []struct {
+		name         string
+		routes       string
+		primaryErr   error
+		source       string
+		target       string
+		want         string
+		wantProvider string
+	}{
+		{name: "default route", source: "en", target: "de", want: "[en->de] hello", wantProvider: "primary"},
+		{name: "fallback", primaryErr: down, source: "en", target: "de", want: "[en->de] hello", wantProvider: "fake"},
+		{name: "route by pair", routes: "en:ja=fake", primaryErr: down, source: "EN", target: "ja", want: "[EN->ja] hello", wantProvider: "fake"},
+		{name: "wildcard route", routes: "*:fr=fake", primaryErr: down, source: "de", target: "fr", want: "[de->fr] hello", wantProvider: "fake"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := ParseRoutes(tt.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			primary := &stubProvider{reply: "[" + tt.source + "->" + tt.target + "] hello", err: tt.primaryErr}
+			providers := map[string]models.TranslatorModel{"primary": primary, "fake": &FakeProvider{}}
+			c := cache.NewMemoryCache()
+			r, err := NewRouter(providers, "primary", "fake", routes, c, time.Hour, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				got, err := r.Translate(ctx, "hello", models.TranslateOptions{}, tt.source, tt.target)
+				if err != nil {
+					t.Fatalf("Translate: %v", err)
+				}
+				if got.Text != tt.want || got.Provider != tt.wantProvider {
+					t.Errorf("Translate #%d = %q by %s, want %q by %s", i+1, got.Text, got.Provider, tt.want, tt.wantProvider)
+				}
+			}
+
+			// The second call is served from the cache, under the provider
+			// that made the translation.
+			if primary.calls > 1 {
+				t.Errorf("primary called %d times, want at most 1", primary.calls)
+			}
+			if _, found, _ := c.Get(ctx, r.cacheKey(tt.wantProvider, "hello", "", tt.source, tt.target)); !found {
+				t.Errorf("translation is not cached under %s", tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestNewRouterRejectsUnknownProviders(t *testing.T) {
+	providers := map[string]models.TranslatorModel{"fake": &FakeProvider{}}
+	routes := []Route{{SourceLang: "en", TargetLang: "ja", Primary: "deepl"}}
+
+	if _, err := NewRouter(providers, "fake", "", routes, cache.NewMemoryCache(), time.Hour, nil); err == nil {
+		t.Error("NewRouter accepted a route to an unconfigured provider")
+	}
+	if _, err := NewRouter(providers, "fake", "openai", nil, cache.NewMemoryCache(), time.Hour, nil); err == nil {
+		t.Error("NewRouter accepted an unconfigured fallback")
+	}
+}
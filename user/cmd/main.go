@@ -17,6 +17,7 @@ import (
 
 	"github.com/HJyup/translatify-common/discovery"
 	"github.com/HJyup/translatify-common/discovery/consul"
+	"github.com/HJyup/translatify-common/pagination"
 	common "github.com/HJyup/translatify-common/utils"
 	"github.com/jackc/pgx/v5"
 	"google.golang.org/grpc"
@@ -24,21 +25,38 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 )
 
+// The database settings are all the migrate subcommand needs, so they are
+// the only ones read up front.
 var (
-	serviceName = common.EnvString("SERVICE_NAME")
-	grpcAddr    = common.EnvString("GRPC_ADDR")
-	consulAddr  = common.EnvString("CONSUL_ADDR")
-	environment = common.EnvString("ENVIRONMENT")
-
 	dbUser = common.EnvString("POSTGRES_USER")
 	dbPass = common.EnvString("POSTGRES_PASSWORD")
 	dbName = common.EnvString("POSTGRES_DB_NAME")
 	dbHost = common.EnvString("POSTGRES_DB")
 	dbPort = common.EnvString("POSTGRES_PORT")
+)
 
-	jaegerAddr = common.EnvString("JAEGER_ADDR")
+var (
+	serviceName string
+	grpcAddr    string
+	consulAddr  string
+	environment string
+
+	jaegerAddr string
+
+	pageTokenSecret string
 )
 
+func loadServiceEnv() {
+	serviceName = common.EnvString("SERVICE_NAME")
+	grpcAddr = common.EnvString("GRPC_ADDR")
+	consulAddr = common.EnvString("CONSUL_ADDR")
+	environment = common.EnvString("ENVIRONMENT")
+
+	jaegerAddr = common.EnvString("JAEGER_ADDR")
+
+	pageTokenSecret = common.EnvString("PAGE_TOKEN_SECRET")
+}
+
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -50,6 +68,32 @@ func main() {
 		cancel()
 	}()
 
+	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", dbUser, dbPass, dbName, dbPort, dbHost)
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		log.Fatalf("Failed to parse database config: %v", err)
+	}
+
+	dbConn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	defer dbConn.Close(ctx)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrations(ctx, dbConn, os.Args[2:]); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		return
+	}
+
+	loadServiceEnv()
+
+	if err = runMigrations(ctx, dbConn, nil); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
+
 	tracerCfg := tracer.Config{
 		ServiceName:    serviceName,
 		ServiceVersion: "1.0.0",
@@ -95,20 +139,7 @@ func main() {
 	}()
 	defer registry.DeRegister(instanceID)
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", dbUser, dbPass, dbName, dbPort, dbHost)
-
-	config, err := pgx.ParseConfig(dsn)
-	if err != nil {
-		log.Fatalf("Failed to parse database config: %v", err)
-	}
-
-	dbConn, err := pgx.ConnectConfig(ctx, config)
-	if err != nil {
-		log.Fatalf("Failed to connect to the database: %v", err)
-	}
-	defer dbConn.Close(ctx)
-
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(discovery.ServerOptions()...)
 	conn, err := net.Listen("tcp", grpcAddr)
 	if err != nil {
 		log.Fatalf("Failed to listen on %s: %v", grpcAddr, err)
@@ -116,7 +147,7 @@ func main() {
 	defer conn.Close()
 
 	str := store.NewStore(dbConn)
-	srv := service.NewService(str)
+	srv := service.NewService(str, pagination.NewCodec(pageTokenSecret))
 	handler.NewGrpcHandler(grpcServer, srv)
 
 	log.Printf("Starting chat server on %s", grpcAddr)
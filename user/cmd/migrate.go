@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/HJyup/translatify-common/migrate"
+	"github.com/HJyup/translatify-user/internal/store"
+	"github.com/jackc/pgx/v5"
+)
+
+// migrationLock scopes the migration advisory lock. It is fixed rather than
+// taken from SERVICE_NAME, so the migrate subcommand only needs the database
+// settings.
+const migrationLock = "user"
+
+// runMigrations handles both the startup migration and the migrate subcommand:
+//
+//	user migrate [up | down [steps] | version]
+func runMigrations(ctx context.Context, dbConn *pgx.Conn, args []string) error {
+	migrator, err := migrate.NewMigrator(dbConn, store.Migrations(), migrationLock)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Schema version: %d", version)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
+}
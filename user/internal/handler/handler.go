@@ -2,8 +2,10 @@ package handler
 
 import (
 	"context"
+	"errors"
 
 	pb "github.com/HJyup/translatify-common/api"
+	"github.com/HJyup/translatify-common/pagination"
 	models "github.com/HJyup/translatify-user/internal/model"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -63,7 +65,15 @@ func (h *GrpcHandler) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest)
 }
 
 func (h *GrpcHandler) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
-	domainUsers, nextPageToken, err := h.service.ListUsers(int(req.GetLimit()), req.GetPageToken())
+	direction := pagination.Forward
+	if req.GetDirection() == pb.PageDirection_PAGE_DIRECTION_BACKWARD {
+		direction = pagination.Backward
+	}
+
+	domainUsers, nextPageToken, err := h.service.ListUsers(int(req.GetLimit()), req.GetPageToken(), direction)
+	if errors.Is(err, pagination.ErrInvalidToken) {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to list users: %v", err)
 	}
@@ -3,20 +3,22 @@ package service
 import (
 	"context"
 	"time"
+
+	"github.com/HJyup/translatify-common/pagination"
 )
 
 type UserService interface {
 	CreateUser(username, email, password, language string) (string, error)
 	GetUser(username string) (*User, error)
 	DeleteUser(userId string) (bool, error)
-	ListUsers(limit int, paginationToken string) ([]*User, string, error)
+	ListUsers(limit int, paginationToken string, direction pagination.Direction) ([]*User, string, error)
 }
 
 type UserStore interface {
 	CreateUser(ctx context.Context, username, email, password, language string) (*User, error)
 	GetUser(ctx context.Context, username string) (*User, error)
 	DeleteUser(ctx context.Context, userId string) (bool, error)
-	ListUsers(ctx context.Context, limit int, paginationToken string) ([]*User, string, error)
+	ListUsers(ctx context.Context, limit int, cursor *pagination.Cursor, direction pagination.Direction) ([]*User, *pagination.Cursor, error)
 }
 
 type User struct {
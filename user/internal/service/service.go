@@ -5,6 +5,7 @@ import (
 	"errors"
 	models "github.com/HJyup/translatify-user/internal/model"
 
+	"github.com/HJyup/translatify-common/pagination"
 	"github.com/HJyup/translatify-common/utils"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -12,11 +13,12 @@ import (
 const MinPasswordLength = 8
 
 type UserService struct {
-	store models.UserStore
+	store  models.UserStore
+	tokens *pagination.Codec
 }
 
-func NewService(store models.UserStore) *UserService {
-	return &UserService{store: store}
+func NewService(store models.UserStore, tokens *pagination.Codec) *UserService {
+	return &UserService{store: store, tokens: tokens}
 }
 
 func (s *UserService) CreateUser(username, email, password, language string) (string, error) {
@@ -78,17 +80,22 @@ func (s *UserService) DeleteUser(userId string) (bool, error) {
 	return true, nil
 }
 
-func (s *UserService) ListUsers(limit int, paginationToken string) ([]*models.User, string, error) {
+func (s *UserService) ListUsers(limit int, paginationToken string, direction pagination.Direction) ([]*models.User, string, error) {
 	ctx := context.Background()
 
 	if limit <= 0 {
 		limit = 10
 	}
 
-	users, token, err := s.store.ListUsers(ctx, limit, paginationToken)
+	cursor, err := s.tokens.Decode(paginationToken, direction)
 	if err != nil {
 		return nil, "", err
 	}
 
-	return users, token, nil
+	users, next, err := s.store.ListUsers(ctx, limit, cursor, direction)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return users, s.tokens.Encode(next, direction), nil
 }
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+    user_id    UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username   TEXT NOT NULL UNIQUE,
+    email      TEXT NOT NULL UNIQUE,
+    password   TEXT NOT NULL,
+    language   TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- ListUsers pages in creation order.
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at, user_id);
@@ -3,12 +3,14 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
 	models "github.com/HJyup/translatify-user/internal/model"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"strconv"
+	"slices"
 	"time"
 
+	"github.com/HJyup/translatify-common/pagination"
 	"github.com/HJyup/translatify-common/utils"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v5"
@@ -73,25 +75,26 @@ func (s *Store) DeleteUser(ctx context.Context, userId string) (bool, error) {
 	return true, nil
 }
 
-func (s *Store) ListUsers(ctx context.Context, limit int, paginationToken string) ([]*models.User, string, error) {
-	var effectiveSince int64 = 0
-	if paginationToken != "" {
-		if tokenTs, err := strconv.ParseInt(paginationToken, 10, 64); err == nil && tokenTs > effectiveSince {
-			effectiveSince = tokenTs
-		}
+func (s *Store) ListUsers(ctx context.Context, limit int, cursor *pagination.Cursor, direction pagination.Direction) ([]*models.User, *pagination.Cursor, error) {
+	order, cmp := "ASC", ">"
+	if direction == pagination.Backward {
+		order, cmp = "DESC", "<"
 	}
 
 	query := `
 		SELECT user_id, username, email, password, language, created_at
 		FROM users
-		WHERE created_at > to_timestamp($1)
-		ORDER BY created_at ASC
-		LIMIT $2
 	`
+	var args []any
+	if cursor != nil {
+		query += ` WHERE (created_at, user_id) ` + cmp + ` ($1, $2)`
+		args = append(args, cursor.Time, cursor.ID)
+	}
+	query += fmt.Sprintf(` ORDER BY created_at %s, user_id %s LIMIT %d`, order, order, limit+1)
 
-	rows, err := s.dbConn.Query(ctx, query, effectiveSince, limit+1)
+	rows, err := s.dbConn.Query(ctx, query, args...)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
@@ -99,21 +102,26 @@ func (s *Store) ListUsers(ctx context.Context, limit int, paginationToken string
 	for rows.Next() {
 		user, err := scanUser(rows)
 		if err != nil {
-			return nil, "", err
+			return nil, nil, err
 		}
 		users = append(users, user)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	var nextPageToken string
+	var next *pagination.Cursor
 	if len(users) > limit {
-		nextPageToken = strconv.FormatInt(users[limit].CreatedAt.Unix(), 10)
 		users = users[:limit]
+		last := users[limit-1]
+		next = &pagination.Cursor{Time: last.CreatedAt, ID: last.UserId}
 	}
 
-	return users, nextPageToken, nil
+	if direction == pagination.Backward {
+		slices.Reverse(users)
+	}
+
+	return users, next, nil
 }
 
 func scanUser(rs utils.RowScanner) (*models.User, error) {
@@ -132,6 +140,7 @@ func scanUser(rs utils.RowScanner) (*models.User, error) {
 		return nil, err
 	}
 	return &models.User{
+		UserId:    userId,
 		Username:  username,
 		Email:     email,
 		Language:  language,